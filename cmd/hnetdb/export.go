@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/network"
+	"os"
+)
+
+// export implements "hnetdb export [-config file] [-storage kind] [-data file] dot|mermaid|geojson",
+// which writes the network to standard output, e.g. for the wiki.
+func export(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	configFile := configFlag(flags)
+	storageKind := flags.String("storage", "", "where the registry is kept: neo4j or file")
+	dataFile := flags.String("data", "", "data file for -storage=file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: hnetdb export [-config file] [-storage kind] [-data file] dot|mermaid|geojson")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	format := flags.Arg(0)
+	if flags.NArg() != 1 || (format != "dot" && format != "mermaid" && format != "geojson") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	store, err := commandStorage(*configFile, *storageKind, *dataFile)
+	if err != nil {
+		fail(err)
+	}
+	exporter := &network.Exporter{
+		NodeRepository: store.nodes,
+		LinkRepository: store.links,
+	}
+	loaded, err := exporter.Load()
+	// fail exits without running deferred calls.
+	closeErr := store.close()
+	if err != nil {
+		fail(err)
+	}
+	if closeErr != nil {
+		fail(closeErr)
+	}
+
+	switch format {
+	case "dot":
+		fmt.Print(loaded.DOT())
+	case "mermaid":
+		fmt.Print(loaded.Mermaid())
+	case "geojson":
+		bytes, _ := json.MarshalIndent(loaded.GeoJSON(), "", "  ")
+		fmt.Println(string(bytes))
+	}
+}
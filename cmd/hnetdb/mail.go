@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/config"
+	"github.com/mvslovers/hnetdb/pkg/mail"
+	"log"
+	"os"
+)
+
+// openMailer returns the configured mailer. Logged mails go to the
+// server log unless a mail file is configured.
+func openMailer(settings config.Mail, logger *log.Logger) (mail.Mailer, error) {
+	switch settings.Kind {
+	case "smtp":
+		return &mail.SMTPMailer{
+			Address:  settings.SMTP.Address,
+			Username: settings.SMTP.Username,
+			Password: settings.SMTP.Password,
+			From:     settings.From,
+		}, nil
+	case "log":
+		if settings.File == "" {
+			return &mail.LogMailer{Logger: logger}, nil
+		}
+		file, err := os.OpenFile(settings.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return &mail.LogMailer{Logger: log.New(file, "", log.LstdFlags)}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail kind %q", settings.Kind)
+	}
+}
@@ -1,60 +1,182 @@
 package main
 
 import (
-	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/config"
+	"github.com/mvslovers/hnetdb/pkg/health"
 	"github.com/mvslovers/hnetdb/pkg/users"
-	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		export(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "user" {
+		user(os.Args[2:])
+		return
+	}
+	serve(os.Args[1:])
+}
 
-	neo4jUri, found := os.LookupEnv("NEO4J_URI")
-	if !found {
-		panic("NEO4J_URI not set")
+func serve(args []string) {
+	flags := flag.NewFlagSet("hnetdb", flag.ExitOnError)
+	configFile := configFlag(flags)
+	listen := flags.String("listen", "", "address to listen on, e.g. :3000")
+	storageKind := flags.String("storage", "", "where to keep the registry: neo4j, file or memory")
+	dataFile := flags.String("data", "", "data file for -storage=file")
+	logLevel := flags.String("log-level", "", "info or debug")
+	_ = flags.Parse(args)
+
+	settings, err := config.Load(*configFile)
+	if err != nil {
+		fail(err)
 	}
-	neo4jUsername, found := os.LookupEnv("NEO4J_USERNAME")
-	if !found {
-		panic("NEO4J_USERNAME not set")
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			settings.Listen = *listen
+		case "storage":
+			settings.Storage.Kind = *storageKind
+		case "data":
+			settings.Storage.File = *dataFile
+		case "log-level":
+			settings.Log.Level = *logLevel
+		}
+	})
+	if err := settings.Validate(); err != nil {
+		fail(err)
 	}
-	neo4jPassword, found := os.LookupEnv("NEO4J_PASSWORD")
-	if !found {
-		panic("NEO4J_PASSWORD not set")
+
+	logger, err := openLog(settings.Log)
+	if err != nil {
+		fail(err)
 	}
-	usersRepository := users.UserNeo4jRepository{
-		Driver: driver(neo4jUri, neo4j.BasicAuth(neo4jUsername, neo4jPassword, "")),
+
+	store, err := openStorage(settings, logger)
+	if err != nil {
+		fail(err)
 	}
-	nodesRepository := nodes.NodeNeo4jRepository{
-		Driver: driver(neo4jUri, neo4j.BasicAuth(neo4jUsername, neo4jPassword, "")),
+
+	mailer, err := openMailer(settings.Mail, logger)
+	if err != nil {
+		fail(err)
 	}
 
-	registrationHandler := &users.UserRegistrationHandler{
-		Path:           "/users/register",
-		UserRepository: &usersRepository,
+	authenticator := &users.Authenticator{
+		Tokens: &users.Tokens{
+			Secret: []byte(settings.JWT.Secret),
+			TTL:    time.Duration(settings.JWT.TTL),
+		},
+		Sessions: &users.Sessions{
+			Repository: store.sessions,
+			TTL:        time.Duration(settings.JWT.RefreshTTL),
+		},
+		APITokens: &users.APITokens{
+			Repository:     store.apiTokens,
+			UserRepository: store.users,
+		},
 	}
-	loginHandler := &users.UserLoginHandler{
-		Path:           "/users/login",
-		UserRepository: &usersRepository,
+
+	accountMails := &users.AccountMails{
+		UserRepository: store.users,
+		Tokens:         store.mailed,
+		Mailer:         mailer,
+		PublicURL:      settings.PublicURL,
+		Sessions:       authenticator.Sessions,
+		ErrorLog:       logger,
 	}
-	newNodeHandler := &nodes.NewNodeHandler{
-		Path:           "/node",
-		NodeRepository: &nodesRepository,
+
+	// stopping makes /readyz fail for the shutdown delay and while
+	// in-flight requests drain, so load balancers stop sending new ones.
+	var stopping int32
+	ready := health.Check{Name: "server", Run: func() error {
+		if atomic.LoadInt32(&stopping) != 0 {
+			return errors.New("shutting down")
+		}
+		return nil
+	}}
+
+	throttle := loginThrottle(settings.Login, store.logins)
+
+	var handler http.Handler = routes(store, authenticator, accountMails, throttle,
+		mailThrottle(settings.Login), ready)
+	handler = cors(settings.CORS, handler)
+	if settings.Log.Level == "debug" {
+		handler = logRequests(logger, handler)
 	}
-	server := http.NewServeMux()
-	server.HandleFunc(registrationHandler.Path, registrationHandler.Register)
-	server.HandleFunc(loginHandler.Path, loginHandler.Login)
-	server.HandleFunc(newNodeHandler.Path, newNodeHandler.New)
 
-	if err := http.ListenAndServe(":3000", server); err != nil {
-		panic(err)
+	server := &http.Server{
+		Addr:     settings.Listen,
+		Handler:  handler,
+		ErrorLog: logger,
 	}
-}
 
-func driver(target string, token neo4j.AuthToken) neo4j.Driver {
-	result, err := neo4j.NewDriver(target, token)
-	if err != nil {
-		panic(err)
+	failed := make(chan error, 1)
+	go func() {
+		logger.Printf("listening on %s with %s storage", settings.Listen, settings.Storage.Kind)
+		var err error
+		if settings.TLS.Enabled() {
+			err = server.ListenAndServeTLS(settings.TLS.CertFile, settings.TLS.KeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			failed <- err
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-failed:
+		_ = store.close()
+		fail(err)
+	case received := <-signals:
+		logger.Printf("%v received, shutting down", received)
+	}
+
+	// Load balancers only stop sending requests after their next probes
+	// of /readyz, so keep serving until then. Another signal cuts it short.
+	atomic.StoreInt32(&stopping, 1)
+	if delay := time.Duration(settings.ShutdownDelay); delay > 0 {
+		logger.Printf("draining for %v", delay)
+		select {
+		case <-time.After(delay):
+		case <-signals:
+		}
 	}
-	return result
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(settings.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Printf("requests still running after %v: %v", time.Duration(settings.ShutdownTimeout), err)
+	}
+	accountMails.Wait()
+	if err := store.close(); err != nil {
+		logger.Printf("closing storage: %v", err)
+	}
+	logger.Printf("stopped")
+}
+
+// configFlag adds the -config flag, which defaults to $HNETDB_CONFIG.
+func configFlag(flags *flag.FlagSet) *string {
+	return flags.String("config", os.Getenv("HNETDB_CONFIG"), "YAML configuration file")
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "hnetdb:", err)
+	os.Exit(1)
 }
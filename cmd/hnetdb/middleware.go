@@ -0,0 +1,81 @@
+package main
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/config"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// openLog returns the server log, writing to standard error unless a
+// log file is configured.
+func openLog(settings config.Log) (*log.Logger, error) {
+	var writer io.Writer = os.Stderr
+	if settings.File != "" {
+		file, err := os.OpenFile(settings.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+		if err != nil {
+			return nil, err
+		}
+		writer = file
+	}
+	return log.New(writer, "", log.LstdFlags), nil
+}
+
+// cors answers preflight requests and adds the CORS headers for the
+// configured origins.
+func cors(settings config.CORS, next http.Handler) http.Handler {
+	if len(settings.AllowedOrigins) == 0 {
+		return next
+	}
+	allowed := map[string]bool{}
+	for _, origin := range settings.AllowedOrigins {
+		allowed[origin] = true
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		origin := request.Header.Get("Origin")
+		if origin == "" || (!allowed["*"] && !allowed[origin]) {
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		header := writer.Header()
+		header.Add("Vary", "Origin")
+		header.Set("Access-Control-Allow-Origin", origin)
+		// Paging and throttling are told in headers.
+		header.Set("Access-Control-Expose-Headers", "Link, Retry-After, X-Total-Count")
+		if request.Method == "OPTIONS" && request.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", strings.Join([]string{
+				"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE",
+			}, ", "))
+			header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			header.Set("Access-Control-Max-Age", "600")
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests writes one line per request to logger.
+func logRequests(logger *log.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		next.ServeHTTP(recorder, request)
+		logger.Printf("%s %s %d %s", request.Method, request.URL.Path, recorder.status, time.Since(start))
+	})
+}
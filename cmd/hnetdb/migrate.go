@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/config"
+	"github.com/mvslovers/hnetdb/pkg/migrations"
+	"os"
+)
+
+// migrate implements "hnetdb migrate [-config file] [-dir path] up|status".
+func migrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configFile := configFlag(flags)
+	dir := flags.String("dir", "", "directory with local Cypher migrations, applied after the builtin ones")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: hnetdb migrate [-config file] [-dir path] up|status")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	command := flags.Arg(0)
+	if flags.NArg() != 1 || (command != "up" && command != "status") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	all := migrations.Builtin
+	if *dir != "" {
+		loaded, err := migrations.Load(*dir)
+		if err != nil {
+			fail(err)
+		}
+		all = append(append([]migrations.Migration{}, all...), loaded...)
+	}
+
+	settings, err := config.Load(*configFile)
+	if err != nil {
+		fail(err)
+	}
+	if settings.Neo4j.URI == "" {
+		fail(errors.New("neo4j.uri is not configured (or set NEO4J_URI)"))
+	}
+	driver, err := openDriver(settings.Neo4j)
+	if err != nil {
+		fail(err)
+	}
+	runner := &migrations.Runner{
+		Driver:     driver,
+		Migrations: all,
+	}
+	defer func() {
+		_ = runner.Driver.Close()
+	}()
+
+	switch command {
+	case "up":
+		applied, err := runner.Up()
+		for _, migration := range applied {
+			fmt.Printf("applied %-10s %s\n", migration.ID(), migration.Description)
+		}
+		if err != nil {
+			fail(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "status":
+		status, err := runner.Status()
+		if err != nil {
+			fail(err)
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%-7s %-10s %s\n", state, s.ID(), s.Description)
+		}
+	}
+}
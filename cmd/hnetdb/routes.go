@@ -0,0 +1,193 @@
+package main
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"github.com/mvslovers/hnetdb/pkg/health"
+	"github.com/mvslovers/hnetdb/pkg/network"
+	"github.com/mvslovers/hnetdb/pkg/njeconfig"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"net/http"
+	"strings"
+)
+
+// routes wires the handlers of all resources. ready is checked by
+// /readyz in addition to the storage checks.
+func routes(store *storage, authenticator *users.Authenticator, accountMails *users.AccountMails,
+	throttle *users.LoginThrottle, mailThrottle *users.LoginThrottle, ready health.Check) *http.ServeMux {
+	livenessHandler := &health.LivenessHandler{
+		Path: "/healthz",
+	}
+	readinessHandler := &health.ReadinessHandler{
+		Path:   "/readyz",
+		Checks: append([]health.Check{ready}, store.checks...),
+	}
+	registrationHandler := &users.UserRegistrationHandler{
+		Path:           "/users/register",
+		UserRepository: store.users,
+		AccountMails:   accountMails,
+	}
+	verificationHandler := &users.EmailVerificationHandler{
+		Path:         "/users/verify",
+		AccountMails: accountMails,
+		Throttle:     mailThrottle,
+	}
+	passwordResetHandler := &users.PasswordResetHandler{
+		Path:         "/users/password",
+		AccountMails: accountMails,
+		Throttle:     mailThrottle,
+	}
+	loginHandler := &users.UserLoginHandler{
+		Path:           "/users/login",
+		UserRepository: store.users,
+		Tokens:         authenticator.Tokens,
+		Sessions:       authenticator.Sessions,
+		Throttle:       throttle,
+	}
+	tokenRefreshHandler := &users.TokenRefreshHandler{
+		Path:           "/users/token/refresh",
+		UserRepository: store.users,
+		Tokens:         authenticator.Tokens,
+		Sessions:       authenticator.Sessions,
+	}
+	logoutHandler := &users.LogoutHandler{
+		Path:     "/users/logout",
+		Sessions: authenticator.Sessions,
+	}
+	apiTokenHandler := &users.APITokenHandler{
+		Path:      "/users/me/tokens",
+		APITokens: authenticator.APITokens,
+	}
+	profileHandler := &users.ProfileHandler{
+		Path:           "/users/me",
+		UserRepository: store.users,
+		References:     store.ownership,
+		AccountMails:   accountMails,
+		Tokens:         authenticator.Tokens,
+		Sessions:       authenticator.Sessions,
+	}
+	adminHandler := &users.AdminHandler{
+		Path:           "/admin/users",
+		UserRepository: store.users,
+		Sessions:       authenticator.Sessions,
+	}
+	failedLoginHandler := &users.FailedLoginHandler{
+		Path:       "/admin/failed-logins",
+		Repository: store.logins,
+	}
+	newNodeHandler := &nodes.NewNodeHandler{
+		Path:           "/node",
+		NodeRepository: store.nodes,
+	}
+	linkHandler := &nodes.LinkHandler{
+		Path:           "/links",
+		LinkRepository: store.links,
+	}
+	routeHandler := &nodes.RouteHandler{
+		Path:           "/routes",
+		LinkRepository: store.links,
+	}
+	searchHandler := &nodes.SearchHandler{
+		Path:         "/search/nodes",
+		NodeSearcher: store.search,
+	}
+	countryHandler := &nodes.CountryHandler{
+		Path:           "/countries",
+		NodeRepository: store.nodes,
+	}
+	networkHandler := &network.Handler{
+		Path: "/network",
+		Exporter: &network.Exporter{
+			NodeRepository: store.nodes,
+			LinkRepository: store.links,
+		},
+	}
+	nodeHandler := &nodes.NodeHandler{
+		Path:           "/node/",
+		NodeRepository: store.nodes,
+	}
+	ownershipHandler := &nodes.OwnershipHandler{
+		Path:                "/node/",
+		NodeRepository:      store.nodes,
+		OwnershipRepository: store.ownership,
+	}
+	userNodesHandler := &nodes.UserNodesHandler{
+		Path:                "/users/",
+		OwnershipRepository: store.ownership,
+	}
+	moderationHandler := &nodes.ModerationHandler{
+		Path:           "/moderation/nodes",
+		NodeRepository: store.nodes,
+	}
+	configHandler := &njeconfig.ConfigHandler{
+		Path: "/node/",
+		Generator: &njeconfig.Generator{
+			NodeRepository: store.nodes,
+			LinkRepository: store.links,
+		},
+	}
+
+	authenticate := authenticator.Authenticate
+	authenticateWrites := authenticator.AuthenticateWrites
+	// nodeScopes limits requests made with API tokens to their scopes.
+	nodeScopes := func(next http.HandlerFunc) http.HandlerFunc {
+		return users.RequireScopes(users.ScopeNodesRead, users.ScopeNodesWrite, next)
+	}
+
+	server := http.NewServeMux()
+	server.HandleFunc(livenessHandler.Path, livenessHandler.Live)
+	server.HandleFunc(readinessHandler.Path, readinessHandler.Ready)
+	server.HandleFunc(registrationHandler.Path, registrationHandler.Register)
+	server.HandleFunc(verificationHandler.Path, verificationHandler.Verify)
+	server.HandleFunc(verificationHandler.Path+"/resend", verificationHandler.Resend)
+	server.HandleFunc(passwordResetHandler.Path+"/forgot", passwordResetHandler.Forgot)
+	server.HandleFunc(passwordResetHandler.Path+"/reset", passwordResetHandler.Reset)
+	server.HandleFunc(loginHandler.Path, loginHandler.Login)
+	server.HandleFunc(tokenRefreshHandler.Path, tokenRefreshHandler.Refresh)
+	server.HandleFunc(logoutHandler.Path, logoutHandler.Logout)
+	server.HandleFunc(apiTokenHandler.Path, authenticate(users.RequireLoginToken(apiTokenHandler.Tokens)))
+	server.HandleFunc(apiTokenHandler.Path+"/", authenticate(users.RequireLoginToken(apiTokenHandler.Tokens)))
+	server.HandleFunc(profileHandler.Path, authenticate(users.RequireLoginToken(profileHandler.Profile)))
+	server.HandleFunc(profileHandler.Path+"/password", authenticate(users.RequireLoginToken(profileHandler.Password)))
+	server.HandleFunc(adminHandler.Path, authenticate(users.RequireRole(users.RoleAdmin, adminHandler.Users)))
+	server.HandleFunc(adminHandler.Path+"/", authenticate(users.RequireRole(users.RoleAdmin, adminHandler.Users)))
+	server.HandleFunc(failedLoginHandler.Path,
+		authenticate(users.RequireRole(users.RoleAdmin, failedLoginHandler.List)))
+	server.HandleFunc(newNodeHandler.Path, authenticateWrites(nodeScopes(newNodeHandler.New)))
+	server.HandleFunc(linkHandler.Path, authenticateWrites(nodeScopes(linkHandler.Links)))
+	server.HandleFunc(linkHandler.Path+"/", authenticateWrites(nodeScopes(linkHandler.Links)))
+	server.HandleFunc(routeHandler.Path, routeHandler.Route)
+	server.HandleFunc(searchHandler.Path, searchHandler.Search)
+	server.HandleFunc(countryHandler.Path, countryHandler.Countries)
+	server.HandleFunc(networkHandler.Path+".geojson", networkHandler.GeoJSON)
+	server.HandleFunc(networkHandler.Path+".dot", networkHandler.DOT)
+	server.HandleFunc(networkHandler.Path+".mmd", networkHandler.Mermaid)
+	server.HandleFunc(nodeHandler.Path, authenticateWrites(nodeScopes(func(writer http.ResponseWriter, request *http.Request) {
+		switch subresource(nodeHandler.Path, request.URL.Path) {
+		case "":
+			nodeHandler.Node(writer, request)
+		case "config":
+			configHandler.Config(writer, request)
+		case "owner", "maintainers":
+			ownershipHandler.Ownership(writer, request)
+		default:
+			apierror.NotFound(writer, "no such resource")
+		}
+	})))
+	server.HandleFunc(userNodesHandler.Path, userNodesHandler.Nodes)
+	server.HandleFunc(moderationHandler.Path,
+		authenticate(users.RequireRole(users.RoleModerator, moderationHandler.Nodes)))
+	server.HandleFunc(moderationHandler.Path+"/",
+		authenticate(users.RequireRole(users.RoleModerator, moderationHandler.Nodes)))
+	return server
+}
+
+// subresource returns the part of path following the resource name,
+// e.g. "config" for /node/DRNBRX1A/config.
+func subresource(prefix string, path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, prefix), "/", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/config"
+	"github.com/mvslovers/hnetdb/pkg/filestore"
+	"github.com/mvslovers/hnetdb/pkg/health"
+	"github.com/mvslovers/hnetdb/pkg/migrations"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"log"
+	"os"
+	"sync"
+)
+
+// storage bundles the repositories the handlers are wired with.
+type storage struct {
+	users     users.UserRepository
+	sessions  users.RefreshTokenRepository
+	apiTokens users.APITokenRepository
+	mailed    users.OneTimeTokenRepository
+	// logins keeps failed logins, in memory unless Neo4j is used.
+	logins    users.LoginAttemptRepository
+	nodes     nodes.NodeRepository
+	ownership nodes.OwnershipRepository
+	links     nodes.LinkRepository
+	search    nodes.NodeSearcher
+	// checks tell whether the storage is ready to serve requests.
+	checks []health.Check
+	// close releases connections when the server shuts down.
+	close func() error
+}
+
+// openStorage sets up the configured kind of storage: "neo4j" for a
+// Neo4j database, "file" for a single data file or "memory" for a
+// throwaway registry during development.
+func openStorage(settings *config.Config, logger *log.Logger) (*storage, error) {
+	switch settings.Storage.Kind {
+	case "neo4j":
+		return neo4jStorage(settings.Neo4j, logger)
+	case "file":
+		return fileStorage(settings.Storage.File)
+	case "memory":
+		return memoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q", settings.Storage.Kind)
+	}
+}
+
+// commandStorage opens the storage for commands run next to the server.
+// Memory storage is rejected as it only lives inside the server.
+func commandStorage(configFile string, storageKind string, dataFile string) (*storage, error) {
+	settings, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if storageKind != "" {
+		settings.Storage.Kind = storageKind
+	}
+	if dataFile != "" {
+		settings.Storage.File = dataFile
+	}
+	if settings.Storage.Kind == "memory" {
+		return nil, errors.New("memory storage only lives inside the server, use neo4j or file storage")
+	}
+	return openStorage(settings, log.New(os.Stderr, "", log.LstdFlags))
+}
+
+func neo4jStorage(settings config.Neo4j, logger *log.Logger) (*storage, error) {
+	driver, err := openDriver(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &schema{
+		driver:  driver,
+		runner:  &migrations.Runner{Driver: driver, Migrations: migrations.Builtin},
+		migrate: settings.Migrate,
+		logger:  logger,
+	}
+	// An unreachable database is not fatal, /readyz reports it and
+	// migrates the schema once Neo4j is up.
+	if err := schema.check(); err != nil {
+		logger.Printf("schema not ready, see /readyz: %v", err)
+	}
+
+	usersRepository := &users.UserNeo4jRepository{Driver: driver}
+	nodesRepository := &nodes.NodeNeo4jRepository{Driver: driver}
+	return &storage{
+		users:     usersRepository,
+		sessions:  usersRepository,
+		apiTokens: usersRepository,
+		mailed:    usersRepository,
+		logins:    &users.LoginAttemptNeo4jRepository{Driver: driver},
+		nodes:     nodesRepository,
+		ownership: nodesRepository,
+		links:     &nodes.LinkNeo4jRepository{Driver: driver},
+		search:    nodesRepository,
+		checks: []health.Check{
+			{Name: "neo4j", Run: driver.VerifyConnectivity},
+			{Name: "migrations", Run: schema.check},
+		},
+		close: driver.Close,
+	}, nil
+}
+
+// schema keeps the schema of a Neo4j database up to date.
+type schema struct {
+	driver neo4j.Driver
+	runner *migrations.Runner
+	// migrate applies pending migrations, otherwise they are reported.
+	migrate bool
+	logger  *log.Logger
+	// mutex keeps concurrent readiness checks from migrating twice.
+	mutex sync.Mutex
+}
+
+// check fails if the schema is not up to date, after applying pending
+// migrations if migrate is set. Connectivity is checked first since the
+// driver retries queries against an unreachable database for half a
+// minute.
+func (s *schema) check() error {
+	if err := s.driver.VerifyConnectivity(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pending, err := s.runner.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if !s.migrate {
+		return fmt.Errorf("%d schema migrations pending, run hnetdb migrate up", len(pending))
+	}
+	applied, err := s.runner.Up()
+	for _, migration := range applied {
+		s.logger.Printf("applied migration %s: %s", migration.ID(), migration.Description)
+	}
+	return err
+}
+
+func openDriver(settings config.Neo4j) (neo4j.Driver, error) {
+	driver, err := neo4j.NewDriver(settings.URI, neo4j.BasicAuth(settings.Username, settings.Password, ""))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", settings.URI, err)
+	}
+	return driver, nil
+}
+
+func fileStorage(path string) (*storage, error) {
+	store, err := filestore.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	return &storage{
+		users:     store.Users(),
+		sessions:  store.Users(),
+		apiTokens: store.Users(),
+		mailed:    store.Users(),
+		logins:    &users.LoginAttemptMemoryRepository{},
+		nodes:     store.Nodes(),
+		ownership: store.Nodes(),
+		links:     store.Links(),
+		search:    store.Nodes(),
+		close:     func() error { return nil },
+	}, nil
+}
+
+func memoryStorage() *storage {
+	usersRepository := &users.UserMemoryRepository{}
+	graph := nodes.NewMemoryGraph()
+	graph.UserExists = usersRepository.Exists
+	graph.OwnerContact = nodes.OwnerContactsFrom(usersRepository)
+	nodesRepository := &nodes.NodeMemoryRepository{Graph: graph}
+
+	return &storage{
+		users:     usersRepository,
+		sessions:  usersRepository,
+		apiTokens: usersRepository,
+		mailed:    usersRepository,
+		logins:    &users.LoginAttemptMemoryRepository{},
+		nodes:     nodesRepository,
+		ownership: nodesRepository,
+		links:     &nodes.LinkMemoryRepository{Graph: graph},
+		search:    nodesRepository,
+		close:     func() error { return nil },
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/config"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"time"
+)
+
+// addressFactor is how many more attempts a client address gets than an
+// account, since several users may log in from behind one NAT.
+const addressFactor = 5
+
+// loginThrottle sets up the brute-force protection of logins.
+func loginThrottle(settings config.Login, repository users.LoginAttemptRepository) *users.LoginThrottle {
+	lockout := time.Duration(settings.Lockout)
+	return &users.LoginThrottle{
+		Repository: repository,
+		Account: users.ThrottlePolicy{
+			FreeAttempts: settings.FreeAttempts,
+			BaseDelay:    time.Second,
+			MaxDelay:     lockout,
+			LockoutAfter: settings.LockoutAfter,
+			Lockout:      lockout,
+		},
+		Address: users.ThrottlePolicy{
+			FreeAttempts: settings.FreeAttempts * addressFactor,
+			BaseDelay:    time.Second,
+			MaxDelay:     lockout,
+			LockoutAfter: settings.LockoutAfter * addressFactor,
+			Lockout:      lockout,
+		},
+		// Failures are remembered past the end of a lockout, so that the
+		// next one locks out again.
+		ForgetAfter: 4 * lockout,
+		TrustProxy:  settings.TrustProxy,
+	}
+}
+
+// mailThrottle limits the verification and password reset mails anybody
+// may request, so that nobody is flooded with them. Requests are only
+// counted in memory, that is enough for mails.
+func mailThrottle(settings config.Login) *users.LoginThrottle {
+	return &users.LoginThrottle{
+		Repository: &users.LoginAttemptMemoryRepository{},
+		Account: users.ThrottlePolicy{
+			FreeAttempts: 3,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+		},
+		Address: users.ThrottlePolicy{
+			FreeAttempts: 3 * addressFactor,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+		},
+		ForgetAfter: 24 * time.Hour,
+		TrustProxy:  settings.TrustProxy,
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"os"
+)
+
+// user implements "hnetdb user [-config file] [-storage kind] [-data file] promote username role",
+// which sets roles from the shell. Only admins may do that over the API,
+// so this is how the first one comes to be.
+func user(args []string) {
+	flags := flag.NewFlagSet("user", flag.ExitOnError)
+	configFile := configFlag(flags)
+	storageKind := flags.String("storage", "", "where the registry is kept: neo4j or file")
+	dataFile := flags.String("data", "", "data file for -storage=file, the server must not be running")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: hnetdb user [-config file] [-storage kind] [-data file] promote username member|moderator|admin")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 3 || flags.Arg(0) != "promote" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	username, role := flags.Arg(1), users.Role(flags.Arg(2))
+
+	store, err := commandStorage(*configFile, *storageKind, *dataFile)
+	if err != nil {
+		fail(err)
+	}
+	err = users.Promote(store.users, &users.Sessions{Repository: store.sessions}, username, role)
+	closeErr := store.close()
+	if errors.Is(err, users.ErrUserNotFound) {
+		fail(fmt.Errorf("no user called %q, it has to register first", username))
+	}
+	if err != nil {
+		fail(err)
+	}
+	if closeErr != nil {
+		fail(closeErr)
+	}
+	fmt.Printf("%s is %s now\n", username, role)
+}
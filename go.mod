@@ -9,4 +9,5 @@ require (
 	github.com/onsi/gomega v1.10.5
 	github.com/testcontainers/testcontainers-go v0.9.0
 	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,96 @@
+// Package apierror writes the JSON error responses shared by all
+// handlers. Every error response has the body
+//
+//	{"error": {"code": "email_taken", "message": "...", "fields": [...]}}
+//
+// Code is meant for programs and never changes, message is meant for
+// humans. Fields lists the rejected attributes of invalid requests.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Codes used by more than one handler.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeValidationFailed = "validation_failed"
+	CodeInternal         = "internal_error"
+)
+
+type Error struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError tells why an attribute of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Envelope is the body of error responses.
+type Envelope struct {
+	Error *Error `json:"error"`
+}
+
+// Write answers with status and an error body.
+func Write(writer http.ResponseWriter, status int, code string, message string) {
+	WriteError(writer, status, &Error{Code: code, Message: message})
+}
+
+func WriteError(writer http.ResponseWriter, status int, e *Error) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	bytes, _ := json.Marshal(&Envelope{Error: e})
+	_, _ = writer.Write(bytes)
+}
+
+func BadRequest(writer http.ResponseWriter, message string) {
+	Write(writer, http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// MalformedJSON rejects request bodies that cannot be decoded.
+func MalformedJSON(writer http.ResponseWriter) {
+	BadRequest(writer, "request body is not valid JSON")
+}
+
+// Unauthorized asks for a bearer token.
+func Unauthorized(writer http.ResponseWriter) {
+	writer.Header().Set("WWW-Authenticate", "Bearer")
+	Write(writer, http.StatusUnauthorized, CodeUnauthorized, "authentication required")
+}
+
+func Forbidden(writer http.ResponseWriter, message string) {
+	Write(writer, http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(writer http.ResponseWriter, message string) {
+	Write(writer, http.StatusNotFound, CodeNotFound, message)
+}
+
+func MethodNotAllowed(writer http.ResponseWriter) {
+	Write(writer, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed")
+}
+
+// Invalid rejects a request with the given field errors.
+func Invalid(writer http.ResponseWriter, fields ...FieldError) {
+	WriteError(writer, http.StatusUnprocessableEntity, &Error{
+		Code:    CodeValidationFailed,
+		Message: "request has invalid fields",
+		Fields:  fields,
+	})
+}
+
+// Internal reports a failure of the server. The cause is not passed on
+// to clients.
+func Internal(writer http.ResponseWriter) {
+	Write(writer, http.StatusInternalServerError, CodeInternal, "internal server error")
+}
@@ -0,0 +1,13 @@
+package apierror_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestApierror(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Apierror Suite")
+}
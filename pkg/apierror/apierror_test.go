@@ -0,0 +1,45 @@
+package apierror_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+)
+
+var _ = Describe("Error responses", func() {
+
+	It("wraps errors in an envelope", func() {
+		recorder := httptest.NewRecorder()
+
+		apierror.Write(recorder, 409, "email_taken", "email address is already registered")
+
+		Expect(recorder.Code).To(Equal(409))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(MatchJSON(
+			`{"error": {"code": "email_taken", "message": "email address is already registered"}}`))
+	})
+
+	It("lists invalid fields", func() {
+		recorder := httptest.NewRecorder()
+
+		apierror.Invalid(recorder, apierror.FieldError{Field: "email", Message: "is not an email address"})
+
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"error": {
+			"code": "validation_failed",
+			"message": "request has invalid fields",
+			"fields": [{"field": "email", "message": "is not an email address"}]
+		}}`))
+	})
+
+	It("asks for a bearer token", func() {
+		recorder := httptest.NewRecorder()
+
+		apierror.Unauthorized(recorder)
+
+		Expect(recorder.Code).To(Equal(401))
+		Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"unauthorized"`))
+	})
+})
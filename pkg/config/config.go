@@ -0,0 +1,362 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config holds the settings of the hnetdb server. It is read from a YAML
+// file, environment variables and command line flags, in that order of
+// precedence from low to high.
+type Config struct {
+	Listen string `yaml:"listen"`
+	// ShutdownDelay is how long the server keeps serving while /readyz
+	// reports that it is stopping, before it stops to accept requests.
+	// It has to be long enough for load balancers to notice, e.g. the
+	// probe interval times the failures tolerated.
+	ShutdownDelay Duration `yaml:"shutdown_delay"`
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// once the server has been asked to stop.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout"`
+	TLS             TLS      `yaml:"tls"`
+	Storage         Storage  `yaml:"storage"`
+	Neo4j           Neo4j    `yaml:"neo4j"`
+	JWT             JWT      `yaml:"jwt"`
+	CORS            CORS     `yaml:"cors"`
+	Log             Log      `yaml:"log"`
+	Mail            Mail     `yaml:"mail"`
+	Login           Login    `yaml:"login"`
+	// PublicURL is where users reach the web frontend; links in mails
+	// point there.
+	PublicURL string `yaml:"public_url"`
+}
+
+// TLS turns on HTTPS when both files are given.
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+type Storage struct {
+	// Kind is neo4j, file or memory.
+	Kind string `yaml:"kind"`
+	// File is the data file of the file storage.
+	File string `yaml:"file"`
+}
+
+type Neo4j struct {
+	URI      string `yaml:"uri"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Migrate applies pending builtin migrations when the server starts,
+	// which creates the uniqueness constraints on a fresh database. Without
+	// it, "hnetdb migrate up" has to be run before the server is ready.
+	Migrate bool `yaml:"migrate"`
+}
+
+type JWT struct {
+	Secret string   `yaml:"secret"`
+	TTL    Duration `yaml:"ttl"`
+	// RefreshTTL is how long a login session lasts without being used.
+	RefreshTTL Duration `yaml:"refresh_ttl"`
+}
+
+type CORS struct {
+	// AllowedOrigins lists the origins browsers may call the API from,
+	// "*" allows all. CORS is off if empty.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type Log struct {
+	// Level is info or debug; debug also logs every request.
+	Level string `yaml:"level"`
+	// File is appended to instead of writing to standard error.
+	File string `yaml:"file"`
+}
+
+type Mail struct {
+	// Kind is smtp, or log to only log mails during development.
+	Kind string `yaml:"kind"`
+	From string `yaml:"from"`
+	SMTP SMTP   `yaml:"smtp"`
+	// File is appended to instead of the server log for the log kind.
+	File string `yaml:"file"`
+}
+
+type SMTP struct {
+	// Address is host:port of the mail server.
+	Address  string `yaml:"address"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Login limits password guessing. Client addresses get five times the
+// attempts of an account, as several users may share one.
+type Login struct {
+	// FreeAttempts is how many failed logins cost nothing; after that the
+	// wait before the next attempt doubles with every failure.
+	FreeAttempts int `yaml:"free_attempts"`
+	// LockoutAfter failures, logins are refused for Lockout.
+	LockoutAfter int      `yaml:"lockout_after"`
+	Lockout      Duration `yaml:"lockout"`
+	// TrustProxy takes client addresses from X-Forwarded-For; only set it
+	// behind a reverse proxy that sets the header.
+	TrustProxy bool `yaml:"trust_proxy"`
+}
+
+// Duration is a time.Duration written like "15m" in YAML.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var text string
+	if err := unmarshal(&text); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// minimumSecretLength keeps people from signing tokens with "secret".
+const minimumSecretLength = 32
+
+// Default returns the settings used for everything not configured.
+func Default() *Config {
+	return &Config{
+		Listen:          ":3000",
+		ShutdownDelay:   Duration(15 * time.Second),
+		ShutdownTimeout: Duration(30 * time.Second),
+		Storage: Storage{
+			Kind: "neo4j",
+			File: "hnetdb.json",
+		},
+		Neo4j: Neo4j{
+			Migrate: true,
+		},
+		JWT: JWT{
+			TTL:        Duration(15 * time.Minute),
+			RefreshTTL: Duration(30 * 24 * time.Hour),
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Mail: Mail{
+			Kind: "log",
+		},
+		Login: Login{
+			FreeAttempts: 3,
+			LockoutAfter: 10,
+			Lockout:      Duration(15 * time.Minute),
+		},
+	}
+}
+
+// Load reads the YAML file at path on top of the defaults and applies
+// the environment overrides. path may be empty to only use the
+// environment.
+func Load(path string) (*Config, error) {
+	config := Default()
+	if path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(content, config); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := config.applyEnvironment(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// environment maps variables to the settings they override. NEO4J_* and
+// SECRET_ACCESS are kept from the times before the configuration file.
+var environment = []struct {
+	name    string
+	setting func(c *Config) *string
+}{
+	{"HNETDB_LISTEN", func(c *Config) *string { return &c.Listen }},
+	{"HNETDB_TLS_CERT_FILE", func(c *Config) *string { return &c.TLS.CertFile }},
+	{"HNETDB_TLS_KEY_FILE", func(c *Config) *string { return &c.TLS.KeyFile }},
+	{"HNETDB_STORAGE", func(c *Config) *string { return &c.Storage.Kind }},
+	{"HNETDB_DATA_FILE", func(c *Config) *string { return &c.Storage.File }},
+	{"NEO4J_URI", func(c *Config) *string { return &c.Neo4j.URI }},
+	{"NEO4J_USERNAME", func(c *Config) *string { return &c.Neo4j.Username }},
+	{"NEO4J_PASSWORD", func(c *Config) *string { return &c.Neo4j.Password }},
+	{"SECRET_ACCESS", func(c *Config) *string { return &c.JWT.Secret }},
+	{"HNETDB_LOG_LEVEL", func(c *Config) *string { return &c.Log.Level }},
+	{"HNETDB_LOG_FILE", func(c *Config) *string { return &c.Log.File }},
+	{"HNETDB_MAIL_KIND", func(c *Config) *string { return &c.Mail.Kind }},
+	{"HNETDB_MAIL_FROM", func(c *Config) *string { return &c.Mail.From }},
+	{"HNETDB_MAIL_FILE", func(c *Config) *string { return &c.Mail.File }},
+	{"HNETDB_SMTP_ADDRESS", func(c *Config) *string { return &c.Mail.SMTP.Address }},
+	{"HNETDB_SMTP_USERNAME", func(c *Config) *string { return &c.Mail.SMTP.Username }},
+	{"HNETDB_SMTP_PASSWORD", func(c *Config) *string { return &c.Mail.SMTP.Password }},
+	{"HNETDB_PUBLIC_URL", func(c *Config) *string { return &c.PublicURL }},
+}
+
+func (c *Config) applyEnvironment(lookup func(string) (string, bool)) error {
+	for _, variable := range environment {
+		if value, ok := lookup(variable.name); ok {
+			*variable.setting(c) = value
+		}
+	}
+	for name, setting := range map[string]*Duration{
+		"HNETDB_JWT_TTL":         &c.JWT.TTL,
+		"HNETDB_JWT_REFRESH_TTL": &c.JWT.RefreshTTL,
+		"HNETDB_LOGIN_LOCKOUT":   &c.Login.Lockout,
+		"HNETDB_SHUTDOWN_DELAY":  &c.ShutdownDelay,
+	} {
+		if value, ok := lookup(name); ok {
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*setting = Duration(ttl)
+		}
+	}
+	if value, ok := lookup("HNETDB_CORS_ALLOWED_ORIGINS"); ok {
+		c.CORS.AllowedOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.CORS.AllowedOrigins = append(c.CORS.AllowedOrigins, origin)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidationError lists everything that is wrong with a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate checks the configuration as a whole and reports all problems
+// at once.
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		problem("listen: %q is not a host:port address", c.Listen)
+	}
+	if c.ShutdownDelay < 0 {
+		problem("shutdown_delay: must not be negative")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problem("shutdown_timeout: has to be positive")
+	}
+
+	if c.TLS.Enabled() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			problem("tls: cert_file and key_file have to be given together")
+		}
+		for _, file := range []string{c.TLS.CertFile, c.TLS.KeyFile} {
+			if file == "" {
+				continue
+			}
+			if _, err := os.Stat(file); err != nil {
+				problem("tls: %v", err)
+			}
+		}
+	}
+
+	switch c.Storage.Kind {
+	case "neo4j":
+		if c.Neo4j.URI == "" {
+			problem("neo4j.uri: required for neo4j storage (or set NEO4J_URI)")
+		} else if _, err := url.Parse(c.Neo4j.URI); err != nil {
+			problem("neo4j.uri: %v", err)
+		}
+		if c.Neo4j.Username == "" {
+			problem("neo4j.username: required for neo4j storage (or set NEO4J_USERNAME)")
+		}
+	case "file":
+		if c.Storage.File == "" {
+			problem("storage.file: required for file storage")
+		}
+	case "memory":
+	default:
+		problem("storage.kind: %q is not one of neo4j, file or memory", c.Storage.Kind)
+	}
+
+	if len(c.JWT.Secret) < minimumSecretLength {
+		problem("jwt.secret: at least %d characters required (or set SECRET_ACCESS)", minimumSecretLength)
+	}
+	if c.JWT.TTL <= 0 {
+		problem("jwt.ttl: has to be positive")
+	}
+	if c.JWT.RefreshTTL < c.JWT.TTL {
+		problem("jwt.refresh_ttl: must not be shorter than jwt.ttl")
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Path != "" {
+			problem("cors.allowed_origins: %q is not an origin like https://example.org", origin)
+		}
+	}
+
+	if c.Log.Level != "info" && c.Log.Level != "debug" {
+		problem("log.level: %q is not one of info or debug", c.Log.Level)
+	}
+
+	switch c.Mail.Kind {
+	case "smtp":
+		if _, _, err := net.SplitHostPort(c.Mail.SMTP.Address); err != nil {
+			problem("mail.smtp.address: %q is not a host:port address", c.Mail.SMTP.Address)
+		}
+		if c.Mail.From == "" {
+			problem("mail.from: required for smtp mail")
+		}
+	case "log":
+	default:
+		problem("mail.kind: %q is not one of smtp or log", c.Mail.Kind)
+	}
+
+	if c.Login.FreeAttempts < 0 {
+		problem("login.free_attempts: must not be negative")
+	}
+	if c.Login.LockoutAfter <= c.Login.FreeAttempts {
+		problem("login.lockout_after: has to be more than login.free_attempts")
+	}
+	if c.Login.Lockout <= 0 {
+		problem("login.lockout: has to be positive")
+	}
+
+	if c.PublicURL != "" {
+		parsed, err := url.Parse(c.PublicURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problem("public_url: %q is not an http or https URL", c.PublicURL)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
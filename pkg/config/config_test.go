@@ -0,0 +1,134 @@
+package config_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("Config", func() {
+
+	const secret = "0123456789abcdef0123456789abcdef"
+
+	var dir string
+	var variables = []string{
+		"HNETDB_LISTEN", "HNETDB_STORAGE", "HNETDB_JWT_TTL", "HNETDB_JWT_REFRESH_TTL", "HNETDB_CORS_ALLOWED_ORIGINS",
+		"NEO4J_URI", "NEO4J_USERNAME", "NEO4J_PASSWORD", "SECRET_ACCESS",
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "config")
+		Expect(err).To(BeNil(), "Directory should be created")
+		for _, variable := range variables {
+			Expect(os.Unsetenv(variable)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		for _, variable := range variables {
+			Expect(os.Unsetenv(variable)).To(Succeed())
+		}
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	write := func(content string) string {
+		path := filepath.Join(dir, "hnetdb.yaml")
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	It("reads the configuration file on top of the defaults", func() {
+		path := write(`
+listen: "127.0.0.1:8080"
+shutdown_delay: 0s
+neo4j:
+  uri: bolt://localhost:7687
+  username: neo4j
+  password: s3cr3t
+jwt:
+  secret: ` + secret + `
+  ttl: 30m
+cors:
+  allowed_origins: ["https://hnet.example.org"]
+login:
+  lockout: 1h
+  trust_proxy: true
+`)
+
+		settings, err := config.Load(path)
+
+		Expect(err).To(BeNil())
+		Expect(settings.Listen).To(Equal("127.0.0.1:8080"))
+		Expect(settings.ShutdownDelay).To(BeZero())
+		Expect(time.Duration(settings.ShutdownTimeout)).To(Equal(30 * time.Second))
+		Expect(settings.Storage.Kind).To(Equal("neo4j"))
+		Expect(settings.Neo4j.URI).To(Equal("bolt://localhost:7687"))
+		Expect(settings.Neo4j.Migrate).To(BeTrue())
+		Expect(time.Duration(settings.JWT.TTL)).To(Equal(30 * time.Minute))
+		Expect(settings.CORS.AllowedOrigins).To(Equal([]string{"https://hnet.example.org"}))
+		Expect(settings.Log.Level).To(Equal("info"))
+		Expect(settings.Login.FreeAttempts).To(Equal(3))
+		Expect(time.Duration(settings.Login.Lockout)).To(Equal(time.Hour))
+		Expect(settings.Login.TrustProxy).To(BeTrue())
+		Expect(settings.Validate()).To(Succeed())
+	})
+
+	It("lets the environment override the file", func() {
+		path := write("listen: \":8080\"\njwt:\n  ttl: 30m\n")
+		Expect(os.Setenv("HNETDB_LISTEN", ":9090")).To(Succeed())
+		Expect(os.Setenv("HNETDB_JWT_TTL", "5m")).To(Succeed())
+		Expect(os.Setenv("HNETDB_JWT_REFRESH_TTL", "24h")).To(Succeed())
+		Expect(os.Setenv("HNETDB_CORS_ALLOWED_ORIGINS", "https://a.example.org, https://b.example.org")).
+			To(Succeed())
+		Expect(os.Setenv("SECRET_ACCESS", secret)).To(Succeed())
+
+		settings, err := config.Load(path)
+
+		Expect(err).To(BeNil())
+		Expect(settings.Listen).To(Equal(":9090"))
+		Expect(time.Duration(settings.JWT.TTL)).To(Equal(5 * time.Minute))
+		Expect(time.Duration(settings.JWT.RefreshTTL)).To(Equal(24 * time.Hour))
+		Expect(settings.CORS.AllowedOrigins).To(Equal([]string{"https://a.example.org", "https://b.example.org"}))
+		Expect(settings.JWT.Secret).To(Equal(secret))
+	})
+
+	It("rejects unknown settings", func() {
+		path := write("listen: \":8080\"\nlisten_address: \":8080\"\n")
+
+		_, err := config.Load(path)
+
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("reports all problems at once", func() {
+		settings := config.Default()
+		settings.Listen = "3000"
+		settings.ShutdownDelay = config.Duration(-time.Second)
+		settings.TLS.CertFile = filepath.Join(dir, "missing.pem")
+		settings.JWT.Secret = "secret"
+		settings.JWT.RefreshTTL = config.Duration(time.Minute)
+		settings.CORS.AllowedOrigins = []string{"hnet.example.org"}
+		settings.Log.Level = "verbose"
+		settings.Mail.Kind = "smtp"
+		settings.PublicURL = "hnet.example.org"
+		settings.Login.LockoutAfter = 2
+
+		err := settings.Validate()
+
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems).To(HaveLen(14))
+	})
+
+	It("only needs a secret for memory storage", func() {
+		settings := config.Default()
+		settings.Storage.Kind = "memory"
+		settings.JWT.Secret = secret
+
+		Expect(settings.Validate()).To(Succeed())
+	})
+})
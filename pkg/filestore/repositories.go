@@ -0,0 +1,277 @@
+package filestore
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/mvslovers/hnetdb/pkg/users"
+)
+
+// UserRepository implements users.UserRepository and the token
+// repositories of the users package on top of a Store.
+type UserRepository struct {
+	store *Store
+}
+
+func (u *UserRepository) RegisterUser(user *users.User) error {
+	return u.store.update(func() error {
+		return u.store.users.RegisterUser(user)
+	})
+}
+
+func (u *UserRepository) FindByEmailAndPassword(email string, password string) (*users.User, error) {
+	return u.store.users.FindByEmailAndPassword(email, password)
+}
+
+func (u *UserRepository) FindByUsername(username string) (*users.User, error) {
+	return u.store.users.FindByUsername(username)
+}
+
+func (u *UserRepository) FindByEmail(email string) (*users.User, error) {
+	return u.store.users.FindByEmail(email)
+}
+
+func (u *UserRepository) FindAll() ([]*users.User, error) {
+	return u.store.users.FindAll()
+}
+
+func (u *UserRepository) UpdateRole(username string, role users.Role) error {
+	return u.store.update(func() error {
+		return u.store.users.UpdateRole(username, role)
+	})
+}
+
+func (u *UserRepository) SetLocked(username string, locked bool) error {
+	return u.store.update(func() error {
+		return u.store.users.SetLocked(username, locked)
+	})
+}
+
+func (u *UserRepository) SetVerified(username string) error {
+	return u.store.update(func() error {
+		return u.store.users.SetVerified(username)
+	})
+}
+
+func (u *UserRepository) UpdatePassword(username string, password string) error {
+	return u.store.update(func() error {
+		return u.store.users.UpdatePassword(username, password)
+	})
+}
+
+func (u *UserRepository) Rename(username string, newUsername string) error {
+	return u.store.update(func() error {
+		return u.store.users.Rename(username, newUsername)
+	})
+}
+
+func (u *UserRepository) UpdateEmail(username string, email string) error {
+	return u.store.update(func() error {
+		return u.store.users.UpdateEmail(username, email)
+	})
+}
+
+func (u *UserRepository) UpdateContactDetails(username string, details users.ContactDetails) error {
+	return u.store.update(func() error {
+		return u.store.users.UpdateContactDetails(username, details)
+	})
+}
+
+func (u *UserRepository) UpdateProfile(username string, change users.ProfileChange) error {
+	return u.store.update(func() error {
+		return u.store.users.UpdateProfile(username, change)
+	})
+}
+
+func (u *UserRepository) DeleteUser(username string) error {
+	return u.store.update(func() error {
+		return u.store.users.DeleteUser(username)
+	})
+}
+
+func (u *UserRepository) SaveRefreshToken(token *users.RefreshToken) error {
+	return u.store.update(func() error {
+		return u.store.users.SaveRefreshToken(token)
+	})
+}
+
+func (u *UserRepository) FindRefreshToken(id string) (*users.RefreshToken, error) {
+	return u.store.users.FindRefreshToken(id)
+}
+
+func (u *UserRepository) RotateRefreshToken(token *users.RefreshToken, previousHash string) error {
+	return u.store.update(func() error {
+		return u.store.users.RotateRefreshToken(token, previousHash)
+	})
+}
+
+func (u *UserRepository) DeleteRefreshToken(id string) error {
+	return u.store.update(func() error {
+		return u.store.users.DeleteRefreshToken(id)
+	})
+}
+
+func (u *UserRepository) DeleteRefreshTokens(username string) error {
+	return u.store.update(func() error {
+		return u.store.users.DeleteRefreshTokens(username)
+	})
+}
+
+func (u *UserRepository) SaveAPIToken(token *users.APIToken) error {
+	return u.store.update(func() error {
+		return u.store.users.SaveAPIToken(token)
+	})
+}
+
+func (u *UserRepository) FindAPIToken(id string) (*users.APIToken, error) {
+	return u.store.users.FindAPIToken(id)
+}
+
+func (u *UserRepository) FindAPITokens(username string) ([]*users.APIToken, error) {
+	return u.store.users.FindAPITokens(username)
+}
+
+func (u *UserRepository) DeleteAPIToken(username string, id string) error {
+	return u.store.update(func() error {
+		return u.store.users.DeleteAPIToken(username, id)
+	})
+}
+
+func (u *UserRepository) SaveOneTimeToken(token *users.OneTimeToken) error {
+	return u.store.update(func() error {
+		return u.store.users.SaveOneTimeToken(token)
+	})
+}
+
+func (u *UserRepository) TakeOneTimeToken(purpose users.TokenPurpose, hash string) (token *users.OneTimeToken, err error) {
+	err = u.store.update(func() error {
+		token, err = u.store.users.TakeOneTimeToken(purpose, hash)
+		return err
+	})
+	return token, err
+}
+
+// NodeRepository implements nodes.NodeRepository and
+// nodes.OwnershipRepository on top of a Store.
+type NodeRepository struct {
+	store *Store
+}
+
+func (n *NodeRepository) memory() *nodes.NodeMemoryRepository {
+	return &nodes.NodeMemoryRepository{Graph: n.store.graph}
+}
+
+func (n *NodeRepository) Save(node *nodes.Node) error {
+	return n.store.update(func() error {
+		return n.memory().Save(node)
+	})
+}
+
+func (n *NodeRepository) FindAll() ([]*nodes.Node, error) {
+	return n.memory().FindAll()
+}
+
+func (n *NodeRepository) FindByName(name string) (*nodes.Node, error) {
+	return n.memory().FindByName(name)
+}
+
+func (n *NodeRepository) DeleteByName(name string) error {
+	return n.store.update(func() error {
+		return n.memory().DeleteByName(name)
+	})
+}
+
+func (n *NodeRepository) Update(name string, node *nodes.Node) error {
+	return n.store.update(func() error {
+		return n.memory().Update(name, node)
+	})
+}
+
+func (n *NodeRepository) FindAllByState(state nodes.State) ([]*nodes.Node, error) {
+	return n.memory().FindAllByState(state)
+}
+
+func (n *NodeRepository) Query(query *nodes.NodeQuery) (*nodes.NodePage, error) {
+	return n.memory().Query(query)
+}
+
+func (n *NodeRepository) Search(search *nodes.NodeSearch) ([]*nodes.SearchResult, error) {
+	return n.memory().Search(search)
+}
+
+func (n *NodeRepository) SetState(name string, state nodes.State, reason string) error {
+	return n.store.update(func() error {
+		return n.memory().SetState(name, state, reason)
+	})
+}
+
+func (n *NodeRepository) TransferOwnership(name string, username string) error {
+	return n.store.update(func() error {
+		return n.memory().TransferOwnership(name, username)
+	})
+}
+
+func (n *NodeRepository) AddMaintainer(name string, username string) error {
+	return n.store.update(func() error {
+		return n.memory().AddMaintainer(name, username)
+	})
+}
+
+func (n *NodeRepository) RemoveMaintainer(name string, username string) error {
+	return n.store.update(func() error {
+		return n.memory().RemoveMaintainer(name, username)
+	})
+}
+
+func (n *NodeRepository) FindByUser(username string) ([]*nodes.Node, error) {
+	return n.memory().FindByUser(username)
+}
+
+func (n *NodeRepository) RenameUser(username string, newUsername string) error {
+	return n.store.update(func() error {
+		return n.memory().RenameUser(username, newUsername)
+	})
+}
+
+func (n *NodeRepository) ReleaseUser(username string) error {
+	return n.store.update(func() error {
+		return n.memory().ReleaseUser(username)
+	})
+}
+
+// LinkRepository implements nodes.LinkRepository on top of a Store.
+type LinkRepository struct {
+	store *Store
+}
+
+func (l *LinkRepository) memory() *nodes.LinkMemoryRepository {
+	return &nodes.LinkMemoryRepository{Graph: l.store.graph}
+}
+
+func (l *LinkRepository) Save(link *nodes.Link) error {
+	return l.store.update(func() error {
+		return l.memory().Save(link)
+	})
+}
+
+func (l *LinkRepository) FindAll() ([]*nodes.Link, error) {
+	return l.memory().FindAll()
+}
+
+func (l *LinkRepository) FindByNodes(from string, to string) (*nodes.Link, error) {
+	return l.memory().FindByNodes(from, to)
+}
+
+func (l *LinkRepository) Update(link *nodes.Link) error {
+	return l.store.update(func() error {
+		return l.memory().Update(link)
+	})
+}
+
+func (l *LinkRepository) DeleteByNodes(from string, to string) error {
+	return l.store.update(func() error {
+		return l.memory().DeleteByNodes(from, to)
+	})
+}
+
+func (l *LinkRepository) FindRoute(from string, to string, options nodes.RouteOptions) (*nodes.Route, error) {
+	return l.memory().FindRoute(from, to, options)
+}
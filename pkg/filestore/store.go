@@ -0,0 +1,178 @@
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// formatVersion is written to every data file so that later releases
+// can tell old layouts apart. Version 2 added email verification.
+const formatVersion = 2
+
+type data struct {
+	Version       int                   `json:"version"`
+	Users         []*users.User         `json:"users"`
+	RefreshTokens []*users.RefreshToken `json:"refresh_tokens"`
+	APITokens     []*users.APIToken     `json:"api_tokens"`
+	OneTimeTokens []*users.OneTimeToken `json:"one_time_tokens"`
+	Graph         *nodes.GraphData      `json:"graph"`
+}
+
+// Store keeps the whole registry in memory and in a single JSON file.
+// Every change rewrites the file atomically: the new content goes to a
+// temporary file next to it, which is synced and then renamed over the
+// old one, so a crash leaves either the old or the new registry behind
+// but never a torn one. This is meant for small registries of a few
+// hundred nodes; larger ones should use Neo4j.
+type Store struct {
+	path string
+
+	// mutex serializes changes so that the file always matches the
+	// in-memory state.
+	mutex sync.Mutex
+	users *users.UserMemoryRepository
+	graph *nodes.MemoryGraph
+}
+
+// Open loads the registry from path. A missing file is an empty
+// registry, it is created on the first change.
+func Open(path string) (*Store, error) {
+	store := &Store{
+		path:  path,
+		users: &users.UserMemoryRepository{},
+		graph: nodes.NewMemoryGraph(),
+	}
+	store.graph.UserExists = store.users.Exists
+	store.graph.OwnerContact = nodes.OwnerContactsFrom(store.users)
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded data
+	if err := json.Unmarshal(content, &loaded); err != nil {
+		return nil, err
+	}
+	switch loaded.Version {
+	case 1:
+		// Users registered before verification existed keep their
+		// rights, like in the Neo4j migration.
+		for _, user := range loaded.Users {
+			user.Verified = true
+		}
+	case formatVersion:
+	default:
+		return nil, errors.New("unsupported data file version")
+	}
+	store.restore(&loaded)
+	return store, nil
+}
+
+func (s *Store) Users() *UserRepository {
+	return &UserRepository{store: s}
+}
+
+func (s *Store) Nodes() *NodeRepository {
+	return &NodeRepository{store: s}
+}
+
+func (s *Store) Links() *LinkRepository {
+	return &LinkRepository{store: s}
+}
+
+// update applies change and writes the result to disk. If the file
+// cannot be written, the change is rolled back and the write error
+// returned.
+func (s *Store) update(change func() error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous := s.snapshot()
+	if err := change(); err != nil {
+		return err
+	}
+	if err := s.write(s.snapshot()); err != nil {
+		s.restore(previous)
+		return err
+	}
+	return nil
+}
+
+func (s *Store) snapshot() *data {
+	return &data{
+		Version:       formatVersion,
+		Users:         s.users.Export(),
+		RefreshTokens: s.users.ExportRefreshTokens(),
+		APITokens:     s.users.ExportAPITokens(),
+		OneTimeTokens: s.users.ExportOneTimeTokens(),
+		Graph:         s.graph.Export(),
+	}
+}
+
+func (s *Store) restore(d *data) {
+	s.users.Import(d.Users)
+	s.users.ImportRefreshTokens(d.RefreshTokens)
+	s.users.ImportAPITokens(d.APITokens)
+	s.users.ImportOneTimeTokens(d.OneTimeTokens)
+	if d.Graph != nil {
+		s.graph.Import(d.Graph)
+	}
+}
+
+func (s *Store) write(d *data) (err error) {
+	content, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temporary, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(temporary.Name())
+		}
+	}()
+
+	if _, err = temporary.Write(content); err != nil {
+		_ = temporary.Close()
+		return err
+	}
+	if err = temporary.Sync(); err != nil {
+		_ = temporary.Close()
+		return err
+	}
+	if err = temporary.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(temporary.Name(), 0600); err != nil {
+		return err
+	}
+	if err = os.Rename(temporary.Name(), s.path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(s.path))
+}
+
+// syncDir makes the rename of the data file durable.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dir.Close()
+	}()
+	return dir.Sync()
+}
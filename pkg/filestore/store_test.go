@@ -0,0 +1,121 @@
+package filestore_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/filestore"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var _ = Describe("Store", func() {
+
+	var dir string
+	var path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "filestore")
+		Expect(err).To(BeNil(), "Directory should be created")
+		path = filepath.Join(dir, "hnetdb.json")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("starts empty without a data file", func() {
+		store, err := filestore.Open(path)
+		Expect(err).To(BeNil())
+
+		all, err := store.Nodes().FindAll()
+		Expect(err).To(BeNil())
+		Expect(all).To(BeEmpty())
+		Expect(path).NotTo(BeAnExistingFile())
+	})
+
+	It("keeps the registry across restarts", func() {
+		store, err := filestore.Open(path)
+		Expect(err).To(BeNil())
+		Expect(store.Users().RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed())
+		for _, name := range []string{"DRNBRX1A", "DRNBRX2A"} {
+			Expect(store.Nodes().Save(&nodes.Node{
+				Name:            name,
+				Platform:        "Hercules 4 on Linux",
+				OperatingSystem: "MVS3.8J",
+				Owner:           "flo",
+				State:           nodes.StateApproved,
+			})).To(Succeed())
+		}
+		link := &nodes.Link{From: "DRNBRX1A", To: "DRNBRX2A", Transport: nodes.TransportCTC,
+			Host: "0E40", Status: nodes.LinkUp}
+		Expect(store.Links().Save(link)).To(Succeed())
+		Expect(store.Users().SaveRefreshToken(&users.RefreshToken{
+			ID:        "session",
+			Username:  "flo",
+			Hash:      "hash",
+			ExpiresAt: time.Now().Add(time.Hour),
+		})).To(Succeed())
+
+		reopened, err := filestore.Open(path)
+		Expect(err).To(BeNil())
+
+		user, err := reopened.Users().FindByEmailAndPassword("flo@example.org", "sup3rpassw0rd")
+		Expect(err).To(BeNil())
+		Expect(user.Username).To(Equal("flo"))
+		token, err := reopened.Users().FindRefreshToken("session")
+		Expect(err).To(BeNil())
+		Expect(token.Username).To(Equal("flo"))
+		node, err := reopened.Nodes().FindByName("DRNBRX1A")
+		Expect(err).To(BeNil())
+		Expect(node.Owner).To(Equal("flo"))
+		found, err := reopened.Links().FindByNodes("DRNBRX2A", "DRNBRX1A")
+		Expect(err).To(BeNil())
+		Expect(found).To(Equal(link))
+
+		files, err := ioutil.ReadDir(dir)
+		Expect(err).To(BeNil())
+		Expect(files).To(HaveLen(1), "No temporary files should be left")
+	})
+
+	It("rolls changes back that cannot be written", func() {
+		store, err := filestore.Open(path)
+		Expect(err).To(BeNil())
+		Expect(os.RemoveAll(dir)).To(Succeed())
+
+		err = store.Nodes().Save(&nodes.Node{Name: "DRNBRX1A"})
+
+		Expect(err).NotTo(BeNil())
+		_, err = store.Nodes().FindByName("DRNBRX1A")
+		Expect(err).To(Equal(nodes.ErrNodeNotFound))
+	})
+
+	It("keeps users of version 1 files verified", func() {
+		Expect(ioutil.WriteFile(path, []byte(`{"version": 1, "users": [`+
+			`{"username": "flo", "email": "flo@example.org", "password": "hash", "role": "member"}]}`),
+			0600)).To(Succeed())
+
+		store, err := filestore.Open(path)
+		Expect(err).To(BeNil())
+
+		user, err := store.Users().FindByUsername("flo")
+		Expect(err).To(BeNil())
+		Expect(user.Verified).To(BeTrue())
+	})
+
+	It("refuses unreadable data files", func() {
+		Expect(ioutil.WriteFile(path, []byte("{"), 0600)).To(Succeed())
+
+		_, err := filestore.Open(path)
+
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,68 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Check is one condition the server needs to serve requests, e.g. a
+// reachable database. Run returns why the condition is not met.
+type Check struct {
+	Name string
+	Run  func() error
+}
+
+// Status is the body of liveness and readiness responses. Checks maps
+// the names of failed checks to their errors.
+type Status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// LivenessHandler answers as long as the process is able to serve HTTP
+// at all, so supervisors only restart hnetdb when it hangs.
+type LivenessHandler struct {
+	Path string
+}
+
+func (h *LivenessHandler) Live(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" && request.Method != "HEAD" {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeStatus(writer, http.StatusOK, &Status{Status: "ok"})
+}
+
+// ReadinessHandler runs all checks and answers 503 if any of them fails,
+// so load balancers only send traffic to instances that can handle it.
+type ReadinessHandler struct {
+	Path   string
+	Checks []Check
+}
+
+func (h *ReadinessHandler) Ready(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" && request.Method != "HEAD" {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	failed := map[string]string{}
+	for _, check := range h.Checks {
+		if err := check.Run(); err != nil {
+			failed[check.Name] = err.Error()
+		}
+	}
+	if len(failed) > 0 {
+		writeStatus(writer, http.StatusServiceUnavailable, &Status{Status: "unavailable", Checks: failed})
+		return
+	}
+	writeStatus(writer, http.StatusOK, &Status{Status: "ready"})
+}
+
+func writeStatus(writer http.ResponseWriter, status int, body *Status) {
+	writer.Header().Add("Content-Type", "application/json")
+	writer.Header().Add("Cache-Control", "no-store")
+	writer.WriteHeader(status)
+	bytes, _ := json.Marshal(body)
+	_, _ = writer.Write(bytes)
+}
@@ -0,0 +1,72 @@
+package health_test
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/health"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+)
+
+var _ = Describe("Health", func() {
+
+	status := func(recorder *httptest.ResponseRecorder) *health.Status {
+		var result health.Status
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &result)).To(Succeed())
+		return &result
+	}
+
+	It("is alive", func() {
+		handler := &health.LivenessHandler{Path: "/healthz"}
+		recorder := httptest.NewRecorder()
+
+		handler.Live(recorder, httptest.NewRequest("GET", "/healthz", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(status(recorder).Status).To(Equal("ok"))
+	})
+
+	It("is ready when all checks pass", func() {
+		handler := &health.ReadinessHandler{
+			Path: "/readyz",
+			Checks: []health.Check{
+				{Name: "database", Run: func() error { return nil }},
+			},
+		}
+		recorder := httptest.NewRecorder()
+
+		handler.Ready(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(status(recorder).Status).To(Equal("ready"))
+	})
+
+	It("reports failed checks", func() {
+		handler := &health.ReadinessHandler{
+			Path: "/readyz",
+			Checks: []health.Check{
+				{Name: "database", Run: func() error { return nil }},
+				{Name: "migrations", Run: func() error { return errors.New("2 migrations pending") }},
+			},
+		}
+		recorder := httptest.NewRecorder()
+
+		handler.Ready(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+		Expect(recorder.Code).To(Equal(503))
+		Expect(status(recorder)).To(Equal(&health.Status{
+			Status: "unavailable",
+			Checks: map[string]string{"migrations": "2 migrations pending"},
+		}))
+	})
+
+	It("only answers reads", func() {
+		handler := &health.ReadinessHandler{Path: "/readyz"}
+		recorder := httptest.NewRecorder()
+
+		handler.Ready(recorder, httptest.NewRequest("POST", "/readyz", nil))
+
+		Expect(recorder.Code).To(Equal(405))
+	})
+})
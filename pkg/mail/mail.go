@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is a plain text mail.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends mails to users.
+type Mailer interface {
+	Send(message *Message) error
+}
+
+// ErrInvalidHeader is returned for recipients or subjects spanning
+// several lines, which would allow injecting headers.
+var ErrInvalidHeader = errors.New("invalid mail header")
+
+// SMTPMailer delivers mails through an SMTP server at Address, given as
+// host:port. Username and Password are optional; the server has to
+// offer STARTTLS for them to be sent.
+type SMTPMailer struct {
+	Address  string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPMailer) Send(message *Message) error {
+	content, err := format(s.From, message, time.Now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Address)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Address, auth, s.From, []string{message.To}, content)
+}
+
+// LogMailer writes mails to Logger instead of sending them. It stands
+// in for a mail server during development and in tests.
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+func (l *LogMailer) Send(message *Message) error {
+	if err := checkHeaders(message); err != nil {
+		return err
+	}
+	l.Logger.Printf("mail to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+func format(from string, message *Message, date time.Time) ([]byte, error) {
+	if err := checkHeaders(message); err != nil {
+		return nil, err
+	}
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "From: %s\r\n", from)
+	fmt.Fprintf(&content, "To: %s\r\n", message.To)
+	fmt.Fprintf(&content, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&content, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	content.WriteString("MIME-Version: 1.0\r\n")
+	content.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	content.WriteString("\r\n")
+	content.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return content.Bytes(), nil
+}
+
+func checkHeaders(message *Message) error {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	return nil
+}
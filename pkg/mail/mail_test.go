@@ -0,0 +1,97 @@
+package mail_test
+
+import (
+	"bytes"
+	"github.com/mvslovers/hnetdb/pkg/mail"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+// smtpServer accepts a single mail and sends its data to received.
+func smtpServer(received chan<- string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(BeNil(), "Server should listen")
+	go func() {
+		defer GinkgoRecover()
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer connection.Close()
+		text := textproto.NewConn(connection)
+		_ = text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.Fields(line + " x")[0]); command {
+			case "EHLO", "HELO":
+				_ = text.PrintfLine("250 localhost")
+			case "DATA":
+				_ = text.PrintfLine("354 go ahead")
+				data, err := text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				received <- string(data)
+				_ = text.PrintfLine("250 ok")
+			case "QUIT":
+				_ = text.PrintfLine("221 bye")
+				return
+			default:
+				_ = text.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return listener
+}
+
+var _ = Describe("Mailers", func() {
+
+	message := &mail.Message{
+		To:      "flo@example.org",
+		Subject: "Verify your email address",
+		Body:    "Hello flo,\nplease verify.",
+	}
+
+	It("sends mails through SMTP", func() {
+		received := make(chan string, 1)
+		listener := smtpServer(received)
+		defer listener.Close()
+		mailer := &mail.SMTPMailer{Address: listener.Addr().String(), From: "hnetdb@example.org"}
+
+		Expect(mailer.Send(message)).To(Succeed())
+
+		var data string
+		Eventually(received).Should(Receive(&data))
+		Expect(data).To(ContainSubstring("From: hnetdb@example.org\n"))
+		Expect(data).To(ContainSubstring("To: flo@example.org\n"))
+		Expect(data).To(ContainSubstring("Subject: Verify your email address\n"))
+		Expect(data).To(HaveSuffix("\n\nHello flo,\nplease verify.\n"))
+	})
+
+	It("logs mails", func() {
+		var output bytes.Buffer
+		mailer := &mail.LogMailer{Logger: log.New(&output, "", 0)}
+
+		Expect(mailer.Send(message)).To(Succeed())
+
+		Expect(output.String()).To(Equal(
+			"mail to flo@example.org: Verify your email address\nHello flo,\nplease verify.\n"))
+	})
+
+	It("refuses to inject headers", func() {
+		mailer := &mail.LogMailer{Logger: log.New(&bytes.Buffer{}, "", 0)}
+
+		err := mailer.Send(&mail.Message{To: "flo@example.org\r\nBcc: all@example.org", Subject: "Hi"})
+		Expect(err).To(Equal(mail.ErrInvalidHeader))
+
+		err = (&mail.SMTPMailer{Address: "127.0.0.1:1"}).Send(&mail.Message{To: "flo@example.org", Subject: "Hi\nBcc: x"})
+		Expect(err).To(Equal(mail.ErrInvalidHeader))
+	})
+})
@@ -0,0 +1,83 @@
+package migrations
+
+// Builtin holds the migrations shipped with hnetdb. Append new ones at
+// the end; never change a migration once it has been released.
+var Builtin = []Migration{
+	{
+		Version:     1,
+		Description: "unique migration records, node names, user names and email addresses",
+		Statements: []string{
+			"CREATE CONSTRAINT migration_id IF NOT EXISTS FOR (m:Migration) REQUIRE m.id IS UNIQUE",
+			"CREATE CONSTRAINT schema_version_namespace IF NOT EXISTS FOR (v:SchemaVersion) REQUIRE v.namespace IS UNIQUE",
+			"CREATE CONSTRAINT node_name IF NOT EXISTS FOR (n:Node) REQUIRE n.name IS UNIQUE",
+			"CREATE CONSTRAINT user_username IF NOT EXISTS FOR (u:User) REQUIRE u.username IS UNIQUE",
+			"CREATE CONSTRAINT user_email IF NOT EXISTS FOR (u:User) REQUIRE u.email IS UNIQUE",
+		},
+	},
+	{
+		Version:     2,
+		Description: "mark nodes registered before the approval workflow as approved",
+		Statements: []string{
+			"MATCH (n:Node) WHERE n.state IS NULL SET n.state = 'approved'",
+		},
+	},
+	{
+		Version:     3,
+		Description: "unique refresh token IDs",
+		Statements: []string{
+			"CREATE CONSTRAINT refresh_token_id IF NOT EXISTS FOR (t:RefreshToken) REQUIRE t.id IS UNIQUE",
+		},
+	},
+	{
+		Version:     4,
+		Description: "unique API token IDs",
+		Statements: []string{
+			"CREATE CONSTRAINT api_token_id IF NOT EXISTS FOR (t:APIToken) REQUIRE t.id IS UNIQUE",
+		},
+	},
+	{
+		Version:     5,
+		Description: "email verification",
+		Statements: []string{
+			"CREATE CONSTRAINT one_time_token_hash IF NOT EXISTS FOR (t:OneTimeToken) REQUIRE t.hash IS UNIQUE",
+			// Users registered before verification existed keep their
+			// rights.
+			"MATCH (u:User) WHERE u.verified IS NULL SET u.verified = true",
+		},
+	},
+	{
+		Version:     6,
+		Description: "failed login tracking",
+		Statements: []string{
+			"CREATE CONSTRAINT login_failures_key IF NOT EXISTS FOR (f:LoginFailures) REQUIRE f.key IS UNIQUE",
+			"CREATE INDEX login_failures_last_failure IF NOT EXISTS FOR (f:LoginFailures) ON (f.last_failure)",
+			"CREATE INDEX failed_login_at IF NOT EXISTS FOR (f:FailedLogin) ON (f.at)",
+		},
+	},
+	{
+		Version:     7,
+		Description: "node list filters",
+		Statements: []string{
+			"CREATE INDEX node_os IF NOT EXISTS FOR (n:Node) ON (n.os)",
+			"CREATE INDEX node_platform IF NOT EXISTS FOR (n:Node) ON (n.platform)",
+			"CREATE INDEX node_location IF NOT EXISTS FOR (n:Node) ON (n.location)",
+			"CREATE INDEX node_gateway IF NOT EXISTS FOR (n:Node) ON (n.gateway)",
+		},
+	},
+	{
+		Version:     8,
+		Description: "node search",
+		Statements: []string{
+			"CREATE FULLTEXT INDEX node_search IF NOT EXISTS FOR (n:Node) " +
+				"ON EACH [n.name, n.alias, n.location, n.platform, n.os] " +
+				"OPTIONS {indexConfig: {`fulltext.analyzer`: 'standard-no-stop-words'}}",
+		},
+	},
+	{
+		Version:     9,
+		Description: "node places",
+		Statements: []string{
+			"CREATE POINT INDEX node_coordinates IF NOT EXISTS FOR (n:Node) ON (n.coordinates)",
+		},
+	},
+}
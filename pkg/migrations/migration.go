@@ -0,0 +1,130 @@
+package migrations
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration brings the graph from the previous version of its namespace
+// to Version. Statements are Cypher statements that are run one by one,
+// each in its own transaction, since Neo4j does not allow schema and data
+// changes in the same transaction. Run, if set, is called afterwards in a
+// single write transaction for fix-ups that are easier to write in Go.
+type Migration struct {
+	// Namespace keeps the versions of the migrations of an installation
+	// apart from the builtin ones, which leave it empty. See Load.
+	Namespace   string
+	Version     int
+	Description string
+	Statements  []string
+	Run         func(tx neo4j.Transaction) error
+}
+
+// LocalNamespace is the namespace of migrations loaded from a directory.
+const LocalNamespace = "local"
+
+// ID names the migration in the database, like "hnetdb/3" for the third
+// builtin migration or "local/3" for a loaded one.
+func (m Migration) ID() string {
+	return m.namespace() + "/" + strconv.Itoa(m.Version)
+}
+
+// namespace is the namespace recorded in the database, "hnetdb" for the
+// builtin migrations.
+func (m Migration) namespace() string {
+	if m.Namespace == "" {
+		return "hnetdb"
+	}
+	return m.Namespace
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.cypher$`)
+
+// Load reads Cypher migrations from dir into LocalNamespace, so their
+// versions never collide with builtin ones. Files are named
+// {version}_{description}.cypher, e.g. 0003_add_link_index.cypher, and
+// hold statements separated by semicolons. Lines starting with // are
+// comments.
+func Load(dir string) ([]Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Migration
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".cypher" {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(file.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must be {version}_{description}.cypher", file.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", file.Name(), err)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Migration{
+			Namespace:   LocalNamespace,
+			Version:     version,
+			Description: strings.ReplaceAll(match[2], "_", " "),
+			Statements:  statements(string(content)),
+		})
+	}
+	return result, nil
+}
+
+// Sorted returns migrations in the order they are applied: the builtin
+// ones first, since local ones may rely on their schema, then by
+// namespace and version. Versions must be positive and unique within a
+// namespace.
+func Sorted(migrations []Migration) ([]Migration, error) {
+	result := make([]Migration, len(migrations))
+	copy(result, migrations)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Version < result[j].Version
+	})
+	for i, migration := range result {
+		if migration.Version < 1 {
+			return nil, fmt.Errorf("migration %s (%s): version has to be positive",
+				migration.ID(), migration.Description)
+		}
+		if i > 0 && migration.ID() == result[i-1].ID() {
+			return nil, fmt.Errorf("migration %s (%s): version is taken by %q",
+				migration.ID(), migration.Description, result[i-1].Description)
+		}
+	}
+	return result, nil
+}
+
+func statements(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "//") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var result []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		statement = strings.TrimSpace(statement)
+		if statement != "" {
+			result = append(result, statement)
+		}
+	}
+	return result
+}
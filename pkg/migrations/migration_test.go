@@ -0,0 +1,89 @@
+package migrations_test
+
+import (
+	. "github.com/mvslovers/hnetdb/pkg/migrations"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Migrations", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "migrations")
+		Expect(err).To(BeNil(), "Directory should be created")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	write := func(name string, content string) {
+		Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+	}
+
+	It("loads Cypher files", func() {
+		write("0003_link_index.cypher", "// speeds up route lookups\n"+
+			"CREATE INDEX link_status IF NOT EXISTS\n"+
+			"FOR ()-[l:LINKED_TO]-() ON (l.status);\n"+
+			"\n"+
+			"MATCH ()-[l:LINKED_TO]-() WHERE l.status IS NULL SET l.status = 'up';\n")
+		write("README.md", "not a migration")
+
+		migrations, err := Load(dir)
+
+		Expect(err).To(BeNil())
+		Expect(migrations).To(HaveLen(1))
+		Expect(migrations[0].ID()).To(Equal("local/3"))
+		Expect(migrations[0].Description).To(Equal("link index"))
+		Expect(migrations[0].Statements).To(Equal([]string{
+			"CREATE INDEX link_status IF NOT EXISTS\nFOR ()-[l:LINKED_TO]-() ON (l.status)",
+			"MATCH ()-[l:LINKED_TO]-() WHERE l.status IS NULL SET l.status = 'up'",
+		}))
+	})
+
+	It("rejects badly named files", func() {
+		write("link-index.cypher", "RETURN 1")
+
+		_, err := Load(dir)
+
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("sorts migrations by version", func() {
+		sorted, err := Sorted([]Migration{{Version: 2}, {Version: 1}})
+
+		Expect(err).To(BeNil())
+		Expect(sorted).To(Equal([]Migration{{Version: 1}, {Version: 2}}))
+	})
+
+	It("rejects duplicates", func() {
+		_, err := Sorted([]Migration{{Version: 1}, {Version: 1}})
+		Expect(err).NotTo(BeNil())
+
+		_, err = Sorted([]Migration{{Version: 0}})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("keeps the versions of local migrations apart from builtin ones", func() {
+		local := Migration{Namespace: LocalNamespace, Version: 1}
+
+		sorted, err := Sorted([]Migration{local, {Version: 2}, {Version: 1}})
+
+		Expect(err).To(BeNil())
+		Expect(sorted).To(Equal([]Migration{{Version: 1}, {Version: 2}, local}))
+		Expect(sorted[0].ID()).To(Equal("hnetdb/1"))
+		Expect(sorted[2].ID()).To(Equal("local/1"))
+	})
+
+	It("numbers the builtin migrations uniquely", func() {
+		_, err := Sorted(Builtin)
+
+		Expect(err).To(BeNil())
+	})
+})
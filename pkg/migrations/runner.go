@@ -0,0 +1,190 @@
+package migrations
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// Status reports whether a migration has been applied.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Runner applies migrations to a Neo4j database. Every applied migration
+// is recorded in a :Migration node with its ID, and the highest version
+// applied of each namespace in a :SchemaVersion node.
+type Runner struct {
+	Driver     neo4j.Driver
+	Migrations []Migration
+}
+
+// recorded is the bookkeeping of the applied migrations in the database.
+type recorded struct {
+	// namespaces holds the namespaces of the applied migrations by ID.
+	namespaces map[string]string
+	// versions holds the schema version of each namespace.
+	versions map[string]int64
+}
+
+func (r *Runner) recorded() (result recorded, err error) {
+	session := r.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeRead,
+	})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result = recorded{namespaces: map[string]string{}, versions: map[string]int64{}}
+		res, err := tx.Run("MATCH (m:Migration) RETURN m.namespace AS namespace, m.id AS id", nil)
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			id, _ := res.Record().Get("id")
+			namespace, _ := res.Record().Get("namespace")
+			result.namespaces[id.(string)] = namespace.(string)
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+
+		res, err = tx.Run("MATCH (v:SchemaVersion) RETURN v.namespace AS namespace, v.version AS version", nil)
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			namespace, _ := res.Record().Get("namespace")
+			version, _ := res.Record().Get("version")
+			result.versions[namespace.(string)] = version.(int64)
+		}
+		return nil, res.Err()
+	})
+	return result, err
+}
+
+// Status lists all migrations in order together with whether they have
+// been applied. It fails if the schema version of a namespace of the
+// runner is beyond its migrations, or the database has migrations applied
+// in such a namespace that are unknown to the runner, as when an older
+// hnetdb runs against a newer schema. Other namespaces are left alone, so
+// the server does not care about local migrations.
+func (r *Runner) Status() ([]Status, error) {
+	migrations, err := Sorted(r.Migrations)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := r.recorded()
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	latest := map[string]int64{}
+	for _, migration := range migrations {
+		known[migration.ID()] = true
+		if version := int64(migration.Version); version > latest[migration.namespace()] {
+			latest[migration.namespace()] = version
+		}
+	}
+	for namespace, version := range recorded.versions {
+		if highest, ok := latest[namespace]; ok && version > highest {
+			return nil, fmt.Errorf("database is at schema version %d of %s, only up to %d is known here",
+				version, namespace, highest)
+		}
+	}
+	for id, namespace := range recorded.namespaces {
+		if _, ok := latest[namespace]; ok && !known[id] {
+			return nil, fmt.Errorf("database has migration %s applied, which is unknown here", id)
+		}
+	}
+
+	result := make([]Status, len(migrations))
+	for i, migration := range migrations {
+		_, ok := recorded.namespaces[migration.ID()]
+		result[i] = Status{Migration: migration, Applied: ok}
+	}
+	return result, nil
+}
+
+// Pending returns the migrations that have not been applied yet.
+func (r *Runner) Pending() ([]Migration, error) {
+	status, err := r.Status()
+	if err != nil {
+		return nil, err
+	}
+	var result []Migration
+	for _, s := range status {
+		if !s.Applied {
+			result = append(result, s.Migration)
+		}
+	}
+	return result, nil
+}
+
+// Up applies all pending migrations in order and returns them. It stops
+// at the first failing migration, which stays pending.
+func (r *Runner) Up() ([]Migration, error) {
+	pending, err := r.Pending()
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, migration := range pending {
+		if err := r.apply(migration); err != nil {
+			return applied, fmt.Errorf("migration %s (%s): %w", migration.ID(), migration.Description, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+func (r *Runner) apply(migration Migration) (err error) {
+	session := r.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for _, statement := range migration.Statements {
+		if _, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run(statement, nil)
+			if err != nil {
+				return nil, err
+			}
+			return res.Consume()
+		}); err != nil {
+			return err
+		}
+	}
+
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		if migration.Run != nil {
+			if err := migration.Run(tx); err != nil {
+				return nil, err
+			}
+		}
+		parameters := map[string]interface{}{
+			"id":          migration.ID(),
+			"namespace":   migration.namespace(),
+			"version":     migration.Version,
+			"description": migration.Description,
+		}
+		if _, err := tx.Run("MERGE (m:Migration {id: $id}) "+
+			"SET m.namespace = $namespace, m.version = $version, m.description = $description, "+
+			"m.applied_at = datetime()", parameters); err != nil {
+			return nil, err
+		}
+		// Local migrations may leave gaps, so the version only goes up.
+		return tx.Run("MERGE (v:SchemaVersion {namespace: $namespace}) "+
+			"SET v.version = CASE WHEN coalesce(v.version, 0) < $version THEN $version ELSE v.version END, "+
+			"v.updated_at = datetime()", parameters)
+	})
+	return err
+}
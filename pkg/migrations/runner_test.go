@@ -0,0 +1,196 @@
+package migrations_test
+
+import (
+	"context"
+	"fmt"
+	. "github.com/mvslovers/hnetdb/pkg/migrations"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"io"
+)
+
+var _ = Describe("Migration runner", func() {
+
+	const username = "neo4j"
+	const password = "s3cr3t"
+
+	var ctx context.Context
+	var neo4jContainer testcontainers.Container
+	var driver neo4j.Driver
+	var runner *Runner
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		neo4jContainer, err = startContainer(ctx, username, password)
+		Expect(err).To(BeNil(), "Container should start")
+		port, err := neo4jContainer.MappedPort(ctx, "7687")
+		Expect(err).To(BeNil(), "Port should be resolved")
+		address := fmt.Sprintf("bolt://localhost:%d", port.Int())
+		driver, err = neo4j.NewDriver(address, neo4j.BasicAuth(username, password, ""))
+		Expect(err).To(BeNil(), "Driver should be created")
+		runner = &Runner{
+			Driver: driver,
+			Migrations: []Migration{
+				{
+					Version:     1,
+					Description: "unique names",
+					Statements: []string{
+						"CREATE CONSTRAINT thing_name IF NOT EXISTS FOR (t:Thing) REQUIRE t.name IS UNIQUE",
+					},
+				},
+				{
+					Version:     2,
+					Description: "first thing",
+					Run: func(tx neo4j.Transaction) error {
+						_, err := tx.Run("CREATE (:Thing {name: 'first'})", nil)
+						return err
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		Close(driver, "Driver")
+		Expect(neo4jContainer.Terminate(ctx)).To(BeNil(), "Container should stop")
+	})
+
+	It("starts without applied migrations", func() {
+		status, err := runner.Status()
+		Expect(err).To(BeNil())
+		Expect(status).To(HaveLen(2))
+		Expect(status[0].Applied).To(BeFalse())
+		Expect(status[1].Applied).To(BeFalse())
+	})
+
+	It("applies pending migrations once", func() {
+		applied, err := runner.Up()
+
+		Expect(err).To(BeNil())
+		Expect(applied).To(HaveLen(2))
+		pending, err := runner.Pending()
+		Expect(err).To(BeNil())
+		Expect(pending).To(BeEmpty())
+
+		applied, err = runner.Up()
+		Expect(err).To(BeNil())
+		Expect(applied).To(BeEmpty())
+
+		session := driver.NewSession(neo4j.SessionConfig{})
+		defer Close(session, "Session")
+		count, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("MATCH (t:Thing) RETURN count(t) AS count", nil)
+			if err != nil {
+				return nil, err
+			}
+			record, err := res.Single()
+			if err != nil {
+				return nil, err
+			}
+			count, _ := record.Get("count")
+			return count, nil
+		})
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(int64(1)))
+	})
+
+	It("stops at the first failing migration", func() {
+		runner.Migrations = append(runner.Migrations, Migration{
+			Version:     3,
+			Description: "duplicate thing",
+			Statements:  []string{"CREATE (:Thing {name: 'first'})"},
+		})
+
+		applied, err := runner.Up()
+
+		Expect(err).NotTo(BeNil())
+		Expect(applied).To(HaveLen(2))
+		pending, err := runner.Pending()
+		Expect(err).To(BeNil())
+		Expect(pending).To(HaveLen(1))
+		Expect(pending[0].Version).To(Equal(3))
+	})
+
+	It("tracks local migrations apart from builtin ones", func() {
+		local := Migration{
+			Namespace:   LocalNamespace,
+			Version:     3,
+			Description: "second thing",
+			Statements:  []string{"CREATE (:Thing {name: 'second'})"},
+		}
+		runner.Migrations = append(runner.Migrations, local)
+		_, err := runner.Up()
+		Expect(err).To(BeNil())
+
+		runner.Migrations = append(runner.Migrations[:2:2], Migration{
+			Version:     3,
+			Description: "third thing",
+			Statements:  []string{"CREATE (:Thing {name: 'third'})"},
+		})
+		pending, err := runner.Pending()
+		Expect(err).To(BeNil())
+		Expect(pending).To(HaveLen(1))
+		Expect(pending[0].ID()).To(Equal("hnetdb/3"))
+	})
+
+	It("refuses databases with unknown migrations", func() {
+		_, err := runner.Up()
+		Expect(err).To(BeNil())
+
+		runner.Migrations = runner.Migrations[:1]
+		_, err = runner.Pending()
+
+		Expect(err).To(MatchError(ContainSubstring("schema version 2 of hnetdb")))
+	})
+
+	It("records the schema version of each namespace", func() {
+		runner.Migrations = append(runner.Migrations, Migration{
+			Namespace:   LocalNamespace,
+			Version:     5,
+			Description: "second thing",
+			Statements:  []string{"CREATE (:Thing {name: 'second'})"},
+		})
+		_, err := runner.Up()
+		Expect(err).To(BeNil())
+
+		session := driver.NewSession(neo4j.SessionConfig{})
+		defer Close(session, "Session")
+		versions, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("MATCH (v:SchemaVersion) RETURN v.namespace AS namespace, v.version AS version", nil)
+			if err != nil {
+				return nil, err
+			}
+			versions := map[string]int64{}
+			for res.Next() {
+				namespace, _ := res.Record().Get("namespace")
+				version, _ := res.Record().Get("version")
+				versions[namespace.(string)] = version.(int64)
+			}
+			return versions, res.Err()
+		})
+		Expect(err).To(BeNil())
+		Expect(versions).To(Equal(map[string]int64{"hnetdb": 2, "local": 5}))
+	})
+})
+
+func Close(closer io.Closer, resourceName string) {
+	Expect(closer.Close()).
+		To(BeNil(), "%s should close", resourceName)
+}
+
+func startContainer(ctx context.Context, username, password string) (testcontainers.Container, error) {
+	request := testcontainers.ContainerRequest{
+		Image:        "neo4j",
+		ExposedPorts: []string{"7687/tcp"},
+		Env:          map[string]string{"NEO4J_AUTH": fmt.Sprintf("%s/%s", username, password)},
+		WaitingFor:   wait.ForLog("Bolt enabled"),
+	}
+	return testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: request,
+		Started:          true,
+	})
+}
@@ -0,0 +1,53 @@
+package network
+
+import (
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"strings"
+)
+
+// DOT renders the network as undirected Graphviz graph, since NJE links
+// carry traffic both ways. Nodes are clustered by country and labeled
+// with their operating system; gateways are drawn as double octagons and
+// links marked down are dashed.
+func (n *Network) DOT() string {
+	builder := &strings.Builder{}
+	builder.WriteString("graph hnet {\n")
+	builder.WriteString("  node [shape=box];\n")
+	countries, unplaced := n.byCountry()
+	for _, country := range countries {
+		fmt.Fprintf(builder, "  subgraph %s {\n", dotID("cluster_"+country.code))
+		fmt.Fprintf(builder, "    label=%s;\n", dotID(nodes.CountryName(country.code)))
+		for _, node := range country.nodes {
+			writeDOTNode(builder, "    ", node)
+		}
+		builder.WriteString("  }\n")
+	}
+	for _, node := range unplaced {
+		writeDOTNode(builder, "  ", node)
+	}
+	for _, link := range n.Links {
+		attributes := "label=" + dotID(string(link.Transport))
+		if link.Status == nodes.LinkDown {
+			attributes += ", style=dashed"
+		}
+		fmt.Fprintf(builder, "  %s -- %s [%s];\n", dotID(link.From), dotID(link.To), attributes)
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+func writeDOTNode(builder *strings.Builder, indent string, node *nodes.Node) {
+	attributes := "label=" + dotID(node.Name+"\n"+node.OperatingSystem)
+	if node.IsGateway {
+		attributes += ", shape=doubleoctagon"
+	}
+	fmt.Fprintf(builder, "%s%s [%s];\n", indent, dotID(node.Name), attributes)
+}
+
+// dotID quotes text as DOT identifier. Node names may contain the
+// national characters @, # and $, which DOT only allows quoted.
+func dotID(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(text) + `"`
+}
@@ -0,0 +1,44 @@
+package network_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/network"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DOT", func() {
+
+	It("clusters nodes by country and draws gateways and down links", func() {
+		loaded, err := testExporter().Load()
+		Expect(err).To(BeNil())
+
+		Expect(loaded.DOT()).To(Equal(`graph hnet {
+  node [shape=box];
+  subgraph "cluster_DE" {
+    label="Germany";
+    "DRNLON1A" [label="DRNLON1A\nVM/370"];
+  }
+  subgraph "cluster_GB" {
+    label="United Kingdom";
+    "DRNBRX1A" [label="DRNBRX1A\nMVS3.8J"];
+    "DRNMIG1A" [label="DRNMIG1A\nz/OS", shape=doubleoctagon];
+  }
+  "DRNBRX1A" -- "DRNMIG1A" [label="tcpnje"];
+  "DRNMIG1A" -- "DRNLON1A" [label="bsc", style=dashed];
+}
+`))
+	})
+
+	It("quotes names and leaves nodes without place unclustered", func() {
+		unplaced := &network.Network{
+			Nodes: []*nodes.Node{{Name: "HNET$1", OperatingSystem: `VM/370 "SixPack"`}},
+		}
+
+		Expect(unplaced.DOT()).To(Equal(`graph hnet {
+  node [shape=box];
+  "HNET$1" [label="HNET$1\nVM/370 \"SixPack\""];
+}
+`))
+	})
+})
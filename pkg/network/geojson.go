@@ -0,0 +1,88 @@
+package network
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+)
+
+// FeatureCollection is a GeoJSON document as of RFC 7946.
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry holds a Point or a LineString. Positions are longitude,
+// latitude.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// GeoJSON maps the nodes with coordinates to Point features named like
+// the node, followed by LineString features for the links between them,
+// named FROM-TO. Nodes without coordinates and their links are left
+// out.
+func (n *Network) GeoJSON() *FeatureCollection {
+	collection := &FeatureCollection{Type: "FeatureCollection", Features: []*Feature{}}
+	positions := map[string][]float64{}
+	for _, node := range n.Nodes {
+		if node.Place == nil || node.Place.Coordinates == nil {
+			continue
+		}
+		position := []float64{node.Place.Coordinates.Longitude, node.Place.Coordinates.Latitude}
+		positions[node.Name] = position
+		collection.Features = append(collection.Features, &Feature{
+			Type:       "Feature",
+			ID:         node.Name,
+			Geometry:   &Geometry{Type: "Point", Coordinates: position},
+			Properties: nodeProperties(node),
+		})
+	}
+	for _, link := range n.Links {
+		from, ok := positions[link.From]
+		if !ok {
+			continue
+		}
+		to, ok := positions[link.To]
+		if !ok {
+			continue
+		}
+		collection.Features = append(collection.Features, &Feature{
+			Type:     "Feature",
+			ID:       link.From + "-" + link.To,
+			Geometry: &Geometry{Type: "LineString", Coordinates: [][]float64{from, to}},
+			Properties: map[string]interface{}{
+				"from":      link.From,
+				"to":        link.To,
+				"transport": link.Transport,
+				"status":    link.Status,
+			},
+		})
+	}
+	return collection
+}
+
+// nodeProperties are named like the attributes of nodes.Node.
+func nodeProperties(node *nodes.Node) map[string]interface{} {
+	properties := map[string]interface{}{
+		"name":     node.Name,
+		"gateway":  node.IsGateway,
+		"platform": node.Platform,
+		"os":       node.OperatingSystem,
+		"location": node.Location,
+		"country":  node.Place.Country,
+	}
+	if node.Alias != "" {
+		properties["alias"] = node.Alias
+	}
+	if node.Place.City != "" {
+		properties["city"] = node.Place.City
+	}
+	return properties
+}
@@ -0,0 +1,44 @@
+package network_test
+
+import (
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GeoJSON", func() {
+
+	It("maps nodes with coordinates to points and their links to lines", func() {
+		loaded, err := testExporter().Load()
+		Expect(err).To(BeNil())
+
+		encoded, err := json.Marshal(loaded.GeoJSON())
+
+		Expect(err).To(BeNil())
+		Expect(encoded).To(MatchJSON(`{
+			"type": "FeatureCollection",
+			"features": [
+				{
+					"type": "Feature",
+					"id": "DRNBRX1A",
+					"geometry": {"type": "Point", "coordinates": [-0.1156, 51.4613]},
+					"properties": {"name": "DRNBRX1A", "alias": "BRX", "gateway": false, "platform": "Hercules",
+						"os": "MVS3.8J", "location": "Brixton", "country": "GB", "city": "London"}
+				},
+				{
+					"type": "Feature",
+					"id": "DRNMIG1A",
+					"geometry": {"type": "Point", "coordinates": [-0.1426, 51.539]},
+					"properties": {"name": "DRNMIG1A", "gateway": true, "platform": "z/PDT", "os": "z/OS",
+						"location": "Camden", "country": "GB"}
+				},
+				{
+					"type": "Feature",
+					"id": "DRNBRX1A-DRNMIG1A",
+					"geometry": {"type": "LineString", "coordinates": [[-0.1156, 51.4613], [-0.1426, 51.539]]},
+					"properties": {"from": "DRNBRX1A", "to": "DRNMIG1A", "transport": "tcpnje", "status": "up"}
+				}
+			]
+		}`))
+	})
+})
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"encoding/json"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+)
+
+type Handler struct {
+	Path     string
+	Exporter *Exporter
+}
+
+// GeoJSON serves Path.geojson, see Network.GeoJSON.
+func (h *Handler) GeoJSON(writer http.ResponseWriter, request *http.Request) {
+	h.serve(writer, request, "application/geo+json", func(network *Network) []byte {
+		bytes, _ := json.Marshal(network.GeoJSON())
+		return bytes
+	})
+}
+
+// DOT serves Path.dot, see Network.DOT.
+func (h *Handler) DOT(writer http.ResponseWriter, request *http.Request) {
+	h.serve(writer, request, "text/vnd.graphviz; charset=utf-8", func(network *Network) []byte {
+		return []byte(network.DOT())
+	})
+}
+
+// Mermaid serves Path.mmd, see Network.Mermaid.
+func (h *Handler) Mermaid(writer http.ResponseWriter, request *http.Request) {
+	h.serve(writer, request, "text/plain; charset=utf-8", func(network *Network) []byte {
+		return []byte(network.Mermaid())
+	})
+}
+
+func (h *Handler) serve(writer http.ResponseWriter, request *http.Request, contentType string,
+	render func(network *Network) []byte) {
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	network, err := h.Exporter.Load()
+	if err != nil {
+		apierror.Internal(writer)
+		return
+	}
+
+	writer.Header().Add("Content-Type", contentType)
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write(render(network))
+}
@@ -0,0 +1,62 @@
+package network_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/network"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+)
+
+var _ = Describe("Network handler", func() {
+
+	var handler *network.Handler
+
+	BeforeEach(func() {
+		handler = &network.Handler{
+			Path:     "/network",
+			Exporter: testExporter(),
+		}
+	})
+
+	It("serves GeoJSON", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.GeoJSON(recorder, httptest.NewRequest("GET", "/network.geojson", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/geo+json"))
+		Expect(recorder.Body.String()).To(HavePrefix(`{"type":"FeatureCollection","features":[`))
+	})
+
+	It("serves DOT and Mermaid", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.DOT(recorder, httptest.NewRequest("GET", "/network.dot", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("text/vnd.graphviz; charset=utf-8"))
+		Expect(recorder.Body.String()).To(HavePrefix("graph hnet {\n"))
+
+		recorder = httptest.NewRecorder()
+
+		handler.Mermaid(recorder, httptest.NewRequest("GET", "/network.mmd", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+		Expect(recorder.Body.String()).To(HavePrefix("flowchart LR\n"))
+	})
+
+	It("only allows GET", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.GeoJSON(recorder, httptest.NewRequest("POST", "/network.geojson", nil))
+
+		Expect(recorder.Code).To(Equal(405))
+
+		recorder = httptest.NewRecorder()
+
+		handler.DOT(recorder, httptest.NewRequest("DELETE", "/network.dot", nil))
+
+		Expect(recorder.Code).To(Equal(405))
+	})
+})
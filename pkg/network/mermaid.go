@@ -0,0 +1,57 @@
+package network
+
+import (
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"strings"
+)
+
+// Mermaid renders the network as Mermaid flowchart, grouped and drawn
+// like DOT: gateways are hexagons and links marked down are dotted.
+// Nodes get IDs by position, since Mermaid does not accept the national
+// characters of node names in IDs.
+func (n *Network) Mermaid() string {
+	ids := map[string]string{}
+	for i, node := range n.Nodes {
+		ids[node.Name] = fmt.Sprintf("n%d", i)
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString("flowchart LR\n")
+	countries, unplaced := n.byCountry()
+	for _, country := range countries {
+		fmt.Fprintf(builder, "  subgraph country_%s [%s]\n", country.code,
+			mermaidText(nodes.CountryName(country.code)))
+		for _, node := range country.nodes {
+			writeMermaidNode(builder, "    ", ids[node.Name], node)
+		}
+		builder.WriteString("  end\n")
+	}
+	for _, node := range unplaced {
+		writeMermaidNode(builder, "  ", ids[node.Name], node)
+	}
+	for _, link := range n.Links {
+		line := "---"
+		if link.Status == nodes.LinkDown {
+			line = "-.-"
+		}
+		fmt.Fprintf(builder, "  %s %s|%s| %s\n", ids[link.From], line, mermaidText(string(link.Transport)),
+			ids[link.To])
+	}
+	return builder.String()
+}
+
+func writeMermaidNode(builder *strings.Builder, indent string, id string, node *nodes.Node) {
+	label := mermaidText(node.Name + "\n" + node.OperatingSystem)
+	if node.IsGateway {
+		fmt.Fprintf(builder, "%s%s{{%s}}\n", indent, id, label)
+	} else {
+		fmt.Fprintf(builder, "%s%s[%s]\n", indent, id, label)
+	}
+}
+
+// mermaidText quotes text as Mermaid label.
+func mermaidText(text string) string {
+	replacer := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "\n", "<br/>")
+	return `"` + replacer.Replace(text) + `"`
+}
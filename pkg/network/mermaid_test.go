@@ -0,0 +1,38 @@
+package network_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/network"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mermaid", func() {
+
+	It("groups nodes by country and draws gateways and down links", func() {
+		loaded, err := testExporter().Load()
+		Expect(err).To(BeNil())
+
+		Expect(loaded.Mermaid()).To(Equal(`flowchart LR
+  subgraph country_DE ["Germany"]
+    n1["DRNLON1A<br/>VM/370"]
+  end
+  subgraph country_GB ["United Kingdom"]
+    n0["DRNBRX1A<br/>MVS3.8J"]
+    n2{{"DRNMIG1A<br/>z/OS"}}
+  end
+  n0 ---|"tcpnje"| n2
+  n2 -.-|"bsc"| n1
+`))
+	})
+
+	It("escapes labels and leaves nodes without place ungrouped", func() {
+		unplaced := &network.Network{
+			Nodes: []*nodes.Node{{Name: "HNET$1", OperatingSystem: `<b>"VM"</b>`}},
+		}
+
+		Expect(unplaced.Mermaid()).To(Equal(`flowchart LR
+  n0["HNET$1<br/>#lt;b#gt;#quot;VM#quot;#lt;/b#gt;"]
+`))
+	})
+})
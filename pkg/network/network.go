@@ -0,0 +1,84 @@
+// Package network exports the approved part of the HNET graph for maps
+// and diagrams.
+package network
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"sort"
+)
+
+// Network holds the approved nodes in name order and the links between
+// them, ordered by their ends.
+type Network struct {
+	Nodes []*nodes.Node
+	Links []*nodes.Link
+}
+
+type Exporter struct {
+	NodeRepository nodes.NodeRepository
+	LinkRepository nodes.LinkRepository
+}
+
+// Load reads the network. Links to nodes that are not approved are left
+// out, links marked down are kept.
+func (e *Exporter) Load() (*Network, error) {
+	approved, err := e.NodeRepository.FindAllByState(nodes.StateApproved)
+	if err != nil {
+		return nil, err
+	}
+	links, err := e.LinkRepository.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	network := &Network{Nodes: approved, Links: []*nodes.Link{}}
+	names := map[string]bool{}
+	for _, node := range approved {
+		names[node.Name] = true
+	}
+	for _, link := range links {
+		if names[link.From] && names[link.To] {
+			network.Links = append(network.Links, link)
+		}
+	}
+
+	sort.Slice(network.Nodes, func(i, j int) bool {
+		return network.Nodes[i].Name < network.Nodes[j].Name
+	})
+	sort.Slice(network.Links, func(i, j int) bool {
+		if network.Links[i].From != network.Links[j].From {
+			return network.Links[i].From < network.Links[j].From
+		}
+		return network.Links[i].To < network.Links[j].To
+	})
+	return network, nil
+}
+
+// country holds the nodes placed in the country with the ISO code.
+type country struct {
+	code  string
+	nodes []*nodes.Node
+}
+
+// byCountry groups the nodes by country, in order of the country codes,
+// and returns the nodes without a place separately.
+func (n *Network) byCountry() (countries []*country, unplaced []*nodes.Node) {
+	indexes := map[string]int{}
+	for _, node := range n.Nodes {
+		if node.Place == nil {
+			unplaced = append(unplaced, node)
+			continue
+		}
+		index, ok := indexes[node.Place.Country]
+		if !ok {
+			index = len(countries)
+			indexes[node.Place.Country] = index
+			countries = append(countries, &country{code: node.Place.Country})
+		}
+		countries[index].nodes = append(countries[index].nodes, node)
+	}
+	sort.Slice(countries, func(i, j int) bool {
+		return countries[i].code < countries[j].code
+	})
+	return countries, unplaced
+}
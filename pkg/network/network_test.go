@@ -0,0 +1,55 @@
+package network_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/network"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// testExporter reads a small network from memory: DRNBRX1A and DRNMIG1A
+// in London, DRNLON1A without coordinates and the pending DRNNEW1A.
+func testExporter() *network.Exporter {
+	graph := nodes.NewMemoryGraph()
+	graph.Import(&nodes.GraphData{
+		Nodes: []*nodes.Node{
+			{Name: "DRNMIG1A", Platform: "z/PDT", OperatingSystem: "z/OS", IsGateway: true, Location: "Camden",
+				Place: &nodes.Place{Country: "GB", Coordinates: &nodes.Coordinates{Latitude: 51.539, Longitude: -0.1426}}},
+			{Name: "DRNBRX1A", Alias: "BRX", Platform: "Hercules", OperatingSystem: "MVS3.8J", Location: "Brixton",
+				Place: &nodes.Place{Country: "GB", City: "London",
+					Coordinates: &nodes.Coordinates{Latitude: 51.4613, Longitude: -0.1156}}},
+			{Name: "DRNLON1A", Platform: "Hercules", OperatingSystem: "VM/370", Location: "Germany"},
+			{Name: "DRNNEW1A", Platform: "Hercules", OperatingSystem: "MVS3.8J", State: nodes.StatePending},
+		},
+		Links: []*nodes.Link{
+			{From: "DRNMIG1A", To: "DRNLON1A", Transport: nodes.TransportBSC, Host: "0B0", Status: nodes.LinkDown},
+			{From: "DRNBRX1A", To: "DRNMIG1A", Transport: nodes.TransportTCP, Host: "mig.example.org",
+				Status: nodes.LinkUp},
+			{From: "DRNMIG1A", To: "DRNNEW1A", Transport: nodes.TransportTCP, Host: "new.example.org",
+				Status: nodes.LinkUp},
+		},
+	})
+	return &network.Exporter{
+		NodeRepository: &nodes.NodeMemoryRepository{Graph: graph},
+		LinkRepository: &nodes.LinkMemoryRepository{Graph: graph},
+	}
+}
+
+var _ = Describe("Exporter", func() {
+
+	It("loads approved nodes and the links between them", func() {
+		loaded, err := testExporter().Load()
+
+		Expect(err).To(BeNil())
+		var names []string
+		for _, node := range loaded.Nodes {
+			names = append(names, node.Name)
+		}
+		Expect(names).To(Equal([]string{"DRNBRX1A", "DRNLON1A", "DRNMIG1A"}))
+		var links []string
+		for _, link := range loaded.Links {
+			links = append(links, link.From+"-"+link.To)
+		}
+		Expect(links).To(Equal([]string{"DRNBRX1A-DRNMIG1A", "DRNMIG1A-DRNLON1A"}))
+	})
+})
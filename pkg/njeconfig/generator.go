@@ -0,0 +1,94 @@
+// Package njeconfig generates NJE routing configuration for a node
+// from the link graph stored in hnetdb.
+package njeconfig
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatJES2  Format = "jes2"
+	FormatNJE38 Format = "nje38"
+)
+
+// defaultPort is the well known port for TCP/IP NJE.
+const defaultPort = 175
+
+// DefaultFormat picks the configuration dialect for a node. MVS 3.8j
+// systems run NJE38, everything else is expected to speak JES2 NJE.
+func DefaultFormat(node *nodes.Node) Format {
+	os := strings.ToUpper(strings.Replace(node.OperatingSystem, " ", "", -1))
+	if strings.HasPrefix(os, "MVS3.8") {
+		return FormatNJE38
+	}
+	return FormatJES2
+}
+
+type Generator struct {
+	NodeRepository nodes.NodeRepository
+	LinkRepository nodes.LinkRepository
+}
+
+// Generate renders the configuration of the named node. An empty
+// format selects DefaultFormat for the node. Only approved nodes are
+// part of the generated configuration.
+func (g *Generator) Generate(name string, format Format) (string, error) {
+	node, err := g.NodeRepository.FindByName(name)
+	if err != nil {
+		return "", err
+	}
+	if !node.IsApproved() {
+		return "", nodes.ErrNodeNotFound
+	}
+
+	links, err := g.approvedLinks()
+	if err != nil {
+		return "", err
+	}
+
+	if format == "" {
+		format = DefaultFormat(node)
+	}
+
+	buffer := &bytes.Buffer{}
+	t := buildTopology(node.Name, links)
+	switch format {
+	case FormatJES2:
+		writeJES2(buffer, t)
+	case FormatNJE38:
+		writeNJE38(buffer, t)
+	default:
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+	return buffer.String(), nil
+}
+
+// approvedLinks returns the links between approved nodes.
+func (g *Generator) approvedLinks() ([]*nodes.Link, error) {
+	approved, err := g.NodeRepository.FindAllByState(nodes.StateApproved)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, node := range approved {
+		names[node.Name] = true
+	}
+
+	links, err := g.LinkRepository.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*nodes.Link
+	for _, link := range links {
+		if names[link.From] && names[link.To] {
+			result = append(result, link)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,166 @@
+package njeconfig_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/njeconfig"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type FakeNodeRepository struct {
+	Nodes []*nodes.Node
+}
+
+func (f *FakeNodeRepository) Save(node *nodes.Node) error {
+	f.Nodes = append(f.Nodes, node)
+	return nil
+}
+
+func (f *FakeNodeRepository) FindAll() ([]*nodes.Node, error) {
+	return f.Nodes, nil
+}
+
+func (f *FakeNodeRepository) FindByName(name string) (*nodes.Node, error) {
+	for _, node := range f.Nodes {
+		if node.Name == name {
+			return node, nil
+		}
+	}
+	return nil, nodes.ErrNodeNotFound
+}
+
+func (f *FakeNodeRepository) DeleteByName(name string) error {
+	return nil
+}
+
+func (f *FakeNodeRepository) Update(name string, node *nodes.Node) error {
+	return nil
+}
+
+func (f *FakeNodeRepository) FindAllByState(state nodes.State) ([]*nodes.Node, error) {
+	var result []*nodes.Node
+	for _, node := range f.Nodes {
+		if node.State == state || (state == nodes.StateApproved && node.IsApproved()) {
+			result = append(result, node)
+		}
+	}
+	return result, nil
+}
+
+func (f *FakeNodeRepository) SetState(name string, state nodes.State, reason string) error {
+	return nil
+}
+
+func (f *FakeNodeRepository) Query(query *nodes.NodeQuery) (*nodes.NodePage, error) {
+	return &nodes.NodePage{Nodes: f.Nodes, Total: len(f.Nodes)}, nil
+}
+
+type FakeLinkRepository struct {
+	Links []*nodes.Link
+}
+
+func (f *FakeLinkRepository) Save(link *nodes.Link) error {
+	return nil
+}
+
+func (f *FakeLinkRepository) FindAll() ([]*nodes.Link, error) {
+	return f.Links, nil
+}
+
+func (f *FakeLinkRepository) FindByNodes(from string, to string) (*nodes.Link, error) {
+	return nil, nodes.ErrLinkNotFound
+}
+
+func (f *FakeLinkRepository) Update(link *nodes.Link) error {
+	return nil
+}
+
+func (f *FakeLinkRepository) DeleteByNodes(from string, to string) error {
+	return nil
+}
+
+func (f *FakeLinkRepository) FindRoute(from string, to string, options nodes.RouteOptions) (*nodes.Route, error) {
+	return nil, nodes.ErrRouteNotFound
+}
+
+func testGenerator() *njeconfig.Generator {
+	return &njeconfig.Generator{
+		NodeRepository: &FakeNodeRepository{
+			Nodes: []*nodes.Node{
+				{Name: "DRNBRX1A", OperatingSystem: "MVS3.8J"},
+				{Name: "DRNMIG1A", OperatingSystem: "z/OS", IsGateway: true},
+				{Name: "DRNMIG3A", OperatingSystem: "MVS3.8J"},
+				{Name: "DRNLON1A", OperatingSystem: "VM/ESA"},
+				{Name: "DRNPAR1A", OperatingSystem: "z/OS"},
+				{Name: "DRNNEW1A", OperatingSystem: "z/OS", State: nodes.StatePending},
+			},
+		},
+		LinkRepository: &FakeLinkRepository{
+			Links: []*nodes.Link{
+				{From: "DRNBRX1A", To: "DRNMIG1A", Transport: nodes.TransportTCP,
+					Host: "mig.example.org", BufferSize: 8192, Status: nodes.LinkUp},
+				{From: "DRNMIG3A", To: "DRNMIG1A", Transport: nodes.TransportCTC,
+					Host: "0A0", Status: nodes.LinkUp},
+				{From: "DRNMIG1A", To: "DRNLON1A", Transport: nodes.TransportBSC,
+					Host: "0B0", Status: nodes.LinkUp},
+				{From: "DRNLON1A", To: "DRNPAR1A", Transport: nodes.TransportBSC,
+					Host: "0B1", Status: nodes.LinkDown},
+				{From: "DRNMIG1A", To: "DRNNEW1A", Transport: nodes.TransportTCP,
+					Host: "new.example.org", Status: nodes.LinkUp},
+			},
+		},
+	}
+}
+
+var _ = Describe("Generator", func() {
+
+	It("picks the dialect from the operating system", func() {
+		Expect(njeconfig.DefaultFormat(&nodes.Node{OperatingSystem: "MVS 3.8j"})).To(Equal(njeconfig.FormatNJE38))
+		Expect(njeconfig.DefaultFormat(&nodes.Node{OperatingSystem: "z/OS"})).To(Equal(njeconfig.FormatJES2))
+	})
+
+	It("generates JES2 definitions", func() {
+		config, err := testGenerator().Generate("DRNBRX1A", njeconfig.FormatJES2)
+
+		Expect(err).To(BeNil())
+		Expect(config).To(Equal(
+			"/* JES2 NJE DEFINITIONS FOR DRNBRX1A, GENERATED BY HNETDB */\n" +
+				"NJEDEF   OWNNODE=1,NODENUM=4,LINENUM=1,PATHMGR=NO\n" +
+				"NODE(1)  NAME=DRNBRX1A\n" +
+				"NODE(2)  NAME=DRNLON1A\n" +
+				"NODE(3)  NAME=DRNMIG1A,BUFSIZE=8192\n" +
+				"NODE(4)  NAME=DRNMIG3A\n" +
+				"NETSRV(1) SOCKET=LOCAL\n" +
+				"LINE(1)  UNIT=TCP\n" +
+				"SOCKET(DRNMIG1A) IPADDR=mig.example.org,PORT=175,NODE=3,LINE=1\n" +
+				"CONNECT  NODEA=DRNBRX1A,NODEB=DRNMIG1A\n" +
+				"CONNECT  NODEA=DRNMIG1A,NODEB=DRNLON1A\n" +
+				"CONNECT  NODEA=DRNMIG3A,NODEB=DRNMIG1A\n" +
+				"/* DRNLON1A VIA DRNMIG1A */\n" +
+				"/* DRNMIG3A VIA DRNMIG1A */\n"))
+	})
+
+	It("generates NJE38 definitions by default for MVS 3.8j", func() {
+		config, err := testGenerator().Generate("DRNMIG3A", "")
+
+		Expect(err).To(BeNil())
+		Expect(config).To(Equal(
+			"* NJE38 CONFIGURATION FOR DRNMIG3A, GENERATED BY HNETDB\n" +
+				"LOCAL    DRNMIG3A\n" +
+				"LINK     DRNMIG1A CTC 0A0\n" +
+				"ROUTE    DRNBRX1A DRNMIG1A\n" +
+				"ROUTE    DRNLON1A DRNMIG1A\n"))
+	})
+
+	It("fails for unknown nodes", func() {
+		_, err := testGenerator().Generate("DUMMY", "")
+
+		Expect(err).To(Equal(nodes.ErrNodeNotFound))
+	})
+
+	It("fails for nodes that are not approved", func() {
+		_, err := testGenerator().Generate("DRNNEW1A", "")
+
+		Expect(err).To(Equal(nodes.ErrNodeNotFound))
+	})
+})
@@ -0,0 +1,48 @@
+package njeconfig
+
+import (
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"net/http"
+	"strings"
+)
+
+type ConfigHandler struct {
+	Path      string
+	Generator *Generator
+}
+
+// Config serves Path{name}/config?format=jes2|nje38.
+func (h *ConfigHandler) Config(writer http.ResponseWriter, request *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(request.URL.Path, h.Path), "/"), "/")
+	if len(parts) != 2 || parts[1] != "config" {
+		apierror.NotFound(writer, "no such resource")
+		return
+	}
+
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	format := Format(request.URL.Query().Get("format"))
+	if format != "" && format != FormatJES2 && format != FormatNJE38 {
+		apierror.BadRequest(writer, "format has to be jes2 or nje38")
+		return
+	}
+
+	config, err := h.Generator.Generate(nodes.NormalizeNodeName(parts[0]), format)
+	if err != nil {
+		if errors.Is(err, nodes.ErrNodeNotFound) {
+			apierror.NotFound(writer, "node not found")
+			return
+		}
+		apierror.Internal(writer)
+		return
+	}
+
+	writer.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write([]byte(config))
+}
@@ -0,0 +1,55 @@
+package njeconfig_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/njeconfig"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+)
+
+var _ = Describe("Config handler", func() {
+
+	var handler *njeconfig.ConfigHandler
+
+	BeforeEach(func() {
+		handler = &njeconfig.ConfigHandler{
+			Path:      "/node/",
+			Generator: testGenerator(),
+		}
+	})
+
+	It("serves the configuration of a node", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Config(recorder, httptest.NewRequest("GET", "/node/DRNMIG1A/config?format=nje38", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(HavePrefix("* NJE38 CONFIGURATION FOR DRNMIG1A"))
+	})
+
+	It("finds nodes by their name in any case", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Config(recorder, httptest.NewRequest("GET", "/node/drnmig1a/config?format=nje38", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(HavePrefix("* NJE38 CONFIGURATION FOR DRNMIG1A"))
+	})
+
+	It("rejects unknown formats", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Config(recorder, httptest.NewRequest("GET", "/node/DRNMIG1A/config?format=rscs", nil))
+
+		Expect(recorder.Code).To(Equal(400))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"bad_request"`))
+	})
+
+	It("returns not found for unknown nodes", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Config(recorder, httptest.NewRequest("GET", "/node/DUMMY/config", nil))
+
+		Expect(recorder.Code).To(Equal(404))
+	})
+})
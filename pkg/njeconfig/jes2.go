@@ -0,0 +1,74 @@
+package njeconfig
+
+import (
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"io"
+)
+
+// writeJES2 emits JES2 initialization statements. The local node is
+// always NODE(1). Path manager is turned off and the topology is given
+// to JES2 as static CONNECT statements, so JES2 routes exactly along
+// the paths listed in the trailing comments.
+func writeJES2(w io.Writer, t *topology) {
+	destinations := t.destinations()
+	numbers := map[string]int{t.local: 1}
+	for i, name := range destinations {
+		numbers[name] = i + 2
+	}
+
+	fmt.Fprintf(w, "/* JES2 NJE DEFINITIONS FOR %s, GENERATED BY HNETDB */\n", t.local)
+	fmt.Fprintf(w, "NJEDEF   OWNNODE=1,NODENUM=%d,LINENUM=%d,PATHMGR=NO\n",
+		len(destinations)+1, len(t.adjacent))
+
+	buffers := map[string]int{}
+	for _, link := range t.adjacent {
+		buffers[link.To] = link.BufferSize
+	}
+	fmt.Fprintf(w, "NODE(1)  NAME=%s\n", t.local)
+	for _, name := range destinations {
+		if buffers[name] > 0 {
+			fmt.Fprintf(w, "NODE(%d)  NAME=%s,BUFSIZE=%d\n", numbers[name], name, buffers[name])
+		} else {
+			fmt.Fprintf(w, "NODE(%d)  NAME=%s\n", numbers[name], name)
+		}
+	}
+
+	if hasTransport(t.adjacent, nodes.TransportTCP) {
+		fmt.Fprintf(w, "NETSRV(1) SOCKET=LOCAL\n")
+	}
+	for i, link := range t.adjacent {
+		line := i + 1
+		switch link.Transport {
+		case nodes.TransportTCP:
+			port := link.Port
+			if port == 0 {
+				port = defaultPort
+			}
+			fmt.Fprintf(w, "LINE(%d)  UNIT=TCP\n", line)
+			fmt.Fprintf(w, "SOCKET(%s) IPADDR=%s,PORT=%d,NODE=%d,LINE=%d\n",
+				link.To, link.Host, port, numbers[link.To], line)
+		default:
+			fmt.Fprintf(w, "LINE(%d)  UNIT=%s\n", line, link.Host)
+		}
+	}
+
+	for _, link := range t.links {
+		fmt.Fprintf(w, "CONNECT  NODEA=%s,NODEB=%s\n", link.From, link.To)
+	}
+
+	for _, route := range t.routes {
+		if route.Hops > 1 {
+			fmt.Fprintf(w, "/* %s VIA %s */\n", route.Destination, route.NextHop)
+		}
+	}
+}
+
+func hasTransport(links []*nodes.Link, transport nodes.Transport) bool {
+	for _, link := range links {
+		if link.Transport == transport {
+			return true
+		}
+	}
+	return false
+}
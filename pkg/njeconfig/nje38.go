@@ -0,0 +1,39 @@
+package njeconfig
+
+import (
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"io"
+	"strings"
+)
+
+// writeNJE38 emits an NJE38 configuration: the local node, one LINK
+// statement per adjacent node and a ROUTE statement for every node
+// that is reached through one of them.
+func writeNJE38(w io.Writer, t *topology) {
+	fmt.Fprintf(w, "* NJE38 CONFIGURATION FOR %s, GENERATED BY HNETDB\n", t.local)
+	fmt.Fprintf(w, "LOCAL    %s\n", t.local)
+
+	for _, link := range t.adjacent {
+		switch link.Transport {
+		case nodes.TransportTCP:
+			port := link.Port
+			if port == 0 {
+				port = defaultPort
+			}
+			fmt.Fprintf(w, "LINK     %-8s TCP %s %d", link.To, link.Host, port)
+		default:
+			fmt.Fprintf(w, "LINK     %-8s %s %s", link.To, strings.ToUpper(string(link.Transport)), link.Host)
+		}
+		if link.BufferSize > 0 {
+			fmt.Fprintf(w, " BUFSIZE=%d", link.BufferSize)
+		}
+		fmt.Fprintln(w)
+	}
+
+	for _, route := range t.routes {
+		if route.Hops > 1 {
+			fmt.Fprintf(w, "ROUTE    %-8s %s\n", route.Destination, route.NextHop)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package njeconfig_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestNjeconfig(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "NJE Config Suite")
+}
@@ -0,0 +1,106 @@
+package njeconfig
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/nodes"
+	"sort"
+)
+
+// Route tells the local node which adjacent node to hand traffic for
+// Destination to.
+type Route struct {
+	Destination string
+	NextHop     string
+	Hops        int
+}
+
+// topology is the part of the link graph reachable from the local node.
+type topology struct {
+	local string
+	// adjacent holds the links of the local node, oriented so that To
+	// is the neighbour.
+	adjacent []*nodes.Link
+	// links holds every active link between reachable nodes.
+	links  []*nodes.Link
+	routes []Route
+}
+
+// buildTopology walks the link graph breadth first from local. Links
+// marked down are ignored, neighbours are visited in name order so the
+// generated configuration is stable.
+func buildTopology(local string, links []*nodes.Link) *topology {
+	neighbours := map[string][]*nodes.Link{}
+	for _, link := range links {
+		if link.Status == nodes.LinkDown {
+			continue
+		}
+		reverse := *link
+		reverse.From, reverse.To = link.To, link.From
+		neighbours[link.From] = append(neighbours[link.From], link)
+		neighbours[link.To] = append(neighbours[link.To], &reverse)
+	}
+	for _, adjacent := range neighbours {
+		sort.Slice(adjacent, func(i, j int) bool {
+			return adjacent[i].To < adjacent[j].To
+		})
+	}
+
+	result := &topology{
+		local:    local,
+		adjacent: neighbours[local],
+	}
+
+	nextHop := map[string]string{local: ""}
+	distance := map[string]int{local: 0}
+	queue := []string{local}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, link := range neighbours[current] {
+			if _, seen := nextHop[link.To]; seen {
+				continue
+			}
+			if current == local {
+				nextHop[link.To] = link.To
+			} else {
+				nextHop[link.To] = nextHop[current]
+			}
+			distance[link.To] = distance[current] + 1
+			queue = append(queue, link.To)
+			result.routes = append(result.routes, Route{
+				Destination: link.To,
+				NextHop:     nextHop[link.To],
+				Hops:        distance[link.To],
+			})
+		}
+	}
+
+	for _, link := range links {
+		if link.Status == nodes.LinkDown {
+			continue
+		}
+		if _, ok := nextHop[link.From]; ok {
+			result.links = append(result.links, link)
+		}
+	}
+	sort.Slice(result.links, func(i, j int) bool {
+		if result.links[i].From != result.links[j].From {
+			return result.links[i].From < result.links[j].From
+		}
+		return result.links[i].To < result.links[j].To
+	})
+	sort.Slice(result.routes, func(i, j int) bool {
+		return result.routes[i].Destination < result.routes[j].Destination
+	})
+
+	return result
+}
+
+// destinations returns the names of all reachable nodes except the
+// local one, in name order.
+func (t *topology) destinations() []string {
+	names := make([]string, len(t.routes))
+	for i, route := range t.routes {
+		names[i] = route.Destination
+	}
+	return names
+}
@@ -0,0 +1,656 @@
+package nodes_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/filestore"
+	"github.com/mvslovers/hnetdb/pkg/migrations"
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// storage bundles the repositories of one backend together with ways
+// to register and change users, which live outside this package.
+type storage struct {
+	nodes      NodeRepository
+	ownership  OwnershipRepository
+	links      LinkRepository
+	search     NodeSearcher
+	addUser    func(username string)
+	renameUser func(username string, newUsername string)
+	setContact func(username string, details users.ContactDetails)
+}
+
+// repositoryContract holds the specs every storage backend has to pass.
+// newStorage is called before each spec and has to return an empty
+// backend.
+func repositoryContract(newStorage func() *storage) {
+
+	var s *storage
+
+	node := func(name string) *Node {
+		return &Node{
+			Name:            name,
+			Platform:        "Hercules 4 on Linux",
+			OperatingSystem: "MVS3.8J",
+			Location:        "Germany",
+			Place:           &Place{Country: "DE"},
+		}
+	}
+
+	BeforeEach(func() {
+		s = newStorage()
+	})
+
+	Describe("nodes", func() {
+
+		It("saves and finds nodes", func() {
+			s.addUser("flo")
+			saved := node("DRNBRX1A")
+			saved.Alias = "DEBRXMVS"
+			saved.Owner = "flo"
+			saved.State = StatePending
+
+			Expect(s.nodes.Save(saved)).To(Succeed())
+
+			found, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found).To(Equal(saved))
+		})
+
+		It("keeps places", func() {
+			saved := node("DRNBRX1A")
+			saved.Location = "Brixton"
+			saved.Place = &Place{Country: "GB", City: "London", Coordinates: &Coordinates{Latitude: 51.46, Longitude: -0.115}}
+			Expect(s.nodes.Save(saved)).To(Succeed())
+
+			found, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found.Place).To(Equal(saved.Place))
+
+			saved.Place = nil
+			saved.Location = "Toronto, Canada"
+			Expect(s.nodes.Update("DRNBRX1A", saved)).To(Succeed())
+
+			found, err = s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found.Place).To(Equal(&Place{Country: "CA", City: "Toronto"}), "Place should be parsed")
+		})
+
+		It("drops owners that are not registered", func() {
+			saved := node("DRNBRX1A")
+			saved.Owner = "nobody"
+
+			Expect(s.nodes.Save(saved)).To(Succeed())
+
+			found, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found.Owner).To(BeEmpty())
+		})
+
+		It("rejects duplicate names", func() {
+			Expect(s.nodes.Save(node("DRNBRX1A"))).To(Succeed())
+
+			Expect(s.nodes.Save(node("DRNBRX1A"))).To(Equal(ErrNodeExists))
+		})
+
+		It("reports unknown nodes", func() {
+			_, err := s.nodes.FindByName("UNKNOWN")
+			Expect(err).To(Equal(ErrNodeNotFound))
+
+			Expect(s.nodes.DeleteByName("UNKNOWN")).To(Equal(ErrNodeNotFound))
+			Expect(s.nodes.Update("UNKNOWN", node("UNKNOWN"))).To(Equal(ErrNodeNotFound))
+			Expect(s.nodes.SetState("UNKNOWN", StateApproved, "")).To(Equal(ErrNodeNotFound))
+		})
+
+		It("lists nodes by state", func() {
+			legacy := node("LEGACY")
+			pending := node("PENDING")
+			pending.State = StatePending
+			Expect(s.nodes.Save(legacy)).To(Succeed())
+			Expect(s.nodes.Save(pending)).To(Succeed())
+
+			all, err := s.nodes.FindAll()
+			Expect(err).To(BeNil())
+			Expect(all).To(ConsistOf(legacy, pending))
+
+			approved, err := s.nodes.FindAllByState(StateApproved)
+			Expect(err).To(BeNil())
+			Expect(approved).To(ConsistOf(legacy))
+
+			Expect(s.nodes.SetState("PENDING", StateRejected, "no contact")).To(Succeed())
+
+			rejected, err := s.nodes.FindAllByState(StateRejected)
+			Expect(err).To(BeNil())
+			Expect(rejected).To(HaveLen(1))
+			Expect(rejected[0].StateReason).To(Equal("no contact"))
+		})
+
+		It("renames nodes together with their links and owner", func() {
+			s.addUser("flo")
+			owned := node("DRNBRX1A")
+			owned.Owner = "flo"
+			Expect(s.nodes.Save(owned)).To(Succeed())
+			Expect(s.nodes.Save(node("DRNBRX2A"))).To(Succeed())
+			Expect(s.links.Save(&Link{From: "DRNBRX1A", To: "DRNBRX2A", Transport: TransportCTC,
+				Host: "0E40", Status: LinkUp})).To(Succeed())
+
+			renamed := node("DRNBRX3A")
+			Expect(s.nodes.Update("DRNBRX1A", renamed)).To(Succeed())
+
+			found, err := s.nodes.FindByName("DRNBRX3A")
+			Expect(err).To(BeNil())
+			Expect(found.Owner).To(Equal("flo"))
+			_, err = s.links.FindByNodes("DRNBRX3A", "DRNBRX2A")
+			Expect(err).To(BeNil())
+
+			Expect(s.nodes.Update("DRNBRX3A", node("DRNBRX2A"))).To(Equal(ErrNodeExists))
+		})
+
+		It("deletes nodes together with their links", func() {
+			Expect(s.nodes.Save(node("DRNBRX1A"))).To(Succeed())
+			Expect(s.nodes.Save(node("DRNBRX2A"))).To(Succeed())
+			Expect(s.links.Save(&Link{From: "DRNBRX1A", To: "DRNBRX2A", Transport: TransportCTC,
+				Host: "0E40", Status: LinkUp})).To(Succeed())
+
+			Expect(s.nodes.DeleteByName("DRNBRX1A")).To(Succeed())
+
+			_, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(Equal(ErrNodeNotFound))
+			links, err := s.links.FindAll()
+			Expect(err).To(BeNil())
+			Expect(links).To(BeEmpty())
+		})
+	})
+
+	Describe("queries", func() {
+
+		BeforeEach(func() {
+			for _, saved := range []*Node{
+				{Name: "DRNBRX1A", Alias: "BRX", Platform: "Hercules", OperatingSystem: "MVS3.8J", Location: "Germany", IsGateway: true},
+				{Name: "DRNBRX2A", Platform: "Hercules", OperatingSystem: "VM/370", Location: "Germany"},
+				{Name: "DRNMIG1A", Platform: "Hercules", OperatingSystem: "MVS3.8J", Location: "Canada"},
+				{Name: "DRNMIG2A", Platform: "z/PDT", OperatingSystem: "z/OS", Location: "Canada", State: StatePending},
+				{Name: "SWIVM1", Platform: "Hercules", OperatingSystem: "VM/370", Location: "Austria"},
+			} {
+				Expect(s.nodes.Save(saved)).To(Succeed())
+			}
+		})
+
+		names := func(page *NodePage) []string {
+			var result []string
+			for _, node := range page.Nodes {
+				result = append(result, node.Name)
+			}
+			return result
+		}
+
+		It("filters nodes", func() {
+			gateway := true
+			for _, example := range []struct {
+				query *NodeQuery
+				names []string
+			}{
+				{&NodeQuery{State: StateApproved}, []string{"DRNBRX1A", "DRNBRX2A", "DRNMIG1A", "SWIVM1"}},
+				{&NodeQuery{State: StateApproved, OperatingSystem: "VM/370"}, []string{"DRNBRX2A", "SWIVM1"}},
+				{&NodeQuery{State: StateApproved, Location: "Canada"}, []string{"DRNMIG1A"}},
+				{&NodeQuery{Platform: "z/PDT"}, []string{"DRNMIG2A"}},
+				{&NodeQuery{Gateway: &gateway}, []string{"DRNBRX1A"}},
+				{&NodeQuery{Text: "brx"}, []string{"DRNBRX1A", "DRNBRX2A"}},
+				{&NodeQuery{Text: "many"}, []string{"DRNBRX1A", "DRNBRX2A"}},
+			} {
+				page, err := s.nodes.Query(example.query)
+
+				Expect(err).To(BeNil())
+				Expect(names(page)).To(Equal(example.names), "%+v", example.query)
+				Expect(page.Total).To(Equal(len(example.names)))
+				Expect(page.NextCursor).To(BeEmpty())
+			}
+		})
+
+		It("sorts nodes by name within equal keys", func() {
+			page, err := s.nodes.Query(&NodeQuery{State: StateApproved, Sort: "-location"})
+
+			Expect(err).To(BeNil())
+			Expect(names(page)).To(Equal([]string{"DRNBRX1A", "DRNBRX2A", "DRNMIG1A", "SWIVM1"}))
+
+			page, err = s.nodes.Query(&NodeQuery{State: StateApproved, Sort: "os"})
+
+			Expect(err).To(BeNil())
+			Expect(names(page)).To(Equal([]string{"DRNBRX1A", "DRNMIG1A", "DRNBRX2A", "SWIVM1"}))
+		})
+
+		It("pages through nodes", func() {
+			query := &NodeQuery{State: StateApproved, Sort: "location", Limit: 3}
+			var pages [][]string
+			for {
+				page, err := s.nodes.Query(query)
+				Expect(err).To(BeNil())
+				Expect(page.Total).To(Equal(4))
+				pages = append(pages, names(page))
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+
+			Expect(pages).To(Equal([][]string{{"SWIVM1", "DRNMIG1A", "DRNBRX1A"}, {"DRNBRX2A"}}))
+		})
+
+		It("finds nodes near coordinates", func() {
+			for name, coordinates := range map[string]*Coordinates{
+				"BRIXTON": {Latitude: 51.4613, Longitude: -0.1156},
+				"CAMDEN":  {Latitude: 51.5390, Longitude: -0.1426},
+				"BERLIN":  {Latitude: 52.5200, Longitude: 13.4050},
+			} {
+				saved := node(name)
+				saved.Place = &Place{Country: "GB", Coordinates: coordinates}
+				Expect(s.nodes.Save(saved)).To(Succeed())
+			}
+			brixton := &Coordinates{Latitude: 51.4613, Longitude: -0.1156}
+
+			page, err := s.nodes.Query(&NodeQuery{Near: brixton, Radius: 5})
+			Expect(err).To(BeNil())
+			Expect(names(page)).To(Equal([]string{"BRIXTON"}))
+
+			page, err = s.nodes.Query(&NodeQuery{Near: brixton, Radius: 10})
+			Expect(err).To(BeNil())
+			Expect(names(page)).To(Equal([]string{"BRIXTON", "CAMDEN"}))
+
+			page, err = s.nodes.Query(&NodeQuery{Near: brixton, Radius: 1000})
+			Expect(err).To(BeNil())
+			Expect(names(page)).To(Equal([]string{"BERLIN", "BRIXTON", "CAMDEN"}))
+		})
+
+		It("rejects cursors of other sort orders", func() {
+			page, err := s.nodes.Query(&NodeQuery{Limit: 1})
+			Expect(err).To(BeNil())
+
+			_, err = s.nodes.Query(&NodeQuery{Limit: 1, Sort: "os", Cursor: page.NextCursor})
+
+			Expect(err).To(Equal(&ValidationError{Field: "cursor", Message: "is not valid for this query"}))
+		})
+	})
+
+	Describe("search", func() {
+
+		BeforeEach(func() {
+			for _, saved := range []*Node{
+				{Name: "DRNBRX1A", Alias: "BRX", Platform: "Hercules 4", OperatingSystem: "MVS3.8J", Location: "London Brixton"},
+				{Name: "DRNBRX2A", Platform: "Hercules 4", OperatingSystem: "VM/370", Location: "London Camden"},
+				{Name: "DRNMIG1A", Alias: "MIG", Platform: "z/PDT", OperatingSystem: "z/OS", Location: "Toronto"},
+				{Name: "DRNMIG2A", Platform: "Hercules 4", OperatingSystem: "MVS3.8J", Location: "Mighty Oaks"},
+				{Name: "DRNBRX3A", Platform: "Hercules 4", OperatingSystem: "MVS3.8J", Location: "Brixton", State: StatePending},
+			} {
+				Expect(s.nodes.Save(saved)).To(Succeed())
+			}
+		})
+
+		names := func(results []*SearchResult) []string {
+			var names []string
+			for _, result := range results {
+				names = append(names, result.Node.Name)
+			}
+			return names
+		}
+
+		It("finds approved nodes by every word, prefix or with typos", func() {
+			for text, expected := range map[string][]string{
+				"brixton":        {"DRNBRX1A"},
+				"Brix":           {"DRNBRX1A"},
+				"brixtn":         {"DRNBRX1A"},
+				"London":         {"DRNBRX1A", "DRNBRX2A"},
+				"london camden":  {"DRNBRX2A"},
+				"mvs3.8j London": {"DRNBRX1A"},
+				"z/os":           {"DRNMIG1A"},
+				"paris":          nil,
+			} {
+				results, err := s.search.Search(&NodeSearch{Text: text})
+
+				Expect(err).To(BeNil())
+				Expect(names(results)).To(ConsistOf(expected), text)
+			}
+		})
+
+		It("ranks matches of better fields and words first", func() {
+			results, err := s.search.Search(&NodeSearch{Text: "mig"})
+
+			Expect(err).To(BeNil())
+			Expect(names(results)).To(Equal([]string{"DRNMIG1A", "DRNMIG2A"}))
+			Expect(results[0].Score).To(BeNumerically(">", results[1].Score))
+
+			results, err = s.search.Search(&NodeSearch{Text: "mig", Limit: 1})
+
+			Expect(err).To(BeNil())
+			Expect(names(results)).To(Equal([]string{"DRNMIG1A"}))
+
+			results, err = s.search.Search(&NodeSearch{Text: "drnbrx1a"})
+
+			Expect(err).To(BeNil())
+			Expect(names(results)).To(Equal([]string{"DRNBRX1A", "DRNBRX2A"}))
+		})
+
+		It("highlights matched words", func() {
+			results, err := s.search.Search(&NodeSearch{Text: "brx londn"})
+
+			Expect(err).To(BeNil())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Highlights).To(Equal([]*Highlight{
+				{Field: "alias", Parts: []*HighlightPart{{Text: "BRX", Match: true}}},
+				{Field: "location", Parts: []*HighlightPart{{Text: "London", Match: true}, {Text: " Brixton"}}},
+			}))
+		})
+	})
+
+	Describe("ownership", func() {
+
+		BeforeEach(func() {
+			s.addUser("flo")
+			s.addUser("bob")
+			owned := node("DRNBRX1A")
+			owned.Owner = "flo"
+			Expect(s.nodes.Save(owned)).To(Succeed())
+		})
+
+		It("manages maintainers", func() {
+			Expect(s.ownership.AddMaintainer("DRNBRX1A", "bob")).To(Succeed())
+			Expect(s.ownership.AddMaintainer("DRNBRX1A", "bob")).To(Succeed())
+			Expect(s.ownership.AddMaintainer("DRNBRX1A", "flo")).To(Succeed())
+
+			found, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found.Maintainers).To(Equal([]string{"bob"}))
+
+			nodes, err := s.ownership.FindByUser("bob")
+			Expect(err).To(BeNil())
+			Expect(nodes).To(HaveLen(1))
+
+			Expect(s.ownership.RemoveMaintainer("DRNBRX1A", "bob")).To(Succeed())
+			Expect(s.ownership.RemoveMaintainer("DRNBRX1A", "bob")).To(Equal(ErrUserNotFound))
+		})
+
+		It("transfers ownership", func() {
+			Expect(s.ownership.AddMaintainer("DRNBRX1A", "bob")).To(Succeed())
+
+			Expect(s.ownership.TransferOwnership("DRNBRX1A", "bob")).To(Succeed())
+
+			found, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found.Owner).To(Equal("bob"))
+			Expect(found.Maintainers).To(BeNil())
+			nodes, err := s.ownership.FindByUser("flo")
+			Expect(err).To(BeNil())
+			Expect(nodes).To(BeEmpty())
+		})
+
+		It("shows the contact details of owners", func() {
+			s.setContact("flo", users.ContactDetails{DisplayName: "Florent", Callsign: "DL1ABC"})
+
+			found, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found.OwnerContact).To(Equal(&OwnerContact{DisplayName: "Florent", Callsign: "DL1ABC"}))
+			all, err := s.nodes.FindAll()
+			Expect(err).To(BeNil())
+			Expect(all[0].OwnerContact).To(Equal(found.OwnerContact))
+		})
+
+		It("follows renamed users", func() {
+			Expect(s.ownership.AddMaintainer("DRNBRX1A", "bob")).To(Succeed())
+
+			s.renameUser("flo", "florent")
+			s.renameUser("bob", "robert")
+			Expect(s.ownership.RenameUser("flo", "florent")).To(Succeed())
+			Expect(s.ownership.RenameUser("bob", "robert")).To(Succeed())
+
+			found, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found.Owner).To(Equal("florent"))
+			Expect(found.Maintainers).To(Equal([]string{"robert"}))
+		})
+
+		It("hands the nodes of released users to their first maintainer", func() {
+			s.addUser("alice")
+			Expect(s.ownership.AddMaintainer("DRNBRX1A", "bob")).To(Succeed())
+			Expect(s.ownership.AddMaintainer("DRNBRX1A", "alice")).To(Succeed())
+			unmaintained := node("DRNBRX2A")
+			unmaintained.Owner = "flo"
+			Expect(s.nodes.Save(unmaintained)).To(Succeed())
+			maintained := node("DRNBRX3A")
+			maintained.Owner = "bob"
+			Expect(s.nodes.Save(maintained)).To(Succeed())
+			Expect(s.ownership.AddMaintainer("DRNBRX3A", "flo")).To(Succeed())
+
+			Expect(s.ownership.ReleaseUser("flo")).To(Succeed())
+
+			found, err := s.nodes.FindByName("DRNBRX1A")
+			Expect(err).To(BeNil())
+			Expect(found.Owner).To(Equal("alice"))
+			Expect(found.Maintainers).To(Equal([]string{"bob"}))
+			found, err = s.nodes.FindByName("DRNBRX2A")
+			Expect(err).To(BeNil())
+			Expect(found.Owner).To(BeEmpty())
+			found, err = s.nodes.FindByName("DRNBRX3A")
+			Expect(err).To(BeNil())
+			Expect(found.Owner).To(Equal("bob"))
+			Expect(found.Maintainers).To(BeNil())
+			nodes, err := s.ownership.FindByUser("flo")
+			Expect(err).To(BeNil())
+			Expect(nodes).To(BeEmpty())
+		})
+
+		It("reports unknown nodes and users", func() {
+			Expect(s.ownership.AddMaintainer("UNKNOWN", "bob")).To(Equal(ErrNodeNotFound))
+			Expect(s.ownership.AddMaintainer("DRNBRX1A", "nobody")).To(Equal(ErrUserNotFound))
+			Expect(s.ownership.TransferOwnership("DRNBRX1A", "nobody")).To(Equal(ErrUserNotFound))
+		})
+	})
+
+	Describe("links", func() {
+
+		var link *Link
+
+		BeforeEach(func() {
+			for _, name := range []string{"A", "B", "C", "D"} {
+				Expect(s.nodes.Save(node(name))).To(Succeed())
+			}
+			link = &Link{From: "A", To: "B", Transport: TransportTCP, Host: "b.example.org",
+				Port: 175, BufferSize: 8192, Status: LinkUp}
+			Expect(s.links.Save(link)).To(Succeed())
+		})
+
+		It("finds links in both directions", func() {
+			found, err := s.links.FindByNodes("B", "A")
+
+			Expect(err).To(BeNil())
+			Expect(found).To(Equal(link))
+		})
+
+		It("rejects duplicate links and unknown nodes", func() {
+			Expect(s.links.Save(&Link{From: "B", To: "A", Transport: TransportCTC, Host: "0E40",
+				Status: LinkUp})).To(Equal(ErrLinkExists))
+			Expect(s.links.Save(&Link{From: "A", To: "UNKNOWN", Transport: TransportCTC, Host: "0E40",
+				Status: LinkUp})).To(Equal(ErrNodeNotFound))
+		})
+
+		It("updates and deletes links", func() {
+			Expect(s.links.Update(&Link{From: "B", To: "A", Transport: TransportTCP,
+				Host: "b.example.org", Port: 1175, Status: LinkDown})).To(Succeed())
+
+			found, err := s.links.FindByNodes("A", "B")
+			Expect(err).To(BeNil())
+			Expect(found).To(Equal(&Link{From: "A", To: "B", Transport: TransportTCP,
+				Host: "b.example.org", Port: 1175, Status: LinkDown}))
+
+			Expect(s.links.DeleteByNodes("B", "A")).To(Succeed())
+			Expect(s.links.DeleteByNodes("B", "A")).To(Equal(ErrLinkNotFound))
+			_, err = s.links.FindByNodes("A", "B")
+			Expect(err).To(Equal(ErrLinkNotFound))
+		})
+
+		It("finds the shortest usable route", func() {
+			for _, l := range []*Link{
+				{From: "B", To: "C", Transport: TransportCTC, Host: "0E40", Status: LinkUp},
+				{From: "C", To: "D", Transport: TransportCTC, Host: "0E41", Status: LinkUp},
+				{From: "A", To: "D", Transport: TransportCTC, Host: "0E42", Status: LinkDown},
+			} {
+				Expect(s.links.Save(l)).To(Succeed())
+			}
+
+			route, err := s.links.FindRoute("A", "D", RouteOptions{})
+			Expect(err).To(BeNil())
+			Expect(hopNames(route)).To(Equal([]string{"A", "B", "C", "D"}))
+
+			route, err = s.links.FindRoute("A", "D", RouteOptions{IncludeDown: true})
+			Expect(err).To(BeNil())
+			Expect(hopNames(route)).To(Equal([]string{"A", "D"}))
+
+			_, err = s.links.FindRoute("A", "D", RouteOptions{Exclude: []string{"C"}})
+			Expect(err).To(Equal(ErrRouteNotFound))
+
+			Expect(s.nodes.SetState("B", StatePending, "")).To(Succeed())
+			_, err = s.links.FindRoute("A", "D", RouteOptions{})
+			Expect(err).To(Equal(ErrRouteNotFound))
+
+			_, err = s.links.FindRoute("A", "UNKNOWN", RouteOptions{})
+			Expect(err).To(Equal(ErrNodeNotFound))
+		})
+	})
+}
+
+func hopNames(route *Route) []string {
+	var names []string
+	for _, hop := range route.Hops {
+		names = append(names, hop.Name)
+	}
+	return names
+}
+
+var _ = Describe("In-memory storage", func() {
+
+	repositoryContract(func() *storage {
+		registered := map[string]bool{}
+		contacts := map[string]*OwnerContact{}
+		graph := NewMemoryGraph()
+		graph.UserExists = func(username string) bool {
+			return registered[username]
+		}
+		graph.OwnerContact = func(username string) *OwnerContact {
+			return contacts[username]
+		}
+		return &storage{
+			nodes:     &NodeMemoryRepository{Graph: graph},
+			ownership: &NodeMemoryRepository{Graph: graph},
+			links:     &LinkMemoryRepository{Graph: graph},
+			search:    &NodeMemoryRepository{Graph: graph},
+			addUser: func(username string) {
+				registered[username] = true
+			},
+			renameUser: func(username string, newUsername string) {
+				delete(registered, username)
+				registered[newUsername] = true
+			},
+			setContact: func(username string, details users.ContactDetails) {
+				contacts[username] = &OwnerContact{
+					DisplayName: details.DisplayName,
+					Callsign:    details.Callsign,
+					Contact:     details.Contact,
+				}
+			},
+		}
+	})
+})
+
+var _ = Describe("File storage", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "filestore")
+		Expect(err).To(BeNil(), "Directory should be created")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	repositoryContract(func() *storage {
+		store, err := filestore.Open(filepath.Join(dir, "hnetdb.json"))
+		Expect(err).To(BeNil(), "Store should open")
+		return &storage{
+			nodes:     store.Nodes(),
+			ownership: store.Nodes(),
+			links:     store.Links(),
+			search:    store.Nodes(),
+			addUser: func(username string) {
+				Expect(store.Users().RegisterUser(&users.User{
+					Username: username,
+					Email:    username + "@example.org",
+					Password: "password",
+				})).To(Succeed())
+			},
+			renameUser: func(username string, newUsername string) {
+				Expect(store.Users().Rename(username, newUsername)).To(Succeed())
+			},
+			setContact: func(username string, details users.ContactDetails) {
+				Expect(store.Users().UpdateContactDetails(username, details)).To(Succeed())
+			},
+		}
+	})
+})
+
+var _ = Describe("Neo4j repository contract", func() {
+
+	run := func(query string, parameters map[string]interface{}) {
+		session := driver.NewSession(neo4j.SessionConfig{})
+		defer Close(session, "Session")
+
+		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			_, err := tx.Run(query, parameters)
+			return nil, err
+		})
+		Expect(err).To(BeNil(), "Query should run")
+	}
+
+	BeforeEach(func() {
+		runner := &migrations.Runner{Driver: driver, Migrations: migrations.Builtin}
+		_, err := runner.Up()
+		Expect(err).To(BeNil(), "Migrations should be applied")
+	})
+
+	AfterEach(func() {
+		run("MATCH (n) WHERE n:Node OR n:User DETACH DELETE n", nil)
+	})
+
+	repositoryContract(func() *storage {
+		nodeRepository := &NodeNeo4jRepository{Driver: driver}
+		return &storage{
+			nodes:     nodeRepository,
+			ownership: nodeRepository,
+			links:     &LinkNeo4jRepository{Driver: driver},
+			search:    nodeRepository,
+			addUser: func(username string) {
+				run("CREATE (:User {username: $username, email: $username + '@example.org'})",
+					map[string]interface{}{"username": username})
+			},
+			renameUser: func(username string, newUsername string) {
+				run("MATCH (u:User {username: $username}) SET u.username = $newUsername",
+					map[string]interface{}{"username": username, "newUsername": newUsername})
+			},
+			setContact: func(username string, details users.ContactDetails) {
+				run("MATCH (u:User {username: $username}) "+
+					"SET u.display_name = $display_name, u.callsign = $callsign, u.contact = $contact",
+					map[string]interface{}{
+						"username":     username,
+						"display_name": details.DisplayName,
+						"callsign":     details.Callsign,
+						"contact":      details.Contact,
+					})
+			},
+		}
+	})
+})
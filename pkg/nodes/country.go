@@ -0,0 +1,67 @@
+package nodes
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+	"sort"
+)
+
+// CountryCount tells how many nodes are in a country, e.g. for drawing
+// a map of the network.
+type CountryCount struct {
+	Country  string `json:"country"`
+	Name     string `json:"name"`
+	Nodes    int    `json:"nodes"`
+	Gateways int    `json:"gateways"`
+}
+
+// CountByCountry counts nodes by the country of their place, in order
+// of the country codes. Nodes without a place are not counted.
+func CountByCountry(nodes []*Node) []*CountryCount {
+	counts := map[string]*CountryCount{}
+	for _, node := range nodes {
+		place := placeOf(node)
+		if place == nil {
+			continue
+		}
+		count, ok := counts[place.Country]
+		if !ok {
+			count = &CountryCount{Country: place.Country, Name: CountryName(place.Country)}
+			counts[place.Country] = count
+		}
+		count.Nodes++
+		if node.IsGateway {
+			count.Gateways++
+		}
+	}
+
+	result := []*CountryCount{}
+	for _, count := range counts {
+		result = append(result, count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Country < result[j].Country
+	})
+	return result
+}
+
+type CountryHandler struct {
+	Path           string
+	NodeRepository NodeRepository
+}
+
+// Countries answers with the approved nodes counted by country.
+func (h *CountryHandler) Countries(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	approved, err := h.NodeRepository.FindAllByState(StateApproved)
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, CountByCountry(approved))
+}
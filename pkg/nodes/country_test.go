@@ -0,0 +1,45 @@
+package nodes_test
+
+import (
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+)
+
+var _ = Describe("Countries", func() {
+
+	It("counts nodes and gateways by country", func() {
+		counts := CountByCountry([]*Node{
+			{Name: "DRNBRX1A", Location: "Germany", IsGateway: true},
+			{Name: "DRNBRX2A", Place: &Place{Country: "DE", City: "Berlin"}},
+			{Name: "DRNMIG1A", Location: "Toronto, Canada"},
+			{Name: "UNKNOWN", Location: "somewhere"},
+		})
+
+		Expect(counts).To(Equal([]*CountryCount{
+			{Country: "CA", Name: "Canada", Nodes: 1},
+			{Country: "DE", Name: "Germany", Nodes: 2, Gateways: 1},
+		}))
+	})
+
+	It("serves the counts of approved nodes", func() {
+		repository := &FakeNodeRepository{Nodes: map[string]*Node{
+			"DRNBRX1A": {Name: "DRNBRX1A", Location: "Germany", State: StateApproved},
+			"DRNBRX2A": {Name: "DRNBRX2A", Location: "Germany", State: StatePending},
+		}}
+		handler := &CountryHandler{Path: "/countries", NodeRepository: repository}
+		recorder := httptest.NewRecorder()
+
+		handler.Countries(recorder, httptest.NewRequest("GET", "/countries", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(MatchJSON(
+			`[{"country": "DE", "name": "Germany", "nodes": 1, "gateways": 0}]`))
+
+		recorder = httptest.NewRecorder()
+		handler.Countries(recorder, httptest.NewRequest("POST", "/countries", nil))
+
+		Expect(recorder.Code).To(Equal(405))
+	})
+})
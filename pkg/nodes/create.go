@@ -2,8 +2,11 @@ package nodes
 
 import (
 	"encoding/json"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"github.com/mvslovers/hnetdb/pkg/users"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 )
 
 type NewNodeHandler struct {
@@ -15,18 +18,12 @@ func (h *NewNodeHandler) New(writer http.ResponseWriter, request *http.Request)
 	method := request.Method
 
 	if method == "GET" {
-		all, _ := h.NodeRepository.FindAll()
-		writer.Header().Add("Content-Type", "application/json")
-		writer.WriteHeader(http.StatusOK)
-
-		bytes, _ := json.Marshal(&all)
-		_, _ = writer.Write(bytes)
-
+		h.list(writer, request)
 		return
 	}
 
 	if method != "POST" {
-		writer.WriteHeader(http.StatusMethodNotAllowed)
+		apierror.MethodNotAllowed(writer)
 		return
 	}
 
@@ -34,18 +31,59 @@ func (h *NewNodeHandler) New(writer http.ResponseWriter, request *http.Request)
 	nodeRequest := Node{}
 	err := json.Unmarshal(requestBody, &nodeRequest)
 	if err != nil {
-		writer.WriteHeader(http.StatusBadRequest)
+		apierror.MalformedJSON(writer)
+		return
+	}
+
+	user, _ := users.UserFromContext(request.Context())
+	if user == nil || user.Username == "" {
+		apierror.Unauthorized(writer)
+		return
+	}
+	if !user.Verified {
+		apierror.Write(writer, http.StatusForbidden, "email_unverified",
+			"verify your email address before registering nodes")
+		return
+	}
+	nodeRequest.Owner = user.Username
+	nodeRequest.Maintainers = nil
+	nodeRequest.State = StatePending
+	nodeRequest.StateReason = ""
+
+	if err := nodeRequest.Validate(); err != nil {
+		writeNodeError(writer, err)
 		return
 	}
 
 	err = h.NodeRepository.Save(&nodeRequest)
 	if err != nil {
-		writer.WriteHeader(http.StatusConflict)
+		writeNodeError(writer, err)
 		return
 	}
 
-	writer.WriteHeader(201)
-	writer.Header().Add("Content-Type", "application/json")
-	bytes, _ := json.Marshal(&nodeRequest)
-	_, _ = writer.Write(bytes)
+	writeJSON(writer, http.StatusCreated, &nodeRequest)
+}
+
+// list answers with a page of the approved nodes, see ParseNodeQuery. The
+// number of matching nodes is told in X-Total-Count, the next page is
+// linked to in Link.
+func (h *NewNodeHandler) list(writer http.ResponseWriter, request *http.Request) {
+	query, err := ParseNodeQuery(request.URL.Query())
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+	page, err := h.NodeRepository.Query(query)
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+
+	writer.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		next := *query
+		next.Cursor = page.NextCursor
+		writer.Header().Set("Link", "<"+h.Path+"?"+next.Values().Encode()+`>; rel="next"`)
+	}
+	writeJSON(writer, http.StatusOK, page.Nodes)
 }
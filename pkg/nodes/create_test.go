@@ -1,7 +1,131 @@
 package nodes_test
 
-import . "github.com/onsi/ginkgo"
+import (
+	"encoding/json"
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"strings"
+)
 
 var _ = Describe("Nodes", func() {
 
+	var repository *FakeNodeRepository
+	var handler *NewNodeHandler
+
+	BeforeEach(func() {
+		repository = &FakeNodeRepository{
+			Nodes: map[string]*Node{},
+		}
+		handler = &NewNodeHandler{
+			Path:           "/node",
+			NodeRepository: repository,
+		}
+	})
+
+	It("creates nodes owned by the logged in user", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.New(recorder, as("flo", httptest.NewRequest("POST", "/node",
+			strings.NewReader(`{"name":"DRNBRX1A","platform":"Linux","os":"MVS3.8J","owner":"evil"}`))))
+
+		Expect(recorder.Code).To(Equal(201))
+		Expect(repository.Nodes["DRNBRX1A"].Owner).To(Equal("flo"))
+		Expect(repository.Nodes["DRNBRX1A"].State).To(Equal(StatePending))
+	})
+
+	It("lists approved nodes only", func() {
+		repository.Nodes["DRNBRX1A"] = &Node{Name: "DRNBRX1A", State: StateApproved}
+		repository.Nodes["DRNMIG1A"] = &Node{Name: "DRNMIG1A", State: StatePending}
+		recorder := httptest.NewRecorder()
+
+		handler.New(recorder, httptest.NewRequest("GET", "/node", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		var listed []*Node
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &listed)).To(Succeed())
+		Expect(listed).To(Equal([]*Node{{Name: "DRNBRX1A", State: StateApproved}}))
+	})
+
+	It("pages through the node list", func() {
+		for _, name := range []string{"DRNBRX1A", "DRNBRX2A", "DRNMIG1A"} {
+			repository.Nodes[name] = &Node{Name: name, OperatingSystem: "MVS3.8J", State: StateApproved}
+		}
+		recorder := httptest.NewRecorder()
+
+		handler.New(recorder, httptest.NewRequest("GET", "/node?os=MVS3.8J&limit=2", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Header().Get("X-Total-Count")).To(Equal("3"))
+		var listed []*Node
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &listed)).To(Succeed())
+		Expect(listed).To(HaveLen(2))
+		link := recorder.Header().Get("Link")
+		Expect(link).To(MatchRegexp(`^</node\?cursor=[^>]+&limit=2&os=MVS3.8J>; rel="next"$`))
+
+		recorder = httptest.NewRecorder()
+		handler.New(recorder, httptest.NewRequest("GET", link[1:strings.Index(link, ">")], nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &listed)).To(Succeed())
+		Expect(listed).To(Equal([]*Node{repository.Nodes["DRNMIG1A"]}))
+		Expect(recorder.Header().Get("Link")).To(BeEmpty())
+	})
+
+	It("rejects invalid list parameters", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.New(recorder, httptest.NewRequest("GET", "/node?limit=0", nil))
+
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"limit"`))
+	})
+
+	It("requires a logged in user", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.New(recorder, httptest.NewRequest("POST", "/node",
+			strings.NewReader(`{"name":"DRNBRX1A","platform":"Linux","os":"MVS3.8J"}`)))
+
+		Expect(recorder.Code).To(Equal(401))
+		Expect(repository.Nodes).To(BeEmpty())
+	})
+
+	It("refuses nodes of users with unverified email addresses", func() {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("POST", "/node",
+			strings.NewReader(`{"name":"DRNBRX1A","platform":"Linux","os":"MVS3.8J"}`))
+
+		handler.New(recorder, request.WithContext(users.ContextWithUser(request.Context(),
+			&users.User{Username: "flo", Role: users.RoleMember})))
+
+		Expect(recorder.Code).To(Equal(403))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"email_unverified"`))
+		Expect(repository.Nodes).To(BeEmpty())
+	})
+
+	It("rejects invalid node names", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.New(recorder, as("flo", httptest.NewRequest("POST", "/node",
+			strings.NewReader(`{"name":"DRN-BRX-1A","platform":"Linux","os":"MVS3.8J"}`))))
+
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"name"`))
+		Expect(repository.Nodes).To(BeEmpty())
+	})
+
+	It("reports duplicate nodes", func() {
+		repository.Nodes["DRNBRX1A"] = &Node{Name: "DRNBRX1A"}
+		recorder := httptest.NewRecorder()
+
+		handler.New(recorder, as("flo", httptest.NewRequest("POST", "/node",
+			strings.NewReader(`{"name":"drnbrx1a","platform":"Linux","os":"MVS3.8J"}`))))
+
+		Expect(recorder.Code).To(Equal(409))
+		Expect(recorder.Body.String()).To(MatchJSON(
+			`{"error": {"code": "node_exists", "message": "a node with this name exists already"}}`))
+	})
 })
@@ -0,0 +1,68 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var (
+	ErrNodeNotFound  = errors.New("node not found")
+	ErrNodeExists    = errors.New("node already exists")
+	ErrLinkNotFound  = errors.New("link not found")
+	ErrLinkExists    = errors.New("link already exists")
+	ErrRouteNotFound = errors.New("no route between nodes")
+	ErrUserNotFound  = errors.New("user not found")
+)
+
+// ValidationError reports an attribute that breaks the NJE naming rules
+// or is missing.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// BackendError wraps failures of the storage backend, as opposed to
+// errors caused by the request.
+type BackendError struct {
+	Err error
+}
+
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("backend failure: %v", e.Err)
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// translate maps errors of the Neo4j driver on writes that may break a
+// uniqueness constraint to duplicate, see backendError for the others.
+func translate(err error, duplicate error) error {
+	if neo4jError, ok := err.(*neo4j.Neo4jError); ok &&
+		neo4jError.Code == "Neo.ClientError.Schema.ConstraintValidationFailed" {
+		return duplicate
+	}
+	return backendError(err)
+}
+
+// backendError passes the errors of this package through and wraps all
+// others in a BackendError.
+func backendError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var validationError *ValidationError
+	switch {
+	case errors.Is(err, ErrNodeNotFound), errors.Is(err, ErrNodeExists),
+		errors.Is(err, ErrLinkNotFound), errors.Is(err, ErrLinkExists),
+		errors.Is(err, ErrRouteNotFound), errors.Is(err, ErrUserNotFound),
+		errors.As(err, &validationError):
+		return err
+	}
+	return &BackendError{Err: err}
+}
@@ -0,0 +1,28 @@
+package nodes
+
+import (
+	"encoding/json"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"io/ioutil"
+	"net/http"
+)
+
+func readJSON(writer http.ResponseWriter, request *http.Request, value interface{}) bool {
+	requestBody, _ := ioutil.ReadAll(request.Body)
+	if err := json.Unmarshal(requestBody, value); err != nil {
+		apierror.MalformedJSON(writer)
+		return false
+	}
+	return true
+}
+
+func writeJSON(writer http.ResponseWriter, status int, value interface{}) {
+	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	bytes, _ := json.Marshal(value)
+	_, _ = writer.Write(bytes)
+}
+
+func writeValidationError(writer http.ResponseWriter, err *ValidationError) {
+	apierror.Invalid(writer, apierror.FieldError{Field: err.Field, Message: err.Message})
+}
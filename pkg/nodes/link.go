@@ -0,0 +1,81 @@
+package nodes
+
+import "fmt"
+
+type Transport string
+
+const (
+	TransportTCP Transport = "tcpnje"
+	TransportBSC Transport = "bsc"
+	TransportCTC Transport = "ctc"
+)
+
+type LinkStatus string
+
+const (
+	LinkUp   LinkStatus = "up"
+	LinkDown LinkStatus = "down"
+)
+
+// Link is an NJE connection between two nodes, stored as
+// (:Node)-[:LINKED_TO]->(:Node). NJE links carry traffic in both
+// directions, From and To only record how the link was entered.
+// Host is the remote address for TCP/IP NJE links and the device
+// address for BSC and CTC links.
+type Link struct {
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Transport  Transport  `json:"transport"`
+	Host       string     `json:"host,omitempty"`
+	Port       int        `json:"port,omitempty"`
+	BufferSize int        `json:"buffer_size,omitempty"`
+	Status     LinkStatus `json:"status"`
+}
+
+// Validate checks the link attributes and fills in defaults for
+// optional ones.
+func (l *Link) Validate() error {
+	l.From = NormalizeNodeName(l.From)
+	l.To = NormalizeNodeName(l.To)
+
+	if err := ValidateNodeName("from", l.From); err != nil {
+		return err
+	}
+	if err := ValidateNodeName("to", l.To); err != nil {
+		return err
+	}
+	if l.From == l.To {
+		return &ValidationError{Field: "to", Message: "a node cannot be linked to itself"}
+	}
+
+	switch l.Transport {
+	case TransportTCP:
+		if l.Host == "" {
+			return &ValidationError{Field: "host", Message: "is required for TCP/IP NJE links"}
+		}
+	case TransportBSC, TransportCTC:
+		// The generated JES2 and NJE38 definitions need the device.
+		if l.Host == "" {
+			return &ValidationError{Field: "host", Message: "is required as device address for BSC and CTC links"}
+		}
+	default:
+		return &ValidationError{Field: "transport", Message: fmt.Sprintf("unknown transport %q", l.Transport)}
+	}
+
+	if l.Port < 0 || l.Port > 65535 {
+		return &ValidationError{Field: "port", Message: fmt.Sprintf("invalid port %d", l.Port)}
+	}
+	if l.BufferSize < 0 {
+		return &ValidationError{Field: "buffer_size", Message: fmt.Sprintf("invalid buffer size %d", l.BufferSize)}
+	}
+
+	switch l.Status {
+	case "":
+		l.Status = LinkUp
+	case LinkUp, LinkDown:
+	default:
+		return &ValidationError{Field: "status", Message: fmt.Sprintf("unknown link status %q", l.Status)}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package nodes
+
+import (
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+	"strings"
+)
+
+type LinkHandler struct {
+	Path           string
+	LinkRepository LinkRepository
+}
+
+// Links serves the link collection on Path and single links on
+// Path/{from}/{to}.
+func (h *LinkHandler) Links(writer http.ResponseWriter, request *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(request.URL.Path, h.Path), "/")
+	if rest == "" {
+		h.collection(writer, request)
+		return
+	}
+
+	parts := strings.Split(rest, "/")
+	if len(parts) != 2 {
+		apierror.NotFound(writer, "no such resource")
+		return
+	}
+	h.single(writer, request, NormalizeNodeName(parts[0]), NormalizeNodeName(parts[1]))
+}
+
+func (h *LinkHandler) collection(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case "GET":
+		all, err := h.LinkRepository.FindAll()
+		if err != nil {
+			apierror.Internal(writer)
+			return
+		}
+		writeJSON(writer, http.StatusOK, &all)
+	case "POST":
+		link, ok := readLink(writer, request)
+		if !ok {
+			return
+		}
+		if err := link.Validate(); err != nil {
+			writeLinkError(writer, err)
+			return
+		}
+		if err := h.LinkRepository.Save(link); err != nil {
+			writeLinkError(writer, err)
+			return
+		}
+		writeJSON(writer, http.StatusCreated, link)
+	default:
+		apierror.MethodNotAllowed(writer)
+	}
+}
+
+func (h *LinkHandler) single(writer http.ResponseWriter, request *http.Request, from string, to string) {
+	switch request.Method {
+	case "GET":
+		link, err := h.LinkRepository.FindByNodes(from, to)
+		if err != nil {
+			writeLinkError(writer, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, link)
+	case "PUT":
+		link, ok := readLink(writer, request)
+		if !ok {
+			return
+		}
+		link.From = from
+		link.To = to
+		if err := link.Validate(); err != nil {
+			writeLinkError(writer, err)
+			return
+		}
+		if err := h.LinkRepository.Update(link); err != nil {
+			writeLinkError(writer, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, link)
+	case "DELETE":
+		if err := h.LinkRepository.DeleteByNodes(from, to); err != nil {
+			writeLinkError(writer, err)
+			return
+		}
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		apierror.MethodNotAllowed(writer)
+	}
+}
+
+func readLink(writer http.ResponseWriter, request *http.Request) (*Link, bool) {
+	link := &Link{}
+	if !readJSON(writer, request, link) {
+		return nil, false
+	}
+	return link, true
+}
+
+func writeLinkError(writer http.ResponseWriter, err error) {
+	var validationError *ValidationError
+	switch {
+	case errors.As(err, &validationError):
+		writeValidationError(writer, validationError)
+	case errors.Is(err, ErrNodeNotFound):
+		apierror.Write(writer, http.StatusUnprocessableEntity, "unknown_node", "linked node does not exist")
+	case errors.Is(err, ErrLinkNotFound):
+		apierror.NotFound(writer, "link not found")
+	case errors.Is(err, ErrLinkExists):
+		apierror.Write(writer, http.StatusConflict, "link_exists", "the nodes are linked already")
+	default:
+		apierror.Internal(writer)
+	}
+}
@@ -0,0 +1,170 @@
+package nodes_test
+
+import (
+	"encoding/json"
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"strings"
+)
+
+type FakeLinkRepository struct {
+	Links     []*Link
+	SaveError error
+	Saved     *Link
+	Deleted   [2]string
+	Route     *Route
+	Ends      [2]string
+	Options   RouteOptions
+}
+
+func (f *FakeLinkRepository) Save(link *Link) error {
+	f.Saved = link
+	return f.SaveError
+}
+
+func (f *FakeLinkRepository) FindAll() ([]*Link, error) {
+	return f.Links, nil
+}
+
+func (f *FakeLinkRepository) FindByNodes(from string, to string) (*Link, error) {
+	for _, link := range f.Links {
+		if link.From == from && link.To == to {
+			return link, nil
+		}
+	}
+	return nil, ErrLinkNotFound
+}
+
+func (f *FakeLinkRepository) Update(link *Link) error {
+	_, err := f.FindByNodes(link.From, link.To)
+	return err
+}
+
+func (f *FakeLinkRepository) DeleteByNodes(from string, to string) error {
+	f.Deleted = [2]string{from, to}
+	_, err := f.FindByNodes(from, to)
+	return err
+}
+
+func (f *FakeLinkRepository) FindRoute(from string, to string, options RouteOptions) (*Route, error) {
+	f.Ends = [2]string{from, to}
+	f.Options = options
+	if f.Route == nil {
+		return nil, ErrRouteNotFound
+	}
+	return f.Route, nil
+}
+
+var _ = Describe("Link handler", func() {
+
+	var repository *FakeLinkRepository
+	var handler *LinkHandler
+
+	BeforeEach(func() {
+		repository = &FakeLinkRepository{
+			Links: []*Link{{
+				From:      "DRNBRX1A",
+				To:        "DRNMIG1A",
+				Transport: TransportTCP,
+				Host:      "mig.example.org",
+				Port:      175,
+				Status:    LinkUp,
+			}},
+		}
+		handler = &LinkHandler{
+			Path:           "/links",
+			LinkRepository: repository,
+		}
+	})
+
+	It("lists links", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Links(recorder, httptest.NewRequest("GET", "/links", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		var links []*Link
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &links)).To(Succeed())
+		Expect(links).To(Equal(repository.Links))
+	})
+
+	It("creates links with default status", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Links(recorder, httptest.NewRequest("POST", "/links",
+			strings.NewReader(`{"from":"DRNBRX1A","to":"DRNMIG3A","transport":"ctc","host":"0E40"}`)))
+
+		Expect(recorder.Code).To(Equal(201))
+		Expect(repository.Saved.Status).To(Equal(LinkUp))
+	})
+
+	It("rejects invalid links", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Links(recorder, httptest.NewRequest("POST", "/links",
+			strings.NewReader(`{"from":"DRNBRX1A","to":"DRNBRX1A","transport":"ctc"}`)))
+
+		Expect(recorder.Code).To(Equal(422))
+	})
+
+	It("requires the device address of BSC and CTC links", func() {
+		for _, transport := range []string{"bsc", "ctc"} {
+			recorder := httptest.NewRecorder()
+
+			handler.Links(recorder, httptest.NewRequest("POST", "/links",
+				strings.NewReader(`{"from":"DRNBRX1A","to":"DRNMIG3A","transport":"`+transport+`"}`)))
+
+			Expect(recorder.Code).To(Equal(422), transport)
+			Expect(recorder.Body.String()).To(ContainSubstring(`"field":"host"`))
+		}
+	})
+
+	It("rejects links to unknown nodes", func() {
+		repository.SaveError = ErrNodeNotFound
+		recorder := httptest.NewRecorder()
+
+		handler.Links(recorder, httptest.NewRequest("POST", "/links",
+			strings.NewReader(`{"from":"DRNBRX1A","to":"DUMMY","transport":"bsc","host":"0B0"}`)))
+
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"unknown_node"`))
+	})
+
+	It("reports duplicate links", func() {
+		repository.SaveError = ErrLinkExists
+		recorder := httptest.NewRecorder()
+
+		handler.Links(recorder, httptest.NewRequest("POST", "/links",
+			strings.NewReader(`{"from":"DRNMIG1A","to":"DRNBRX1A","transport":"bsc","host":"0B0"}`)))
+
+		Expect(recorder.Code).To(Equal(409))
+	})
+
+	It("updates links", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Links(recorder, httptest.NewRequest("PUT", "/links/DRNBRX1A/DRNMIG1A",
+			strings.NewReader(`{"transport":"tcpnje","host":"mig.example.org","port":176,"status":"down"}`)))
+
+		Expect(recorder.Code).To(Equal(200))
+	})
+
+	It("deletes links", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Links(recorder, httptest.NewRequest("DELETE", "/links/DRNBRX1A/DRNMIG1A", nil))
+
+		Expect(recorder.Code).To(Equal(204))
+		Expect(repository.Deleted).To(Equal([2]string{"DRNBRX1A", "DRNMIG1A"}))
+	})
+
+	It("returns not found for unknown links", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Links(recorder, httptest.NewRequest("GET", "/links/DRNBRX1A/DUMMY", nil))
+
+		Expect(recorder.Code).To(Equal(404))
+	})
+})
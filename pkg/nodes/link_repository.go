@@ -0,0 +1,323 @@
+package nodes
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+type LinkRepository interface {
+	Save(link *Link) (err error)
+	FindAll() (links []*Link, err error)
+	FindByNodes(from string, to string) (link *Link, err error)
+	Update(link *Link) (err error)
+	DeleteByNodes(from string, to string) (err error)
+	FindRoute(from string, to string, options RouteOptions) (route *Route, err error)
+}
+
+type LinkNeo4jRepository struct {
+	Driver neo4j.Driver
+}
+
+const linkReturn = "RETURN a.name AS from, b.name AS to, r.transport AS transport, r.host AS host, " +
+	"r.port AS port, r.buffer AS buffer, r.status AS status"
+
+func (l *LinkNeo4jRepository) Save(link *Link) (err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		if err := checkEndpoints(tx, link.From, link.To); err != nil {
+			return nil, err
+		}
+
+		existing, err := findLink(tx, link.From, link.To)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrLinkExists
+		}
+
+		_, err = tx.Run("MATCH (a:Node {name: $from}), (b:Node {name: $to}) "+
+			"CREATE (a)-[:LINKED_TO {transport: $transport, host: $host, port: $port, "+
+			"buffer: $buffer, status: $status}]->(b)",
+			linkParameters(link))
+
+		return nil, err
+	})
+
+	return translate(err, ErrLinkExists)
+}
+
+func (l *LinkNeo4jRepository) FindAll() (links []*Link, err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeRead,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	result, err := session.
+		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("MATCH (a:Node)-[r:LINKED_TO]->(b:Node) "+linkReturn, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			var links []*Link
+			for res.Next() {
+				links = append(links, linkFromRecord(res.Record()))
+			}
+			return links, res.Err()
+		})
+
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return result.([]*Link), nil
+}
+
+func (l *LinkNeo4jRepository) FindByNodes(from string, to string) (link *Link, err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeRead,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	result, err := session.
+		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			return findLink(tx, from, to)
+		})
+
+	if err != nil {
+		return nil, backendError(err)
+	}
+	if result.(*Link) == nil {
+		return nil, ErrLinkNotFound
+	}
+	return result.(*Link), nil
+}
+
+func (l *LinkNeo4jRepository) Update(link *Link) (err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("MATCH (a:Node {name: $from})-[r:LINKED_TO]-(b:Node {name: $to}) "+
+			"SET r.transport = $transport, r.host = $host, r.port = $port, "+
+			"r.buffer = $buffer, r.status = $status "+
+			"RETURN count(r) AS updated",
+			linkParameters(link))
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, expectOne(res, ErrLinkNotFound)
+	})
+
+	return translate(err, ErrLinkExists)
+}
+
+func (l *LinkNeo4jRepository) DeleteByNodes(from string, to string) (err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("MATCH (:Node {name: $from})-[r:LINKED_TO]-(:Node {name: $to}) "+
+			"DELETE r RETURN count(r) AS deleted",
+			map[string]interface{}{
+				"from": from,
+				"to":   to,
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, expectOne(res, ErrLinkNotFound)
+	})
+
+	return backendError(err)
+}
+
+// FindRoute returns the shortest path between two nodes over the link
+// graph. Links are traversed in both directions, only approved nodes
+// are considered.
+func (l *LinkNeo4jRepository) FindRoute(from string, to string, options RouteOptions) (route *Route, err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeRead,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	result, err := session.
+		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			if err := checkEndpoints(tx, from, to); err != nil {
+				return nil, err
+			}
+
+			exclude := make([]interface{}, len(options.Exclude))
+			for i, name := range options.Exclude {
+				exclude[i] = name
+			}
+
+			res, err := tx.Run("MATCH (a:Node {name: $from}), (b:Node {name: $to}) "+
+				"MATCH p = shortestPath((a)-[:LINKED_TO*]-(b)) "+
+				"WHERE all(r IN relationships(p) WHERE $includeDown OR r.status <> 'down') "+
+				"AND none(n IN nodes(p) WHERE n.name IN $exclude) "+
+				"AND all(n IN nodes(p) WHERE coalesce(n.state, 'approved') = 'approved') "+
+				"RETURN [n IN nodes(p) | n.name] AS names, [n IN nodes(p) | n.gateway] AS gateways",
+				map[string]interface{}{
+					"from":        from,
+					"to":          to,
+					"includeDown": options.IncludeDown,
+					"exclude":     exclude,
+				})
+			if err != nil {
+				return nil, err
+			}
+
+			if !res.Next() {
+				if err := res.Err(); err != nil {
+					return nil, err
+				}
+				return nil, ErrRouteNotFound
+			}
+
+			record := res.Record()
+			names, _ := record.Get("names")
+			gateways, _ := record.Get("gateways")
+
+			route := &Route{From: from, To: to}
+			for i, name := range names.([]interface{}) {
+				gateway, _ := gateways.([]interface{})[i].(bool)
+				route.Hops = append(route.Hops, &Hop{
+					Name:      name.(string),
+					IsGateway: gateway,
+				})
+			}
+			return route, nil
+		})
+
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return result.(*Route), nil
+}
+
+// checkEndpoints makes sure both nodes of a link exist.
+func checkEndpoints(tx neo4j.Transaction, from string, to string) error {
+	res, err := tx.Run("OPTIONAL MATCH (a:Node {name: $from}) "+
+		"OPTIONAL MATCH (b:Node {name: $to}) "+
+		"RETURN a IS NOT NULL AS hasFrom, b IS NOT NULL AS hasTo",
+		map[string]interface{}{
+			"from": from,
+			"to":   to,
+		})
+	if err != nil {
+		return err
+	}
+
+	record, err := res.Single()
+	if err != nil {
+		return err
+	}
+
+	hasFrom, _ := record.Get("hasFrom")
+	hasTo, _ := record.Get("hasTo")
+	if !hasFrom.(bool) || !hasTo.(bool) {
+		return ErrNodeNotFound
+	}
+	return nil
+}
+
+// findLink looks up the link between two nodes regardless of the
+// direction it was stored in. It returns nil if there is none.
+func findLink(tx neo4j.Transaction, from string, to string) (*Link, error) {
+	res, err := tx.Run("MATCH (a:Node)-[r:LINKED_TO]->(b:Node) "+
+		"WHERE (a.name = $from AND b.name = $to) OR (a.name = $to AND b.name = $from) "+
+		linkReturn,
+		map[string]interface{}{
+			"from": from,
+			"to":   to,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.Next() {
+		return nil, res.Err()
+	}
+	return linkFromRecord(res.Record()), nil
+}
+
+// countOf reads the single count column of a result.
+func countOf(res neo4j.Result) (int64, error) {
+	record, err := res.Single()
+	if err != nil {
+		return 0, err
+	}
+	return record.Values[0].(int64), nil
+}
+
+// expectOne reads a single count column and returns notFound if it is zero.
+func expectOne(res neo4j.Result, notFound error) error {
+	count, err := countOf(res)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func linkParameters(link *Link) map[string]interface{} {
+	return map[string]interface{}{
+		"from":      link.From,
+		"to":        link.To,
+		"transport": string(link.Transport),
+		"host":      link.Host,
+		"port":      int64(link.Port),
+		"buffer":    int64(link.BufferSize),
+		"status":    string(link.Status),
+	}
+}
+
+func linkFromRecord(record *neo4j.Record) *Link {
+	from, _ := record.Get("from")
+	to, _ := record.Get("to")
+	transport, _ := record.Get("transport")
+	host, _ := record.Get("host")
+	port, _ := record.Get("port")
+	buffer, _ := record.Get("buffer")
+	status, _ := record.Get("status")
+
+	return &Link{
+		From:       from.(string),
+		To:         to.(string),
+		Transport:  Transport(transport.(string)),
+		Host:       host.(string),
+		Port:       int(port.(int64)),
+		BufferSize: int(buffer.(int64)),
+		Status:     LinkStatus(status.(string)),
+	}
+}
@@ -0,0 +1,142 @@
+package nodes_test
+
+import (
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing link repository", func() {
+
+	var nodeRepository NodeRepository
+	var repository LinkRepository
+
+	BeforeEach(func() {
+		nodeRepository = &NodeNeo4jRepository{
+			Driver: driver,
+		}
+		repository = &LinkNeo4jRepository{
+			Driver: driver,
+		}
+
+		for _, name := range []string{"LNKTEST1", "LNKTEST2", "LNKTEST3"} {
+			Expect(nodeRepository.Save(&Node{
+				Name:            name,
+				Platform:        "Hercules 4 on Linux",
+				OperatingSystem: "MVS3.8J",
+				Location:        "Germany",
+			})).To(Succeed(), "Node should be created")
+		}
+	})
+
+	AfterEach(func() {
+		session := driver.NewSession(neo4j.SessionConfig{})
+		defer Close(session, "Session")
+
+		_, err := session.
+			WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+				_, err := tx.Run("MATCH (n:Node) WHERE n.name STARTS WITH 'LNKTEST' DETACH DELETE n", nil)
+				return nil, err
+			})
+		Expect(err).To(BeNil(), "Test nodes should be removed")
+	})
+
+	It("saves and finds links in both directions", func() {
+		link := &Link{
+			From:       "LNKTEST1",
+			To:         "LNKTEST2",
+			Transport:  TransportTCP,
+			Host:       "lnktest2.example.org",
+			Port:       175,
+			BufferSize: 8192,
+			Status:     LinkUp,
+		}
+
+		Expect(repository.Save(link)).To(Succeed())
+
+		found, err := repository.FindByNodes("LNKTEST2", "LNKTEST1")
+		Expect(err).To(BeNil(), "Link should be found")
+		Expect(found).To(Equal(link))
+
+		all, err := repository.FindAll()
+		Expect(err).To(BeNil())
+		Expect(all).To(ContainElement(link))
+	})
+
+	It("rejects duplicate links", func() {
+		Expect(repository.Save(&Link{From: "LNKTEST1", To: "LNKTEST2", Transport: TransportCTC, Status: LinkUp})).
+			To(Succeed())
+
+		err := repository.Save(&Link{From: "LNKTEST2", To: "LNKTEST1", Transport: TransportCTC, Status: LinkUp})
+		Expect(err).To(Equal(ErrLinkExists))
+	})
+
+	It("rejects links to unknown nodes", func() {
+		err := repository.Save(&Link{From: "LNKTEST1", To: "DUMMY", Transport: TransportCTC, Status: LinkUp})
+		Expect(err).To(Equal(ErrNodeNotFound))
+	})
+
+	It("updates links", func() {
+		Expect(repository.Save(&Link{From: "LNKTEST1", To: "LNKTEST3", Transport: TransportBSC, Status: LinkUp})).
+			To(Succeed())
+
+		updated := &Link{From: "LNKTEST1", To: "LNKTEST3", Transport: TransportBSC, Status: LinkDown}
+		Expect(repository.Update(updated)).To(Succeed())
+
+		found, err := repository.FindByNodes("LNKTEST1", "LNKTEST3")
+		Expect(err).To(BeNil())
+		Expect(found.Status).To(Equal(LinkDown))
+
+		err = repository.Update(&Link{From: "LNKTEST2", To: "LNKTEST3", Transport: TransportBSC, Status: LinkUp})
+		Expect(err).To(Equal(ErrLinkNotFound))
+	})
+
+	It("deletes links", func() {
+		Expect(repository.Save(&Link{From: "LNKTEST2", To: "LNKTEST3", Transport: TransportBSC, Status: LinkUp})).
+			To(Succeed())
+
+		Expect(repository.DeleteByNodes("LNKTEST3", "LNKTEST2")).To(Succeed())
+
+		_, err := repository.FindByNodes("LNKTEST2", "LNKTEST3")
+		Expect(err).To(Equal(ErrLinkNotFound))
+		Expect(repository.DeleteByNodes("LNKTEST3", "LNKTEST2")).To(Equal(ErrLinkNotFound))
+	})
+
+	It("finds the shortest route", func() {
+		Expect(repository.Save(&Link{From: "LNKTEST1", To: "LNKTEST2", Transport: TransportCTC, Status: LinkUp})).
+			To(Succeed())
+		Expect(repository.Save(&Link{From: "LNKTEST3", To: "LNKTEST2", Transport: TransportCTC, Status: LinkUp})).
+			To(Succeed())
+
+		route, err := repository.FindRoute("LNKTEST1", "LNKTEST3", RouteOptions{})
+		Expect(err).To(BeNil(), "Route should be found")
+		Expect(route.Hops).To(Equal([]*Hop{{Name: "LNKTEST1"}, {Name: "LNKTEST2"}, {Name: "LNKTEST3"}}))
+
+		_, err = repository.FindRoute("LNKTEST1", "LNKTEST3", RouteOptions{Exclude: []string{"LNKTEST2"}})
+		Expect(err).To(Equal(ErrRouteNotFound))
+	})
+
+	It("skips links that are down", func() {
+		Expect(repository.Save(&Link{From: "LNKTEST1", To: "LNKTEST2", Transport: TransportCTC, Status: LinkDown})).
+			To(Succeed())
+
+		_, err := repository.FindRoute("LNKTEST1", "LNKTEST2", RouteOptions{})
+		Expect(err).To(Equal(ErrRouteNotFound))
+
+		route, err := repository.FindRoute("LNKTEST1", "LNKTEST2", RouteOptions{IncludeDown: true})
+		Expect(err).To(BeNil(), "Route over the down link should be found")
+		Expect(route.Hops).To(HaveLen(2))
+	})
+
+	It("routes through approved nodes only", func() {
+		Expect(repository.Save(&Link{From: "LNKTEST1", To: "LNKTEST2", Transport: TransportCTC, Status: LinkUp})).
+			To(Succeed())
+		Expect(repository.Save(&Link{From: "LNKTEST2", To: "LNKTEST3", Transport: TransportCTC, Status: LinkUp})).
+			To(Succeed())
+		Expect(nodeRepository.SetState("LNKTEST2", StatePending, "")).To(Succeed())
+
+		_, err := repository.FindRoute("LNKTEST1", "LNKTEST3", RouteOptions{})
+		Expect(err).To(Equal(ErrRouteNotFound))
+	})
+})
@@ -0,0 +1,544 @@
+package nodes
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"sort"
+	"sync"
+)
+
+// MemoryGraph keeps nodes, links and ownership in memory. It backs
+// NodeMemoryRepository and LinkMemoryRepository, which share one graph
+// just like the Neo4j repositories share one database. It is meant for
+// local development and tests; everything is lost on exit.
+type MemoryGraph struct {
+	// UserExists reports whether a user is registered. Nodes saved for
+	// unknown owners get no owner and ownership changes for unknown users
+	// fail with ErrUserNotFound, as with Neo4j. If nil, every user exists.
+	UserExists func(username string) bool
+	// OwnerContact returns the contact details of a user for the nodes it
+	// owns, or nil.
+	OwnerContact func(username string) *OwnerContact
+
+	mutex sync.RWMutex
+	nodes map[string]*Node
+	links []*Link
+}
+
+func NewMemoryGraph() *MemoryGraph {
+	return &MemoryGraph{
+		nodes: map[string]*Node{},
+	}
+}
+
+// GraphData is the content of a MemoryGraph, e.g. for keeping it on
+// disk.
+type GraphData struct {
+	Nodes []*Node `json:"nodes"`
+	Links []*Link `json:"links"`
+}
+
+// Export returns a copy of all nodes, in name order, and links.
+func (g *MemoryGraph) Export() *GraphData {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	data := &GraphData{
+		Nodes: []*Node{},
+		Links: []*Link{},
+	}
+	for _, node := range g.nodes {
+		data.Nodes = append(data.Nodes, copyNode(node))
+	}
+	sort.Slice(data.Nodes, func(i, j int) bool {
+		return data.Nodes[i].Name < data.Nodes[j].Name
+	})
+	for _, link := range g.links {
+		exported := *link
+		data.Links = append(data.Links, &exported)
+	}
+	return data
+}
+
+// Import replaces the content of the graph with a copy of data. Nodes
+// saved before places existed get one parsed from their location.
+func (g *MemoryGraph) Import(data *GraphData) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.nodes = map[string]*Node{}
+	for _, node := range data.Nodes {
+		imported := copyNode(node)
+		imported.Place = placeOf(node)
+		g.nodes[node.Name] = imported
+	}
+	g.links = nil
+	for _, link := range data.Links {
+		imported := *link
+		g.links = append(g.links, &imported)
+	}
+}
+
+// OwnerContactsFrom reads owner contact details from the profiles in
+// repository, e.g. for MemoryGraph.OwnerContact.
+func OwnerContactsFrom(repository users.UserRepository) func(username string) *OwnerContact {
+	return func(username string) *OwnerContact {
+		user, err := repository.FindByUsername(username)
+		if err != nil || user == nil || user.ContactDetails == (users.ContactDetails{}) {
+			return nil
+		}
+		return &OwnerContact{
+			DisplayName: user.DisplayName,
+			Callsign:    user.Callsign,
+			Contact:     user.Contact,
+		}
+	}
+}
+
+func (g *MemoryGraph) userExists(username string) bool {
+	return g.UserExists == nil || g.UserExists(username)
+}
+
+// findLink returns the index of the link between two nodes regardless of
+// its direction, or -1.
+func (g *MemoryGraph) findLink(from string, to string) int {
+	for i, link := range g.links {
+		if (link.From == from && link.To == to) || (link.From == to && link.To == from) {
+			return i
+		}
+	}
+	return -1
+}
+
+type NodeMemoryRepository struct {
+	Graph *MemoryGraph
+}
+
+func (n *NodeMemoryRepository) Save(node *Node) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if _, ok := g.nodes[node.Name]; ok {
+		return ErrNodeExists
+	}
+
+	stored := copyNode(node)
+	stored.Maintainers = nil
+	stored.StateReason = ""
+	stored.OwnerContact = nil
+	stored.Place = placeOf(node)
+	if !g.userExists(stored.Owner) {
+		stored.Owner = ""
+	}
+	g.nodes[node.Name] = stored
+	return nil
+}
+
+func (n *NodeMemoryRepository) FindAll() (nodes []*Node, err error) {
+	return n.find(func(*Node) bool { return true }), nil
+}
+
+func (n *NodeMemoryRepository) FindByName(name string) (node *Node, err error) {
+	g := n.Graph
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	stored, ok := g.nodes[name]
+	if !ok {
+		return nil, ErrNodeNotFound
+	}
+	return g.withOwnerContact(stored), nil
+}
+
+// FindAllByState returns the nodes in the given state. Nodes without a
+// state are returned as approved ones, see Node.IsApproved.
+func (n *NodeMemoryRepository) FindAllByState(state State) (nodes []*Node, err error) {
+	return n.find(func(node *Node) bool {
+		if node.State == "" {
+			return state == StateApproved
+		}
+		return node.State == state
+	}), nil
+}
+
+func (n *NodeMemoryRepository) Query(query *NodeQuery) (page *NodePage, err error) {
+	return query.page(n.find(query.matches))
+}
+
+func (n *NodeMemoryRepository) Search(search *NodeSearch) (results []*SearchResult, err error) {
+	return search.rank(n.find((*Node).IsApproved)), nil
+}
+
+func (n *NodeMemoryRepository) SetState(name string, state State, reason string) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	stored, ok := g.nodes[name]
+	if !ok {
+		return ErrNodeNotFound
+	}
+	stored.State = state
+	stored.StateReason = reason
+	return nil
+}
+
+// DeleteByName removes the node together with its links.
+func (n *NodeMemoryRepository) DeleteByName(name string) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if _, ok := g.nodes[name]; !ok {
+		return ErrNodeNotFound
+	}
+	delete(g.nodes, name)
+
+	links := g.links[:0]
+	for _, link := range g.links {
+		if link.From != name && link.To != name {
+			links = append(links, link)
+		}
+	}
+	g.links = links
+	return nil
+}
+
+// Update replaces the attributes of the node called name with those of
+// node. node.Name may differ from name to rename the node; its links
+// follow the new name.
+func (n *NodeMemoryRepository) Update(name string, node *Node) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	stored, ok := g.nodes[name]
+	if node.Name != name {
+		if _, exists := g.nodes[node.Name]; exists {
+			return ErrNodeExists
+		}
+	}
+	if !ok {
+		return ErrNodeNotFound
+	}
+
+	stored.Name = node.Name
+	stored.Alias = node.Alias
+	stored.IsGateway = node.IsGateway
+	stored.Platform = node.Platform
+	stored.OperatingSystem = node.OperatingSystem
+	stored.Location = node.Location
+	stored.Place = placeOf(node)
+
+	if node.Name != name {
+		delete(g.nodes, name)
+		g.nodes[node.Name] = stored
+		for _, link := range g.links {
+			if link.From == name {
+				link.From = node.Name
+			}
+			if link.To == name {
+				link.To = node.Name
+			}
+		}
+	}
+	return nil
+}
+
+func (n *NodeMemoryRepository) TransferOwnership(name string, username string) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	stored, err := g.nodeAndUser(name, username)
+	if err != nil {
+		return err
+	}
+	stored.Owner = username
+	stored.Maintainers = without(stored.Maintainers, username)
+	return nil
+}
+
+func (n *NodeMemoryRepository) AddMaintainer(name string, username string) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	stored, err := g.nodeAndUser(name, username)
+	if err != nil {
+		return err
+	}
+	if stored.Owner == username {
+		return nil
+	}
+	for _, maintainer := range stored.Maintainers {
+		if maintainer == username {
+			return nil
+		}
+	}
+	stored.Maintainers = append(stored.Maintainers, username)
+	return nil
+}
+
+func (n *NodeMemoryRepository) RemoveMaintainer(name string, username string) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	stored, ok := g.nodes[name]
+	if !ok {
+		return ErrUserNotFound
+	}
+	remaining := without(stored.Maintainers, username)
+	if len(remaining) == len(stored.Maintainers) {
+		return ErrUserNotFound
+	}
+	stored.Maintainers = remaining
+	return nil
+}
+
+func (n *NodeMemoryRepository) FindByUser(username string) (nodes []*Node, err error) {
+	return n.find(func(node *Node) bool {
+		return node.EditableBy(username)
+	}), nil
+}
+
+func (n *NodeMemoryRepository) RenameUser(username string, newUsername string) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for _, node := range g.nodes {
+		if node.Owner == username {
+			node.Owner = newUsername
+		}
+		for i, maintainer := range node.Maintainers {
+			if maintainer == username {
+				node.Maintainers[i] = newUsername
+			}
+		}
+	}
+	return nil
+}
+
+func (n *NodeMemoryRepository) ReleaseUser(username string) (err error) {
+	g := n.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for _, node := range g.nodes {
+		node.Maintainers = without(node.Maintainers, username)
+		if node.Owner != username {
+			continue
+		}
+		node.Owner = ""
+		if len(node.Maintainers) > 0 {
+			sort.Strings(node.Maintainers)
+			node.Owner = node.Maintainers[0]
+			node.Maintainers = node.Maintainers[1:]
+		}
+	}
+	return nil
+}
+
+// find returns copies of the nodes matching filter in name order.
+func (n *NodeMemoryRepository) find(filter func(node *Node) bool) []*Node {
+	g := n.Graph
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	var nodes []*Node
+	for _, node := range g.nodes {
+		if filter(node) {
+			nodes = append(nodes, g.withOwnerContact(node))
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
+
+// nodeAndUser makes sure both ends of an ownership relationship exist.
+func (g *MemoryGraph) nodeAndUser(name string, username string) (*Node, error) {
+	stored, ok := g.nodes[name]
+	if !ok {
+		return nil, ErrNodeNotFound
+	}
+	if !g.userExists(username) {
+		return nil, ErrUserNotFound
+	}
+	return stored, nil
+}
+
+type LinkMemoryRepository struct {
+	Graph *MemoryGraph
+}
+
+func (l *LinkMemoryRepository) Save(link *Link) (err error) {
+	g := l.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.nodes[link.From] == nil || g.nodes[link.To] == nil {
+		return ErrNodeNotFound
+	}
+	if g.findLink(link.From, link.To) >= 0 {
+		return ErrLinkExists
+	}
+	stored := *link
+	g.links = append(g.links, &stored)
+	return nil
+}
+
+func (l *LinkMemoryRepository) FindAll() (links []*Link, err error) {
+	g := l.Graph
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	for _, link := range g.links {
+		found := *link
+		links = append(links, &found)
+	}
+	return links, nil
+}
+
+func (l *LinkMemoryRepository) FindByNodes(from string, to string) (link *Link, err error) {
+	g := l.Graph
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	i := g.findLink(from, to)
+	if i < 0 {
+		return nil, ErrLinkNotFound
+	}
+	found := *g.links[i]
+	return &found, nil
+}
+
+// Update replaces the attributes of the link between link.From and
+// link.To. The direction the link was stored in is kept.
+func (l *LinkMemoryRepository) Update(link *Link) (err error) {
+	g := l.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	i := g.findLink(link.From, link.To)
+	if i < 0 {
+		return ErrLinkNotFound
+	}
+	stored := g.links[i]
+	stored.Transport = link.Transport
+	stored.Host = link.Host
+	stored.Port = link.Port
+	stored.BufferSize = link.BufferSize
+	stored.Status = link.Status
+	return nil
+}
+
+func (l *LinkMemoryRepository) DeleteByNodes(from string, to string) (err error) {
+	g := l.Graph
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	i := g.findLink(from, to)
+	if i < 0 {
+		return ErrLinkNotFound
+	}
+	g.links = append(g.links[:i], g.links[i+1:]...)
+	return nil
+}
+
+// FindRoute returns the shortest path between two nodes over the link
+// graph. Links are traversed in both directions, only approved nodes
+// are considered. Among paths of equal length the one through the
+// alphabetically first neighbours wins.
+func (l *LinkMemoryRepository) FindRoute(from string, to string, options RouteOptions) (route *Route, err error) {
+	g := l.Graph
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if g.nodes[from] == nil || g.nodes[to] == nil {
+		return nil, ErrNodeNotFound
+	}
+
+	usable := func(name string) bool {
+		for _, excluded := range options.Exclude {
+			if excluded == name {
+				return false
+			}
+		}
+		return g.nodes[name].IsApproved()
+	}
+	if !usable(from) || !usable(to) {
+		return nil, ErrRouteNotFound
+	}
+
+	neighbours := map[string][]string{}
+	for _, link := range g.links {
+		if link.Status == LinkDown && !options.IncludeDown {
+			continue
+		}
+		neighbours[link.From] = append(neighbours[link.From], link.To)
+		neighbours[link.To] = append(neighbours[link.To], link.From)
+	}
+
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 && queue[0] != to {
+		current := queue[0]
+		queue = queue[1:]
+		next := neighbours[current]
+		sort.Strings(next)
+		for _, name := range next {
+			if _, seen := previous[name]; seen || !usable(name) {
+				continue
+			}
+			previous[name] = current
+			queue = append(queue, name)
+		}
+	}
+	if _, reached := previous[to]; !reached {
+		return nil, ErrRouteNotFound
+	}
+
+	route = &Route{From: from, To: to}
+	for name := to; name != ""; name = previous[name] {
+		route.Hops = append([]*Hop{{
+			Name:      name,
+			IsGateway: g.nodes[name].IsGateway,
+		}}, route.Hops...)
+	}
+	return route, nil
+}
+
+// withOwnerContact returns a copy of node with the contact details of
+// its owner.
+func (g *MemoryGraph) withOwnerContact(node *Node) *Node {
+	result := copyNode(node)
+	if g.OwnerContact != nil && node.Owner != "" {
+		result.OwnerContact = g.OwnerContact(node.Owner)
+	}
+	return result
+}
+
+func copyNode(node *Node) *Node {
+	result := *node
+	if node.Maintainers != nil {
+		result.Maintainers = append([]string(nil), node.Maintainers...)
+	}
+	if node.Place != nil {
+		result.Place = placeOf(node)
+	}
+	return &result
+}
+
+func without(names []string, name string) []string {
+	var result []string
+	for _, n := range names {
+		if n != name {
+			result = append(result, n)
+		}
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package nodes
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+	"strings"
+)
+
+type Decision struct {
+	Reason string `json:"reason"`
+}
+
+// actions maps the moderation endpoints to the state they move a node to.
+var actions = map[string]State{
+	"approve":      StateApproved,
+	"reject":       StateRejected,
+	"decommission": StateDecommissioned,
+}
+
+type ModerationHandler struct {
+	Path           string
+	NodeRepository NodeRepository
+}
+
+// Nodes serves the moderation queue on Path?state= (pending by default)
+// and the decisions on Path/{name}/approve, /reject and /decommission.
+// It has to be wrapped with users.RequireRole(users.RoleModerator).
+func (h *ModerationHandler) Nodes(writer http.ResponseWriter, request *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(request.URL.Path, h.Path), "/")
+	if rest == "" {
+		h.queue(writer, request)
+		return
+	}
+
+	parts := strings.Split(rest, "/")
+	state, ok := actions[parts[len(parts)-1]]
+	if len(parts) != 2 || !ok {
+		apierror.NotFound(writer, "no such resource")
+		return
+	}
+	if request.Method != "POST" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	decision := &Decision{}
+	if request.ContentLength != 0 && !readJSON(writer, request, decision) {
+		return
+	}
+	if state != StateApproved && strings.TrimSpace(decision.Reason) == "" {
+		apierror.Invalid(writer, apierror.FieldError{Field: "reason", Message: "is required"})
+		return
+	}
+
+	node, err := h.NodeRepository.FindByName(NormalizeNodeName(parts[0]))
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+	if !node.State.CanBecome(state) {
+		apierror.Write(writer, http.StatusConflict, "invalid_transition",
+			"a "+string(node.State)+" node cannot become "+string(state))
+		return
+	}
+
+	if err := h.NodeRepository.SetState(node.Name, state, decision.Reason); err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+	node.State = state
+	node.StateReason = decision.Reason
+	writeJSON(writer, http.StatusOK, node)
+}
+
+func (h *ModerationHandler) queue(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	state := State(request.URL.Query().Get("state"))
+	if state == "" {
+		state = StatePending
+	}
+
+	queued, err := h.NodeRepository.FindAllByState(state)
+	if err != nil {
+		apierror.Internal(writer)
+		return
+	}
+	if queued == nil {
+		queued = []*Node{}
+	}
+	writeJSON(writer, http.StatusOK, queued)
+}
@@ -0,0 +1,87 @@
+package nodes_test
+
+import (
+	"encoding/json"
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("Moderation", func() {
+
+	var repository *FakeNodeRepository
+	var handler *ModerationHandler
+
+	BeforeEach(func() {
+		repository = &FakeNodeRepository{
+			Nodes: map[string]*Node{
+				"DRNBRX1A": {Name: "DRNBRX1A", State: StatePending},
+				"DRNMIG1A": {Name: "DRNMIG1A", State: StateApproved},
+			},
+		}
+		handler = &ModerationHandler{
+			Path:           "/moderation/nodes",
+			NodeRepository: repository,
+		}
+	})
+
+	It("lists pending nodes", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Nodes(recorder, httptest.NewRequest("GET", "/moderation/nodes", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		var pending []*Node
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &pending)).To(Succeed())
+		Expect(pending).To(Equal([]*Node{{Name: "DRNBRX1A", State: StatePending}}))
+	})
+
+	It("approves nodes", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Nodes(recorder, httptest.NewRequest("POST", "/moderation/nodes/DRNBRX1A/approve", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(repository.Nodes["DRNBRX1A"].State).To(Equal(StateApproved))
+	})
+
+	It("finds nodes by their name in any case", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Nodes(recorder, httptest.NewRequest("POST", "/moderation/nodes/drnbrx1a/approve", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(repository.Nodes["DRNBRX1A"].State).To(Equal(StateApproved))
+	})
+
+	It("rejects nodes with a reason", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Nodes(recorder, httptest.NewRequest("POST", "/moderation/nodes/DRNBRX1A/reject",
+			strings.NewReader(`{"reason":"duplicate of DRNBRX2A"}`)))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(repository.Nodes["DRNBRX1A"].State).To(Equal(StateRejected))
+		Expect(repository.Nodes["DRNBRX1A"].StateReason).To(Equal("duplicate of DRNBRX2A"))
+	})
+
+	It("requires a reason for rejections", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Nodes(recorder, httptest.NewRequest("POST", "/moderation/nodes/DRNBRX1A/reject", nil))
+
+		Expect(recorder.Code).To(Equal(422))
+		Expect(repository.Nodes["DRNBRX1A"].State).To(Equal(StatePending))
+	})
+
+	It("refuses invalid transitions", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Nodes(recorder, httptest.NewRequest("POST", "/moderation/nodes/DRNMIG1A/reject",
+			strings.NewReader(`{"reason":"too late"}`)))
+
+		Expect(recorder.Code).To(Equal(409))
+	})
+})
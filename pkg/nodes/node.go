@@ -1,5 +1,32 @@
 package nodes
 
+type State string
+
+const (
+	StatePending        State = "pending"
+	StateApproved       State = "approved"
+	StateRejected       State = "rejected"
+	StateDecommissioned State = "decommissioned"
+)
+
+// transitions lists the states a moderator may move a node to from its
+// current state.
+var transitions = map[State][]State{
+	StatePending:  {StateApproved, StateRejected},
+	StateRejected: {StateApproved},
+	StateApproved: {StateDecommissioned},
+	"":            {StateDecommissioned},
+}
+
+func (s State) CanBecome(next State) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Node struct {
 	Name            string `json:"name"`
 	Alias           string `json:"alias,omitempty"`
@@ -7,4 +34,82 @@ type Node struct {
 	Platform        string `json:"platform"`
 	OperatingSystem string `json:"os"`
 	Location        string `json:"location"`
+	// Place is parsed from Location if it is not given, see ParsePlace.
+	Place       *Place   `json:"place,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	Maintainers []string `json:"maintainers,omitempty"`
+	State       State    `json:"state,omitempty"`
+	StateReason string   `json:"state_reason,omitempty"`
+	// OwnerContact is read from the profile of the owner and ignored when
+	// nodes are saved.
+	OwnerContact *OwnerContact `json:"owner_contact,omitempty"`
+}
+
+// OwnerContact tells how to reach the owner of a node.
+type OwnerContact struct {
+	DisplayName string `json:"display_name,omitempty"`
+	Callsign    string `json:"callsign,omitempty"`
+	Contact     string `json:"contact,omitempty"`
+}
+
+// IsApproved tells whether the node takes part in routing. Nodes
+// registered before the approval workflow have no state and count as
+// approved.
+func (n *Node) IsApproved() bool {
+	return n.State == StateApproved || n.State == ""
+}
+
+// EditableBy tells whether the user may change the node, which is the
+// case for its owner and its co-maintainers.
+func (n *Node) EditableBy(username string) bool {
+	if username == "" {
+		return false
+	}
+	if n.Owner == username {
+		return true
+	}
+	for _, maintainer := range n.Maintainers {
+		if maintainer == username {
+			return true
+		}
+	}
+	return false
+}
+
+// NodePatch holds a partial update of a node. Attributes left nil are
+// not changed.
+type NodePatch struct {
+	Alias           *string `json:"alias"`
+	IsGateway       *bool   `json:"gateway"`
+	Platform        *string `json:"platform"`
+	OperatingSystem *string `json:"os"`
+	Location        *string `json:"location"`
+	Place           *Place  `json:"place"`
+}
+
+func (p *NodePatch) Apply(node *Node) {
+	if p.Alias != nil {
+		node.Alias = *p.Alias
+	}
+	if p.IsGateway != nil {
+		node.IsGateway = *p.IsGateway
+	}
+	if p.Platform != nil {
+		node.Platform = *p.Platform
+	}
+	if p.OperatingSystem != nil {
+		node.OperatingSystem = *p.OperatingSystem
+	}
+	// A new location or place replaces the other one unless both are
+	// given, see Node.Validate.
+	if p.Location != nil {
+		node.Location = *p.Location
+		node.Place = nil
+	}
+	if p.Place != nil {
+		node.Place = p.Place
+		if p.Location == nil {
+			node.Location = ""
+		}
+	}
 }
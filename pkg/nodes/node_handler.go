@@ -0,0 +1,110 @@
+package nodes
+
+import (
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"net/http"
+	"strings"
+)
+
+type NodeHandler struct {
+	Path           string
+	NodeRepository NodeRepository
+}
+
+// Node serves a single node on Path{name}.
+func (h *NodeHandler) Node(writer http.ResponseWriter, request *http.Request) {
+	name := NormalizeNodeName(strings.TrimPrefix(request.URL.Path, h.Path))
+	if name == "" || strings.Contains(name, "/") {
+		apierror.NotFound(writer, "no such resource")
+		return
+	}
+
+	if request.Method == "GET" {
+		node, err := h.NodeRepository.FindByName(name)
+		if err != nil {
+			writeNodeError(writer, err)
+			return
+		}
+		writeJSON(writer, http.StatusOK, node)
+		return
+	}
+
+	existing, err := h.NodeRepository.FindByName(name)
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+	if !mayEdit(request, existing) {
+		apierror.Forbidden(writer, "only maintainers of the node may change it")
+		return
+	}
+
+	switch request.Method {
+	case "PUT":
+		node := &Node{}
+		if !readJSON(writer, request, node) {
+			return
+		}
+		if node.Name == "" {
+			node.Name = name
+		}
+		node.Owner = existing.Owner
+		node.Maintainers = existing.Maintainers
+		node.State = existing.State
+		node.StateReason = existing.StateReason
+		h.update(writer, name, node)
+	case "PATCH":
+		patch := &NodePatch{}
+		if !readJSON(writer, request, patch) {
+			return
+		}
+		patch.Apply(existing)
+		h.update(writer, name, existing)
+	case "DELETE":
+		if err := h.NodeRepository.DeleteByName(name); err != nil {
+			writeNodeError(writer, err)
+			return
+		}
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		apierror.MethodNotAllowed(writer)
+	}
+}
+
+func (h *NodeHandler) update(writer http.ResponseWriter, name string, node *Node) {
+	if err := node.Validate(); err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+	if err := h.NodeRepository.Update(name, node); err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, node)
+}
+
+// mayEdit tells whether the authenticated user may change node. Admins
+// may change every node.
+func mayEdit(request *http.Request, node *Node) bool {
+	user, ok := users.UserFromContext(request.Context())
+	if !ok {
+		return false
+	}
+	return user.Role.Includes(users.RoleAdmin) || node.EditableBy(user.Username)
+}
+
+func writeNodeError(writer http.ResponseWriter, err error) {
+	var validationError *ValidationError
+	switch {
+	case errors.As(err, &validationError):
+		writeValidationError(writer, validationError)
+	case errors.Is(err, ErrNodeNotFound):
+		apierror.NotFound(writer, "node not found")
+	case errors.Is(err, ErrNodeExists):
+		apierror.Write(writer, http.StatusConflict, "node_exists", "a node with this name exists already")
+	default:
+		apierror.Internal(writer)
+	}
+}
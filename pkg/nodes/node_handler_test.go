@@ -0,0 +1,257 @@
+package nodes_test
+
+import (
+	"encoding/json"
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type FakeNodeRepository struct {
+	Nodes   map[string]*Node
+	Updated *Node
+}
+
+func (f *FakeNodeRepository) Save(node *Node) error {
+	if _, ok := f.Nodes[node.Name]; ok {
+		return ErrNodeExists
+	}
+	f.Nodes[node.Name] = node
+	return nil
+}
+
+func (f *FakeNodeRepository) FindAll() ([]*Node, error) {
+	var all []*Node
+	for _, node := range f.Nodes {
+		all = append(all, node)
+	}
+	return all, nil
+}
+
+func (f *FakeNodeRepository) FindByName(name string) (*Node, error) {
+	node, ok := f.Nodes[name]
+	if !ok {
+		return nil, ErrNodeNotFound
+	}
+	copied := *node
+	return &copied, nil
+}
+
+func (f *FakeNodeRepository) DeleteByName(name string) error {
+	if _, ok := f.Nodes[name]; !ok {
+		return ErrNodeNotFound
+	}
+	delete(f.Nodes, name)
+	return nil
+}
+
+func (f *FakeNodeRepository) Update(name string, node *Node) error {
+	if _, ok := f.Nodes[name]; !ok {
+		return ErrNodeNotFound
+	}
+	if _, ok := f.Nodes[node.Name]; ok && node.Name != name {
+		return ErrNodeExists
+	}
+	f.Updated = node
+	delete(f.Nodes, name)
+	f.Nodes[node.Name] = node
+	return nil
+}
+
+// as marks the request as authenticated by username.
+func as(username string, request *http.Request) *http.Request {
+	return request.WithContext(users.ContextWithUsername(request.Context(), username))
+}
+
+func (f *FakeNodeRepository) FindAllByState(state State) ([]*Node, error) {
+	var result []*Node
+	for _, node := range f.Nodes {
+		if node.State == state || (state == StateApproved && node.IsApproved()) {
+			result = append(result, node)
+		}
+	}
+	return result, nil
+}
+
+// Query runs query on a copy of the nodes in memory.
+func (f *FakeNodeRepository) Query(query *NodeQuery) (*NodePage, error) {
+	all, _ := f.FindAll()
+	graph := NewMemoryGraph()
+	graph.Import(&GraphData{Nodes: all})
+	return (&NodeMemoryRepository{Graph: graph}).Query(query)
+}
+
+func (f *FakeNodeRepository) SetState(name string, state State, reason string) error {
+	node, ok := f.Nodes[name]
+	if !ok {
+		return ErrNodeNotFound
+	}
+	node.State = state
+	node.StateReason = reason
+	return nil
+}
+
+var _ = Describe("Node handler", func() {
+
+	var repository *FakeNodeRepository
+	var handler *NodeHandler
+
+	BeforeEach(func() {
+		repository = &FakeNodeRepository{
+			Nodes: map[string]*Node{
+				"DRNBRX1A": {
+					Name:            "DRNBRX1A",
+					Alias:           "DEBRXMVS",
+					Platform:        "Hercules 4 on Linux",
+					OperatingSystem: "MVS3.8J",
+					Location:        "Germay",
+					Owner:           "flo",
+				},
+				"DRNMIG1A": {
+					Name:            "DRNMIG1A",
+					Platform:        "Hercules 4 on Linux",
+					OperatingSystem: "VM/ESA",
+					Location:        "Germany",
+					Owner:           "mig",
+					Maintainers:     []string{"flo"},
+				},
+			},
+		}
+		handler = &NodeHandler{
+			Path:           "/node/",
+			NodeRepository: repository,
+		}
+	})
+
+	It("gets a node", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, httptest.NewRequest("GET", "/node/DRNBRX1A", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		var node Node
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &node)).To(Succeed())
+		Expect(&node).To(Equal(repository.Nodes["DRNBRX1A"]))
+	})
+
+	It("gets nodes by their name in any case", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, httptest.NewRequest("GET", "/node/drnbrx1a", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"name":"DRNBRX1A"`))
+	})
+
+	It("returns not found for unknown nodes", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, httptest.NewRequest("GET", "/node/DUMMY", nil))
+
+		Expect(recorder.Code).To(Equal(404))
+	})
+
+	It("patches single attributes", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, as("flo", httptest.NewRequest("PATCH", "/node/DRNBRX1A",
+			strings.NewReader(`{"location":"Germany","gateway":true}`))))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(repository.Updated).To(Equal(&Node{
+			Name:            "DRNBRX1A",
+			Alias:           "DEBRXMVS",
+			IsGateway:       true,
+			Platform:        "Hercules 4 on Linux",
+			OperatingSystem: "MVS3.8J",
+			Location:        "Germany",
+			Place:           &Place{Country: "DE"},
+			Owner:           "flo",
+		}))
+	})
+
+	It("writes the location of patched places", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, as("flo", httptest.NewRequest("PATCH", "/node/DRNBRX1A",
+			strings.NewReader(`{"place":{"country":"gb","city":"London"}}`))))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(repository.Updated.Location).To(Equal("London, United Kingdom"))
+		Expect(repository.Updated.Place).To(Equal(&Place{Country: "GB", City: "London"}))
+
+		recorder = httptest.NewRecorder()
+		handler.Node(recorder, as("flo", httptest.NewRequest("PATCH", "/node/DRNBRX1A",
+			strings.NewReader(`{"place":{"country":"Atlantis"}}`))))
+
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"place.country"`))
+	})
+
+	It("replaces a node", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, as("flo", httptest.NewRequest("PUT", "/node/DRNBRX1A",
+			strings.NewReader(`{"platform":"Linux","os":"MVS3.8J","location":"Germany","owner":"evil"}`))))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(repository.Updated).To(Equal(&Node{
+			Name:            "DRNBRX1A",
+			Platform:        "Linux",
+			OperatingSystem: "MVS3.8J",
+			Location:        "Germany",
+			Place:           &Place{Country: "DE"},
+			Owner:           "flo",
+		}))
+	})
+
+	It("rejects renaming to an existing node", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, as("flo", httptest.NewRequest("PUT", "/node/DRNBRX1A",
+			strings.NewReader(`{"name":"DRNMIG1A","platform":"Linux","os":"MVS3.8J"}`))))
+
+		Expect(recorder.Code).To(Equal(409))
+	})
+
+	It("rejects incomplete nodes", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, as("flo", httptest.NewRequest("PUT", "/node/DRNBRX1A",
+			strings.NewReader(`{"platform":"Linux"}`))))
+
+		Expect(recorder.Code).To(Equal(422))
+	})
+
+	It("deletes a node", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, as("flo", httptest.NewRequest("DELETE", "/node/DRNMIG1A", nil)))
+
+		Expect(recorder.Code).To(Equal(204))
+		Expect(repository.Nodes).NotTo(HaveKey("DRNMIG1A"))
+	})
+
+	It("only lets owners and maintainers change a node", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Node(recorder, as("mig", httptest.NewRequest("DELETE", "/node/DRNBRX1A", nil)))
+
+		Expect(recorder.Code).To(Equal(403))
+		Expect(repository.Nodes).To(HaveKey("DRNBRX1A"))
+	})
+
+	It("lets admins change every node", func() {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("DELETE", "/node/DRNBRX1A", nil)
+
+		handler.Node(recorder, request.WithContext(users.ContextWithUser(request.Context(),
+			&users.User{Username: "admin", Role: users.RoleAdmin})))
+
+		Expect(recorder.Code).To(Equal(204))
+	})
+})
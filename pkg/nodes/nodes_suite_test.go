@@ -1,13 +1,49 @@
 package nodes_test
 
 import (
+	"context"
+	"fmt"
 	"testing"
 
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/testcontainers/testcontainers-go"
 )
 
+const neo4jUsername = "neo4j"
+const neo4jPassword = "s3cr3t"
+
+var ctx context.Context
+var neo4jContainer testcontainers.Container
+var driver neo4j.Driver
+
 func TestNodes(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Nodes Suite")
 }
+
+var _ = BeforeSuite(func() {
+	ctx = context.Background()
+	var err error
+
+	neo4jContainer, err = startContainer(ctx, neo4jUsername, neo4jPassword)
+	Expect(err).To(BeNil(), "Container should start")
+
+	port, err := neo4jContainer.MappedPort(ctx, "7687")
+	Expect(err).To(BeNil(), "Port should be resolved")
+
+	address := fmt.Sprintf("bolt://localhost:%d", port.Int())
+
+	driver, err = neo4j.NewDriver(address, neo4j.BasicAuth(neo4jUsername, neo4jPassword, ""))
+	Expect(err).To(BeNil(), "Driver should be created")
+})
+
+var _ = AfterSuite(func() {
+	if driver != nil {
+		Close(driver, "Driver")
+	}
+	if neo4jContainer != nil {
+		Expect(neo4jContainer.Terminate(ctx)).To(BeNil(), "Container should stop")
+	}
+})
@@ -0,0 +1,132 @@
+package nodes
+
+import (
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"net/http"
+	"strings"
+)
+
+// OwnershipRepository manages the (:User)-[:OWNS]->(:Node) and
+// (:User)-[:MAINTAINS]->(:Node) relationships.
+type OwnershipRepository interface {
+	TransferOwnership(name string, username string) (err error)
+	AddMaintainer(name string, username string) (err error)
+	RemoveMaintainer(name string, username string) (err error)
+	FindByUser(username string) (nodes []*Node, err error)
+	// RenameUser lets ownership follow a renamed user.
+	RenameUser(username string, newUsername string) (err error)
+	// ReleaseUser drops the ownership and maintainerships of a user that
+	// is about to be deleted. Each owned node goes to its first
+	// co-maintainer by name, nodes without one are left without owner.
+	ReleaseUser(username string) (err error)
+}
+
+type Owner struct {
+	Owner string `json:"owner"`
+}
+
+type Maintainer struct {
+	Username string `json:"username"`
+}
+
+type OwnershipHandler struct {
+	Path                string
+	NodeRepository      NodeRepository
+	OwnershipRepository OwnershipRepository
+}
+
+// Ownership serves Path{name}/owner and Path{name}/maintainers. Only the
+// owner of a node or an admin may hand it over or change its
+// co-maintainers.
+func (h *OwnershipHandler) Ownership(writer http.ResponseWriter, request *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(request.URL.Path, h.Path), "/")
+	if len(parts) < 2 {
+		apierror.NotFound(writer, "no such resource")
+		return
+	}
+
+	node, err := h.NodeRepository.FindByName(NormalizeNodeName(parts[0]))
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+
+	if request.Method == "GET" {
+		writeJSON(writer, http.StatusOK, node)
+		return
+	}
+
+	user, ok := users.UserFromContext(request.Context())
+	if !ok || (node.Owner != user.Username && !user.Role.Includes(users.RoleAdmin)) {
+		apierror.Forbidden(writer, "only the owner of the node may change its ownership")
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "owner" && request.Method == "PUT":
+		owner := &Owner{}
+		if !readJSON(writer, request, owner) {
+			return
+		}
+		err = h.OwnershipRepository.TransferOwnership(node.Name, owner.Owner)
+	case len(parts) == 2 && parts[1] == "maintainers" && request.Method == "POST":
+		maintainer := &Maintainer{}
+		if !readJSON(writer, request, maintainer) {
+			return
+		}
+		err = h.OwnershipRepository.AddMaintainer(node.Name, maintainer.Username)
+	case len(parts) == 3 && parts[1] == "maintainers" && request.Method == "DELETE":
+		err = h.OwnershipRepository.RemoveMaintainer(node.Name, parts[2])
+	default:
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			apierror.Write(writer, http.StatusUnprocessableEntity, "unknown_user", "user does not exist")
+			return
+		}
+		writeNodeError(writer, err)
+		return
+	}
+
+	node, err = h.NodeRepository.FindByName(node.Name)
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, node)
+}
+
+type UserNodesHandler struct {
+	Path                string
+	OwnershipRepository OwnershipRepository
+}
+
+// Nodes serves Path{username}/nodes, the nodes a user owns or
+// co-maintains.
+func (h *UserNodesHandler) Nodes(writer http.ResponseWriter, request *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(request.URL.Path, h.Path), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "nodes" {
+		apierror.NotFound(writer, "no such resource")
+		return
+	}
+
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	owned, err := h.OwnershipRepository.FindByUser(parts[0])
+	if err != nil {
+		apierror.Internal(writer)
+		return
+	}
+	if owned == nil {
+		owned = []*Node{}
+	}
+	writeJSON(writer, http.StatusOK, owned)
+}
@@ -0,0 +1,193 @@
+package nodes
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+func (n *NodeNeo4jRepository) TransferOwnership(name string, username string) (err error) {
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.
+		WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			if err := checkNodeAndUser(tx, name, username); err != nil {
+				return nil, err
+			}
+
+			_, err := tx.Run("MATCH (n:Node {name: $name}), (u:User {username: $username}) "+
+				"OPTIONAL MATCH (:User)-[owns:OWNS]->(n) "+
+				"OPTIONAL MATCH (u)-[maintains:MAINTAINS]->(n) "+
+				"DELETE owns, maintains "+
+				"WITH DISTINCT n, u "+
+				"MERGE (u)-[:OWNS]->(n)",
+				map[string]interface{}{
+					"name":     name,
+					"username": username,
+				})
+			return nil, err
+		})
+
+	return backendError(err)
+}
+
+func (n *NodeNeo4jRepository) AddMaintainer(name string, username string) (err error) {
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.
+		WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			if err := checkNodeAndUser(tx, name, username); err != nil {
+				return nil, err
+			}
+
+			_, err := tx.Run("MATCH (n:Node {name: $name}), (u:User {username: $username}) "+
+				"WHERE NOT (u)-[:OWNS]->(n) "+
+				"MERGE (u)-[:MAINTAINS]->(n)",
+				map[string]interface{}{
+					"name":     name,
+					"username": username,
+				})
+			return nil, err
+		})
+
+	return backendError(err)
+}
+
+func (n *NodeNeo4jRepository) RemoveMaintainer(name string, username string) (err error) {
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.
+		WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("MATCH (:User {username: $username})-[r:MAINTAINS]->(:Node {name: $name}) "+
+				"DELETE r RETURN count(r) AS deleted",
+				map[string]interface{}{
+					"name":     name,
+					"username": username,
+				})
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, expectOne(res, ErrUserNotFound)
+		})
+
+	return backendError(err)
+}
+
+func (n *NodeNeo4jRepository) FindByUser(username string) (nodes []*Node, err error) {
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeRead,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	result, err := session.
+		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("MATCH (:User {username: $username})-[:OWNS|MAINTAINS]->(n:Node) "+
+				ownershipMatch+nodeReturn+" ORDER BY n.name",
+				map[string]interface{}{
+					"username": username,
+				})
+			if err != nil {
+				return nil, err
+			}
+
+			var nodes []*Node
+			for res.Next() {
+				nodes = append(nodes, nodeFromRecord(res.Record()))
+			}
+			return nodes, res.Err()
+		})
+
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return result.([]*Node), nil
+}
+
+// RenameUser has nothing to do, ownership relationships keep pointing
+// to the renamed user.
+func (n *NodeNeo4jRepository) RenameUser(username string, newUsername string) (err error) {
+	return nil
+}
+
+func (n *NodeNeo4jRepository) ReleaseUser(username string) (err error) {
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.
+		WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			parameters := map[string]interface{}{
+				"username": username,
+			}
+			if _, err := tx.Run("MATCH (:User {username: $username})-[r:MAINTAINS]->(:Node) DELETE r",
+				parameters); err != nil {
+				return nil, err
+			}
+
+			_, err := tx.Run("MATCH (:User {username: $username})-[owns:OWNS]->(n:Node) "+
+				"OPTIONAL MATCH (m:User)-[:MAINTAINS]->(n) "+
+				"WITH owns, n, m ORDER BY m.username "+
+				"WITH owns, n, collect(m)[0] AS heir "+
+				"DELETE owns "+
+				"WITH n, heir WHERE heir IS NOT NULL "+
+				"MATCH (heir)-[maintains:MAINTAINS]->(n) "+
+				"DELETE maintains "+
+				"MERGE (heir)-[:OWNS]->(n)",
+				parameters)
+			return nil, err
+		})
+
+	return backendError(err)
+}
+
+// checkNodeAndUser makes sure both ends of an ownership relationship
+// exist.
+func checkNodeAndUser(tx neo4j.Transaction, name string, username string) error {
+	res, err := tx.Run("OPTIONAL MATCH (n:Node {name: $name}) "+
+		"OPTIONAL MATCH (u:User {username: $username}) "+
+		"RETURN n IS NOT NULL AS hasNode, u IS NOT NULL AS hasUser",
+		map[string]interface{}{
+			"name":     name,
+			"username": username,
+		})
+	if err != nil {
+		return err
+	}
+
+	record, err := res.Single()
+	if err != nil {
+		return err
+	}
+
+	if hasNode, _ := record.Get("hasNode"); !hasNode.(bool) {
+		return ErrNodeNotFound
+	}
+	if hasUser, _ := record.Get("hasUser"); !hasUser.(bool) {
+		return ErrUserNotFound
+	}
+	return nil
+}
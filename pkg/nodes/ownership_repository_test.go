@@ -0,0 +1,93 @@
+package nodes_test
+
+import (
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Testing ownership repository", func() {
+
+	var repository *NodeNeo4jRepository
+
+	BeforeEach(func() {
+		repository = &NodeNeo4jRepository{
+			Driver: driver,
+		}
+
+		session := driver.NewSession(neo4j.SessionConfig{})
+		defer Close(session, "Session")
+
+		_, err := session.
+			WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+				_, err := tx.Run("UNWIND ['own-flo', 'own-mig', 'own-lon'] AS username "+
+					"CREATE (:User {username: username})", nil)
+				return nil, err
+			})
+		Expect(err).To(BeNil(), "Test users should be created")
+
+		Expect(repository.Save(&Node{
+			Name:            "OWNTEST1",
+			Platform:        "Linux",
+			OperatingSystem: "MVS3.8J",
+			Location:        "Germany",
+			Owner:           "own-flo",
+		})).To(Succeed(), "Node should be created")
+	})
+
+	AfterEach(func() {
+		session := driver.NewSession(neo4j.SessionConfig{})
+		defer Close(session, "Session")
+
+		_, err := session.
+			WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+				_, err := tx.Run("MATCH (n:Node {name: 'OWNTEST1'}) DETACH DELETE n", nil)
+				if err != nil {
+					return nil, err
+				}
+				_, err = tx.Run("MATCH (u:User) WHERE u.username STARTS WITH 'own-' DETACH DELETE u", nil)
+				return nil, err
+			})
+		Expect(err).To(BeNil(), "Test data should be removed")
+	})
+
+	It("creates nodes with their owner", func() {
+		node, err := repository.FindByName("OWNTEST1")
+
+		Expect(err).To(BeNil())
+		Expect(node.Owner).To(Equal("own-flo"))
+		Expect(node.Maintainers).To(BeNil())
+	})
+
+	It("manages maintainers", func() {
+		Expect(repository.AddMaintainer("OWNTEST1", "own-mig")).To(Succeed())
+		Expect(repository.AddMaintainer("OWNTEST1", "own-lon")).To(Succeed())
+
+		node, err := repository.FindByName("OWNTEST1")
+		Expect(err).To(BeNil())
+		Expect(node.Maintainers).To(ConsistOf("own-mig", "own-lon"))
+
+		Expect(repository.RemoveMaintainer("OWNTEST1", "own-mig")).To(Succeed())
+		Expect(repository.RemoveMaintainer("OWNTEST1", "own-mig")).To(Equal(ErrUserNotFound))
+
+		owned, err := repository.FindByUser("own-lon")
+		Expect(err).To(BeNil())
+		Expect(owned).To(HaveLen(1))
+		Expect(owned[0].Name).To(Equal("OWNTEST1"))
+	})
+
+	It("transfers ownership", func() {
+		Expect(repository.AddMaintainer("OWNTEST1", "own-mig")).To(Succeed())
+
+		Expect(repository.TransferOwnership("OWNTEST1", "own-mig")).To(Succeed())
+
+		node, err := repository.FindByName("OWNTEST1")
+		Expect(err).To(BeNil())
+		Expect(node.Owner).To(Equal("own-mig"))
+		Expect(node.Maintainers).To(BeNil())
+
+		Expect(repository.TransferOwnership("OWNTEST1", "nobody")).To(Equal(ErrUserNotFound))
+		Expect(repository.TransferOwnership("DUMMY", "own-mig")).To(Equal(ErrNodeNotFound))
+	})
+})
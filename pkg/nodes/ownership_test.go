@@ -0,0 +1,162 @@
+package nodes_test
+
+import (
+	"encoding/json"
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"strings"
+)
+
+type FakeOwnershipRepository struct {
+	Nodes *FakeNodeRepository
+	Users []string
+}
+
+func (f *FakeOwnershipRepository) knows(username string) bool {
+	for _, user := range f.Users {
+		if user == username {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FakeOwnershipRepository) TransferOwnership(name string, username string) error {
+	if !f.knows(username) {
+		return ErrUserNotFound
+	}
+	f.Nodes.Nodes[name].Owner = username
+	return nil
+}
+
+func (f *FakeOwnershipRepository) AddMaintainer(name string, username string) error {
+	if !f.knows(username) {
+		return ErrUserNotFound
+	}
+	node := f.Nodes.Nodes[name]
+	node.Maintainers = append(node.Maintainers, username)
+	return nil
+}
+
+func (f *FakeOwnershipRepository) RemoveMaintainer(name string, username string) error {
+	node := f.Nodes.Nodes[name]
+	for i, maintainer := range node.Maintainers {
+		if maintainer == username {
+			node.Maintainers = append(node.Maintainers[:i], node.Maintainers[i+1:]...)
+			return nil
+		}
+	}
+	return ErrUserNotFound
+}
+
+func (f *FakeOwnershipRepository) FindByUser(username string) ([]*Node, error) {
+	var result []*Node
+	for _, node := range f.Nodes.Nodes {
+		if node.EditableBy(username) {
+			result = append(result, node)
+		}
+	}
+	return result, nil
+}
+
+func (f *FakeOwnershipRepository) RenameUser(username string, newUsername string) error {
+	return nil
+}
+
+func (f *FakeOwnershipRepository) ReleaseUser(username string) error {
+	return nil
+}
+
+var _ = Describe("Ownership", func() {
+
+	var nodeRepository *FakeNodeRepository
+	var ownershipRepository *FakeOwnershipRepository
+	var handler *OwnershipHandler
+
+	BeforeEach(func() {
+		nodeRepository = &FakeNodeRepository{
+			Nodes: map[string]*Node{
+				"DRNBRX1A": {Name: "DRNBRX1A", Owner: "flo", Maintainers: []string{"mig"}},
+			},
+		}
+		ownershipRepository = &FakeOwnershipRepository{
+			Nodes: nodeRepository,
+			Users: []string{"flo", "mig", "lon"},
+		}
+		handler = &OwnershipHandler{
+			Path:                "/node/",
+			NodeRepository:      nodeRepository,
+			OwnershipRepository: ownershipRepository,
+		}
+	})
+
+	It("transfers ownership", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Ownership(recorder, as("flo", httptest.NewRequest("PUT", "/node/DRNBRX1A/owner",
+			strings.NewReader(`{"owner":"lon"}`))))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(nodeRepository.Nodes["DRNBRX1A"].Owner).To(Equal("lon"))
+	})
+
+	It("finds nodes by their name in any case", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Ownership(recorder, as("flo", httptest.NewRequest("PUT", "/node/drnbrx1a/owner",
+			strings.NewReader(`{"owner":"lon"}`))))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(nodeRepository.Nodes["DRNBRX1A"].Owner).To(Equal("lon"))
+	})
+
+	It("rejects transfers to unknown users", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Ownership(recorder, as("flo", httptest.NewRequest("PUT", "/node/DRNBRX1A/owner",
+			strings.NewReader(`{"owner":"nobody"}`))))
+
+		Expect(recorder.Code).To(Equal(422))
+	})
+
+	It("does not let maintainers transfer ownership", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Ownership(recorder, as("mig", httptest.NewRequest("PUT", "/node/DRNBRX1A/owner",
+			strings.NewReader(`{"owner":"mig"}`))))
+
+		Expect(recorder.Code).To(Equal(403))
+		Expect(nodeRepository.Nodes["DRNBRX1A"].Owner).To(Equal("flo"))
+	})
+
+	It("adds and removes maintainers", func() {
+		recorder := httptest.NewRecorder()
+		handler.Ownership(recorder, as("flo", httptest.NewRequest("POST", "/node/DRNBRX1A/maintainers",
+			strings.NewReader(`{"username":"lon"}`))))
+		Expect(recorder.Code).To(Equal(200))
+		Expect(nodeRepository.Nodes["DRNBRX1A"].Maintainers).To(Equal([]string{"mig", "lon"}))
+
+		recorder = httptest.NewRecorder()
+		handler.Ownership(recorder, as("flo", httptest.NewRequest("DELETE", "/node/DRNBRX1A/maintainers/mig", nil)))
+		Expect(recorder.Code).To(Equal(200))
+		Expect(nodeRepository.Nodes["DRNBRX1A"].Maintainers).To(Equal([]string{"lon"}))
+	})
+
+	It("lists the nodes of a user", func() {
+		handler := &UserNodesHandler{
+			Path:                "/users/",
+			OwnershipRepository: ownershipRepository,
+		}
+		recorder := httptest.NewRecorder()
+
+		handler.Nodes(recorder, httptest.NewRequest("GET", "/users/mig/nodes", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		var owned []*Node
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &owned)).To(Succeed())
+		Expect(owned).To(HaveLen(1))
+		Expect(owned[0].Name).To(Equal("DRNBRX1A"))
+	})
+})
@@ -0,0 +1,131 @@
+package nodes
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+const maximumCityLength = 64
+
+// earthRadius in kilometers is the one Neo4j computes distances of
+// geographic points with.
+const earthRadius = 6378.14
+
+// Place is the structured form of Node.Location.
+type Place struct {
+	// Country is an ISO 3166-1 alpha-2 code like DE.
+	Country     string       `json:"country"`
+	City        string       `json:"city,omitempty"`
+	Coordinates *Coordinates `json:"coordinates,omitempty"`
+}
+
+// Coordinates are WGS 84 degrees.
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// ParsePlace reads country and city from free-form locations like
+// "Germany", "Berlin, DE" or "London, United Kingdom", as nodes had
+// before places existed. It returns nil if no country is recognized.
+func ParsePlace(location string) *Place {
+	parts := strings.Split(location, ",")
+	country := countryCode(parts[len(parts)-1])
+	if country == "" {
+		return nil
+	}
+	place := &Place{Country: country}
+	if len(parts) > 1 {
+		place.City = strings.TrimSpace(parts[0])
+	}
+	return place
+}
+
+// CountryName returns the English name of the ISO 3166-1 alpha-2 code,
+// or an empty string for unknown codes.
+func CountryName(code string) string {
+	return countryNames[code]
+}
+
+// countryCode looks text up as country code, name or alias, ignoring
+// case.
+func countryCode(text string) string {
+	text = strings.TrimSpace(text)
+	if code := strings.ToUpper(text); countryNames[code] != "" {
+		return code
+	}
+	text = strings.ToLower(text)
+	for code, name := range countryNames {
+		if strings.ToLower(name) == text {
+			return code
+		}
+	}
+	return countryAliases[text]
+}
+
+// String returns the place the way locations are written, like
+// "London, United Kingdom".
+func (p *Place) String() string {
+	if p.City == "" {
+		return CountryName(p.Country)
+	}
+	return p.City + ", " + CountryName(p.Country)
+}
+
+func (p *Place) validate() error {
+	p.Country = strings.ToUpper(strings.TrimSpace(p.Country))
+	p.City = strings.TrimSpace(p.City)
+
+	if p.Country == "" {
+		return &ValidationError{Field: "place.country", Message: "is required"}
+	}
+	if CountryName(p.Country) == "" {
+		return &ValidationError{Field: "place.country", Message: "has to be an ISO 3166-1 alpha-2 code"}
+	}
+	if utf8.RuneCountInString(p.City) > maximumCityLength {
+		return &ValidationError{Field: "place.city", Message: fmt.Sprintf("may have at most %d characters",
+			maximumCityLength)}
+	}
+	if p.Coordinates != nil {
+		return p.Coordinates.validate("place.coordinates.")
+	}
+	return nil
+}
+
+func (c *Coordinates) validate(prefix string) error {
+	if math.IsNaN(c.Latitude) || c.Latitude < -90 || c.Latitude > 90 {
+		return &ValidationError{Field: prefix + "latitude", Message: "has to be from -90 to 90"}
+	}
+	if math.IsNaN(c.Longitude) || c.Longitude < -180 || c.Longitude > 180 {
+		return &ValidationError{Field: prefix + "longitude", Message: "has to be from -180 to 180"}
+	}
+	return nil
+}
+
+// distance returns the great-circle distance to other in kilometers.
+func (c *Coordinates) distance(other *Coordinates) float64 {
+	radians := func(degrees float64) float64 {
+		return degrees * math.Pi / 180
+	}
+	latitude, otherLatitude := radians(c.Latitude), radians(other.Latitude)
+	sinLatitude := math.Sin((otherLatitude - latitude) / 2)
+	sinLongitude := math.Sin(radians(other.Longitude-c.Longitude) / 2)
+	a := sinLatitude*sinLatitude + math.Cos(latitude)*math.Cos(otherLatitude)*sinLongitude*sinLongitude
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// placeOf returns a copy of the place of node, parsed from its location
+// if it has none.
+func placeOf(node *Node) *Place {
+	if node.Place == nil {
+		return ParsePlace(node.Location)
+	}
+	place := *node.Place
+	if node.Place.Coordinates != nil {
+		coordinates := *node.Place.Coordinates
+		place.Coordinates = &coordinates
+	}
+	return &place
+}
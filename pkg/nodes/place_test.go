@@ -0,0 +1,35 @@
+package nodes_test
+
+import (
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Place", func() {
+
+	DescribeTable("parses locations",
+		func(location string, expected *Place) {
+			Expect(ParsePlace(location)).To(Equal(expected))
+		},
+		Entry("country name", "Germany", &Place{Country: "DE"}),
+		Entry("country name in lower case", " germany ", &Place{Country: "DE"}),
+		Entry("country code", "de", &Place{Country: "DE"}),
+		Entry("city and country", "Toronto, Canada", &Place{Country: "CA", City: "Toronto"}),
+		Entry("city, region and country", "Brixton, London, UK", &Place{Country: "GB", City: "Brixton"}),
+		Entry("country alias", "USA", &Place{Country: "US"}),
+		Entry("city only", "Toronto", nil),
+		Entry("empty", "", nil),
+	)
+
+	It("writes places as locations", func() {
+		Expect((&Place{Country: "GB"}).String()).To(Equal("United Kingdom"))
+		Expect((&Place{Country: "GB", City: "London"}).String()).To(Equal("London, United Kingdom"))
+	})
+
+	It("names countries", func() {
+		Expect(CountryName("CH")).To(Equal("Switzerland"))
+		Expect(CountryName("XX")).To(BeEmpty())
+	})
+})
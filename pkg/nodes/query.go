@@ -0,0 +1,319 @@
+package nodes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaximumQueryLimit = 500
+	// DefaultRadius and MaximumRadius are in kilometers.
+	DefaultRadius = 100
+	MaximumRadius = 20000
+)
+
+// sortKeys maps the sort orders of a NodeQuery to the attribute they
+// sort by, which is named like its Neo4j property. Nodes with equal keys
+// are sorted by name.
+var sortKeys = map[string]func(node *Node) string{
+	"name":     func(node *Node) string { return node.Name },
+	"location": func(node *Node) string { return node.Location },
+	"os":       func(node *Node) string { return node.OperatingSystem },
+	"platform": func(node *Node) string { return node.Platform },
+}
+
+// NodeQuery selects a page of the nodes in State. Empty filters match
+// every node.
+type NodeQuery struct {
+	State State
+	// Text is looked for in name, alias, location, platform and
+	// operating system, ignoring case.
+	Text            string
+	OperatingSystem string
+	Platform        string
+	Location        string
+	Gateway         *bool
+	// Near selects nodes with coordinates at most Radius kilometers away.
+	Near   *Coordinates
+	Radius float64
+	// Sort is one of name, location, os or platform, prefixed with "-"
+	// for descending order. It defaults to name.
+	Sort  string
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first
+	// one.
+	Cursor string
+}
+
+// NodePage is one page of the result of a NodeQuery. Total counts all
+// matching nodes; NextCursor is empty on the last page.
+type NodePage struct {
+	Nodes      []*Node
+	Total      int
+	NextCursor string
+}
+
+// nodeCursor points behind the last node of a page.
+type nodeCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	Name string `json:"n"`
+}
+
+// ParseNodeQuery reads a query of the approved nodes from the parameters
+// q, os, platform, location, gateway, near, radius, sort, limit and
+// cursor. near is given as latitude,longitude.
+func ParseNodeQuery(values url.Values) (*NodeQuery, error) {
+	query := &NodeQuery{
+		State:           StateApproved,
+		Text:            strings.TrimSpace(values.Get("q")),
+		OperatingSystem: values.Get("os"),
+		Platform:        values.Get("platform"),
+		Location:        values.Get("location"),
+		Sort:            values.Get("sort"),
+		Limit:           DefaultQueryLimit,
+		Cursor:          values.Get("cursor"),
+	}
+	if gateway := values.Get("gateway"); gateway != "" {
+		parsed, err := strconv.ParseBool(gateway)
+		if err != nil {
+			return nil, &ValidationError{Field: "gateway", Message: "has to be true or false"}
+		}
+		query.Gateway = &parsed
+	}
+	if near := values.Get("near"); near != "" {
+		coordinates, err := parseCoordinates(near)
+		if err != nil {
+			return nil, err
+		}
+		query.Near = coordinates
+		query.Radius = DefaultRadius
+	}
+	if radius := values.Get("radius"); radius != "" {
+		parsed, err := strconv.ParseFloat(radius, 64)
+		switch {
+		case query.Near == nil:
+			return nil, &ValidationError{Field: "radius", Message: "requires near"}
+		case err != nil || !(parsed > 0 && parsed <= MaximumRadius):
+			return nil, &ValidationError{Field: "radius", Message: "has to be a number of kilometers up to " +
+				strconv.Itoa(MaximumRadius)}
+		}
+		query.Radius = parsed
+	}
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > MaximumQueryLimit {
+			return nil, &ValidationError{Field: "limit", Message: "has to be a number from 1 to " +
+				strconv.Itoa(MaximumQueryLimit)}
+		}
+		query.Limit = parsed
+	}
+	if _, _, err := query.order(); err != nil {
+		return nil, err
+	}
+	if _, err := query.after(); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// Values returns the URL parameters of the query, the inverse of
+// ParseNodeQuery.
+func (q *NodeQuery) Values() url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{
+		"q":        q.Text,
+		"os":       q.OperatingSystem,
+		"platform": q.Platform,
+		"location": q.Location,
+		"sort":     q.Sort,
+		"cursor":   q.Cursor,
+	} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	if q.Gateway != nil {
+		values.Set("gateway", strconv.FormatBool(*q.Gateway))
+	}
+	if q.Near != nil {
+		values.Set("near", formatFloat(q.Near.Latitude)+","+formatFloat(q.Near.Longitude))
+		if q.Radius != DefaultRadius {
+			values.Set("radius", formatFloat(q.Radius))
+		}
+	}
+	if q.Limit != 0 && q.Limit != DefaultQueryLimit {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+// parseCoordinates reads latitude,longitude.
+func parseCoordinates(text string) (*Coordinates, error) {
+	invalid := &ValidationError{Field: "near", Message: "has to be latitude,longitude in degrees"}
+	parts := strings.Split(text, ",")
+	if len(parts) != 2 {
+		return nil, invalid
+	}
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return nil, invalid
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return nil, invalid
+	}
+	coordinates := &Coordinates{Latitude: latitude, Longitude: longitude}
+	if err := coordinates.validate("near."); err != nil {
+		return nil, err
+	}
+	return coordinates, nil
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// radius returns Radius, or the default if it is not set.
+func (q *NodeQuery) radius() float64 {
+	if q.Radius <= 0 {
+		return DefaultRadius
+	}
+	return q.Radius
+}
+
+// order returns the attribute sorted by and whether the order is
+// descending.
+func (q *NodeQuery) order() (key string, descending bool, err error) {
+	key = q.Sort
+	if strings.HasPrefix(key, "-") {
+		key = key[1:]
+		descending = true
+	}
+	if key == "" {
+		key = "name"
+	}
+	if _, ok := sortKeys[key]; !ok {
+		return "", false, &ValidationError{Field: "sort", Message: "has to be one of name, location, os or platform"}
+	}
+	return key, descending, nil
+}
+
+// after decodes the cursor, or returns nil for the first page.
+func (q *NodeQuery) after() (*nodeCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	invalid := &ValidationError{Field: "cursor", Message: "is not valid for this query"}
+	decoded, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	cursor := &nodeCursor{}
+	if json.Unmarshal(decoded, cursor) != nil || cursor.Sort != q.sortOrDefault() {
+		return nil, invalid
+	}
+	return cursor, nil
+}
+
+func (q *NodeQuery) sortOrDefault() string {
+	if q.Sort == "" {
+		return "name"
+	}
+	return q.Sort
+}
+
+// cursorBehind returns the cursor of the page following node.
+func (q *NodeQuery) cursorBehind(node *Node) string {
+	key, _, _ := q.order()
+	encoded, _ := json.Marshal(&nodeCursor{
+		Sort: q.sortOrDefault(),
+		Key:  sortKeys[key](node),
+		Name: node.Name,
+	})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func (q *NodeQuery) limit() int {
+	if q.Limit <= 0 || q.Limit > MaximumQueryLimit {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
+
+// matches applies the filters of the query to node.
+func (q *NodeQuery) matches(node *Node) bool {
+	state := node.State
+	if state == "" {
+		state = StateApproved
+	}
+	switch {
+	case q.State != "" && state != q.State,
+		q.OperatingSystem != "" && node.OperatingSystem != q.OperatingSystem,
+		q.Platform != "" && node.Platform != q.Platform,
+		q.Location != "" && node.Location != q.Location,
+		q.Gateway != nil && node.IsGateway != *q.Gateway:
+		return false
+	}
+	if q.Near != nil {
+		if node.Place == nil || node.Place.Coordinates == nil ||
+			node.Place.Coordinates.distance(q.Near) > q.radius() {
+			return false
+		}
+	}
+	if q.Text == "" {
+		return true
+	}
+	text := strings.ToLower(q.Text)
+	for _, value := range []string{node.Name, node.Alias, node.Location, node.Platform, node.OperatingSystem} {
+		if strings.Contains(strings.ToLower(value), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// page sorts the matching nodes and cuts out the page of the query.
+func (q *NodeQuery) page(matching []*Node) (*NodePage, error) {
+	key, descending, err := q.order()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := q.after()
+	if err != nil {
+		return nil, err
+	}
+	sortKey := sortKeys[key]
+	before := func(a *Node, bKey string, bName string) bool {
+		aKey := sortKey(a)
+		if aKey != bKey {
+			return (aKey < bKey) != descending
+		}
+		return a.Name < bName
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return before(matching[i], sortKey(matching[j]), matching[j].Name)
+	})
+
+	page := &NodePage{Nodes: []*Node{}, Total: len(matching)}
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(matching), func(i int) bool {
+			return !before(matching[i], cursor.Key, cursor.Name) &&
+				(sortKey(matching[i]) != cursor.Key || matching[i].Name != cursor.Name)
+		})
+	}
+	end := start + q.limit()
+	if end < len(matching) {
+		page.NextCursor = q.cursorBehind(matching[end-1])
+	} else {
+		end = len(matching)
+	}
+	page.Nodes = append(page.Nodes, matching[start:end]...)
+	return page, nil
+}
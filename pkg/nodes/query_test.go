@@ -0,0 +1,83 @@
+package nodes_test
+
+import (
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"net/url"
+)
+
+var _ = Describe("NodeQuery", func() {
+
+	It("reads queries of approved nodes", func() {
+		query, err := ParseNodeQuery(url.Values{
+			"q":       {" brx "},
+			"os":      {"MVS3.8J"},
+			"gateway": {"true"},
+			"sort":    {"-location"},
+			"limit":   {"20"},
+		})
+
+		Expect(err).To(BeNil())
+		gateway := true
+		Expect(query).To(Equal(&NodeQuery{
+			State:           StateApproved,
+			Text:            "brx",
+			OperatingSystem: "MVS3.8J",
+			Gateway:         &gateway,
+			Sort:            "-location",
+			Limit:           20,
+		}))
+		Expect(query.Values()).To(Equal(url.Values{
+			"q":       {"brx"},
+			"os":      {"MVS3.8J"},
+			"gateway": {"true"},
+			"sort":    {"-location"},
+			"limit":   {"20"},
+		}))
+	})
+
+	It("reads geographic queries", func() {
+		query, err := ParseNodeQuery(url.Values{"near": {"51.46,-0.115"}, "radius": {"12.5"}})
+
+		Expect(err).To(BeNil())
+		Expect(query.Near).To(Equal(&Coordinates{Latitude: 51.46, Longitude: -0.115}))
+		Expect(query.Radius).To(Equal(12.5))
+		Expect(query.Values()).To(Equal(url.Values{"near": {"51.46,-0.115"}, "radius": {"12.5"}}))
+
+		query, err = ParseNodeQuery(url.Values{"near": {"51.46,-0.115"}})
+
+		Expect(err).To(BeNil())
+		Expect(query.Radius).To(Equal(float64(DefaultRadius)))
+	})
+
+	It("defaults to the first page by name", func() {
+		query, err := ParseNodeQuery(url.Values{})
+
+		Expect(err).To(BeNil())
+		Expect(query).To(Equal(&NodeQuery{State: StateApproved, Limit: DefaultQueryLimit}))
+		Expect(query.Values()).To(BeEmpty())
+	})
+
+	DescribeTable("rejects invalid parameters",
+		func(values url.Values, field string) {
+			_, err := ParseNodeQuery(values)
+
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			Expect(err.(*ValidationError).Field).To(Equal(field))
+		},
+		Entry("gateway", url.Values{"gateway": {"maybe"}}, "gateway"),
+		Entry("zero limit", url.Values{"limit": {"0"}}, "limit"),
+		Entry("large limit", url.Values{"limit": {"501"}}, "limit"),
+		Entry("no limit", url.Values{"limit": {"all"}}, "limit"),
+		Entry("sort", url.Values{"sort": {"alias"}}, "sort"),
+		Entry("cursor", url.Values{"cursor": {"!"}}, "cursor"),
+		Entry("near without longitude", url.Values{"near": {"51.46"}}, "near"),
+		Entry("near without number", url.Values{"near": {"north,west"}}, "near"),
+		Entry("near latitude", url.Values{"near": {"95,0"}}, "near.latitude"),
+		Entry("radius without near", url.Values{"radius": {"10"}}, "radius"),
+		Entry("zero radius", url.Values{"near": {"0,0"}, "radius": {"0"}}, "radius"),
+		Entry("large radius", url.Values{"near": {"0,0"}, "radius": {"20001"}}, "radius"),
+	)
+})
@@ -1,8 +1,8 @@
 package nodes
 
 import (
-	"fmt"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"strings"
 )
 
 type NodeRepository interface {
@@ -10,6 +10,11 @@ type NodeRepository interface {
 	FindAll() (nodes []*Node, err error)
 	FindByName(name string) (node *Node, err error)
 	DeleteByName(name string) (err error)
+	Update(name string, node *Node) (err error)
+	FindAllByState(state State) (nodes []*Node, err error)
+	SetState(name string, state State, reason string) (err error)
+	// Query returns a page of the nodes matching query.
+	Query(query *NodeQuery) (page *NodePage, err error)
 }
 
 type NodeNeo4jRepository struct {
@@ -28,7 +33,7 @@ func (n *NodeNeo4jRepository) Save(node *Node) (err error) {
 	if _, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
 		return n.persistNode(tx, node)
 	}); err != nil {
-		return err
+		return translate(err, ErrNodeExists)
 	}
 
 	return nil
@@ -45,7 +50,7 @@ func (n *NodeNeo4jRepository) FindAll() (nodes []*Node, err error) {
 
 	result, err := session.
 		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-			res, err := tx.Run("MATCH (n:Node) RETURN n", nil)
+			res, err := tx.Run("MATCH (n:Node) "+ownershipMatch+nodeReturn, nil)
 
 			if err != nil {
 				return nil, err
@@ -53,26 +58,14 @@ func (n *NodeNeo4jRepository) FindAll() (nodes []*Node, err error) {
 
 			var nodes []*Node
 			for res.Next() {
-				record := res.Record()
-				if value, ok := record.Get("n"); ok {
-					neo4jnode := value.(neo4j.Node)
-					props := neo4jnode.Props
-
-					node := &Node{
-						Name:            props["name"].(string),
-						Alias:           props["alias"].(string),
-						IsGateway:       props["gateway"].(bool),
-						Platform:        props["platform"].(string),
-						OperatingSystem: props["os"].(string),
-						Location:        props["location"].(string),
-					}
-					nodes = append(nodes, node)
-				}
-
+				nodes = append(nodes, nodeFromRecord(res.Record()))
 			}
-			return nodes, nil
+			return nodes, res.Err()
 		})
 
+	if err != nil {
+		return nil, backendError(err)
+	}
 	return result.([]*Node), nil
 }
 
@@ -87,9 +80,7 @@ func (n *NodeNeo4jRepository) FindByName(name string) (node *Node, err error) {
 
 	result, err := session.
 		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-			res, err := tx.Run("MATCH (n:Node {name: $name}) "+
-				"RETURN n.name AS Name, n.alias AS Alias, n.gateway AS IsGateway, "+
-				"n.platform AS Platform, n.os AS OperatingSystem, n.location AS Location ",
+			res, err := tx.Run("MATCH (n:Node {name: $name}) "+ownershipMatch+nodeReturn,
 				map[string]interface{}{
 					"name": name,
 				})
@@ -98,19 +89,14 @@ func (n *NodeNeo4jRepository) FindByName(name string) (node *Node, err error) {
 				return nil, err
 			}
 
-			singleRecord, err := res.Single()
-			if err != nil {
-				return nil, err
+			if !res.Next() {
+				if err := res.Err(); err != nil {
+					return nil, err
+				}
+				return nil, ErrNodeNotFound
 			}
 
-			return &Node{
-				Name:            singleRecord.Values[0].(string),
-				Alias:           singleRecord.Values[1].(string),
-				IsGateway:       singleRecord.Values[2].(bool),
-				Platform:        singleRecord.Values[3].(string),
-				OperatingSystem: singleRecord.Values[4].(string),
-				Location:        singleRecord.Values[5].(string),
-			}, nil
+			return nodeFromRecord(res.Record()), nil
 		})
 
 	if result != nil {
@@ -119,7 +105,230 @@ func (n *NodeNeo4jRepository) FindByName(name string) (node *Node, err error) {
 		node = nil
 	}
 
-	return node, err
+	return node, backendError(err)
+}
+
+// FindAllByState returns the nodes in the given state. Nodes without a
+// state are returned as approved ones, see Node.IsApproved.
+func (n *NodeNeo4jRepository) FindAllByState(state State) (nodes []*Node, err error) {
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeRead,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	result, err := session.
+		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("MATCH (n:Node) WHERE coalesce(n.state, 'approved') = $state "+
+				ownershipMatch+nodeReturn,
+				map[string]interface{}{
+					"state": string(state),
+				})
+
+			if err != nil {
+				return nil, err
+			}
+
+			var nodes []*Node
+			for res.Next() {
+				nodes = append(nodes, nodeFromRecord(res.Record()))
+			}
+			return nodes, res.Err()
+		})
+
+	if err != nil {
+		return nil, backendError(err)
+	}
+	return result.([]*Node), nil
+}
+
+func (n *NodeNeo4jRepository) Query(query *NodeQuery) (page *NodePage, err error) {
+	key, descending, err := query.order()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := query.after()
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	parameters := map[string]interface{}{
+		"limit": query.limit() + 1,
+	}
+	filter := func(condition string, name string, value interface{}) {
+		conditions = append(conditions, condition)
+		parameters[name] = value
+	}
+	if query.State != "" {
+		filter("coalesce(n.state, 'approved') = $state", "state", string(query.State))
+	}
+	if query.OperatingSystem != "" {
+		filter("n.os = $os", "os", query.OperatingSystem)
+	}
+	if query.Platform != "" {
+		filter("n.platform = $platform", "platform", query.Platform)
+	}
+	if query.Location != "" {
+		filter("n.location = $location", "location", query.Location)
+	}
+	if query.Gateway != nil {
+		filter("n.gateway = $gateway", "gateway", *query.Gateway)
+	}
+	if query.Near != nil {
+		filter("point.distance(n.coordinates, point({latitude: $near_latitude, longitude: $near_longitude})) "+
+			"<= $radius", "radius", query.radius()*1000)
+		parameters["near_latitude"] = query.Near.Latitude
+		parameters["near_longitude"] = query.Near.Longitude
+	}
+	if query.Text != "" {
+		filter("any(value IN [n.name, n.alias, n.location, n.platform, n.os] "+
+			"WHERE toLower(value) CONTAINS $text)", "text", strings.ToLower(query.Text))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	comparison, direction := ">", "ASC"
+	if descending {
+		comparison, direction = "<", "DESC"
+	}
+	property := "n." + key
+	pageWhere := where
+	if cursor != nil {
+		parameters["cursor_key"] = cursor.Key
+		parameters["cursor_name"] = cursor.Name
+		after := "(" + property + " " + comparison + " $cursor_key OR (" +
+			property + " = $cursor_key AND n.name > $cursor_name))"
+		if pageWhere == "" {
+			pageWhere = " WHERE " + after
+		} else {
+			pageWhere += " AND " + after
+		}
+	}
+	order := " ORDER BY " + property + " " + direction + ", n.name"
+
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeRead,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	result, err := session.
+		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("MATCH (n:Node)"+where+" RETURN count(n) AS total", parameters)
+			if err != nil {
+				return nil, err
+			}
+			record, err := res.Single()
+			if err != nil {
+				return nil, err
+			}
+			page := &NodePage{Nodes: []*Node{}, Total: int(record.Values[0].(int64))}
+
+			res, err = tx.Run("MATCH (n:Node)"+pageWhere+" WITH n"+order+" LIMIT $limit "+
+				ownershipMatch+nodeReturn+order, parameters)
+			if err != nil {
+				return nil, err
+			}
+			for res.Next() {
+				page.Nodes = append(page.Nodes, nodeFromRecord(res.Record()))
+			}
+			return page, res.Err()
+		})
+
+	if err != nil {
+		return nil, backendError(err)
+	}
+	page = result.(*NodePage)
+	if len(page.Nodes) > query.limit() {
+		page.Nodes = page.Nodes[:query.limit()]
+		page.NextCursor = query.cursorBehind(page.Nodes[len(page.Nodes)-1])
+	}
+	return page, nil
+}
+
+// Search uses the node_search full-text index. Highlights are found
+// the way the memory repository does, as the index does not tell.
+func (n *NodeNeo4jRepository) Search(search *NodeSearch) (results []*SearchResult, err error) {
+	terms := search.terms()
+	if len(terms) == 0 {
+		return []*SearchResult{}, nil
+	}
+
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeRead,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	result, err := session.
+		ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("CALL db.index.fulltext.queryNodes('node_search', $query) YIELD node AS n, score "+
+				"WHERE coalesce(n.state, 'approved') = 'approved' "+
+				"WITH n, score ORDER BY score DESC, n.name LIMIT $limit "+
+				ownershipMatch+nodeReturn+", score ORDER BY score DESC, n.name",
+				map[string]interface{}{
+					"query": luceneQuery(terms),
+					"limit": search.limit(),
+				})
+			if err != nil {
+				return nil, err
+			}
+			results := []*SearchResult{}
+			for res.Next() {
+				score, _ := res.Record().Get("score")
+				results = append(results, &SearchResult{
+					Node:  nodeFromRecord(res.Record()),
+					Score: score.(float64),
+				})
+			}
+			return results, res.Err()
+		})
+
+	if err != nil {
+		return nil, backendError(err)
+	}
+	results = result.([]*SearchResult)
+	for _, found := range results {
+		found.Highlights = highlight(found.Node, terms)
+	}
+	return results, nil
+}
+
+func (n *NodeNeo4jRepository) SetState(name string, state State, reason string) (err error) {
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.
+		WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run("MATCH (n:Node {name: $name}) SET n.state = $state, n.reason = $reason "+
+				"RETURN count(n) AS updated",
+				map[string]interface{}{
+					"name":   name,
+					"state":  string(state),
+					"reason": reason,
+				})
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, expectOne(res, ErrNodeNotFound)
+		})
+
+	return backendError(err)
 }
 
 func (n *NodeNeo4jRepository) DeleteByName(name string) (err error) {
@@ -133,33 +342,200 @@ func (n *NodeNeo4jRepository) DeleteByName(name string) (err error) {
 
 	_, err = session.
 		WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-			res, err := tx.Run("MATCH (n:Node {name: $name}) DELETE n ",
+			res, err := tx.Run("MATCH (n:Node {name: $name}) DETACH DELETE n RETURN count(n) AS deleted",
 				map[string]interface{}{
 					"name": name,
 				})
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, expectOne(res, ErrNodeNotFound)
+		})
+
+	return backendError(err)
+}
+
+// Update replaces the attributes of the node called name with those of
+// node. node.Name may differ from name to rename the node.
+func (n *NodeNeo4jRepository) Update(name string, node *Node) (err error) {
+	session := n.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
 
-			fmt.Printf("%x", res)
-			return nil, err
+	defer func() {
+		_ = session.Close()
+	}()
+
+	_, err = session.
+		WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			if node.Name != name {
+				res, err := tx.Run("MATCH (n:Node {name: $name}) RETURN count(n) AS existing",
+					map[string]interface{}{
+						"name": node.Name,
+					})
+				if err != nil {
+					return nil, err
+				}
+				existing, err := countOf(res)
+				if err != nil {
+					return nil, err
+				}
+				if existing > 0 {
+					return nil, ErrNodeExists
+				}
+			}
+
+			query := "MATCH (n:Node {name: $current}) " +
+				"SET n.name = $name, n.alias = $alias, n.gateway = $gateway, " +
+				"n.platform = $platform, n.os = $os, n.location = $location, " +
+				"n.country = $country, n.city = $city, n.coordinates = " + coordinatesValue + " " +
+				"RETURN count(n) AS updated"
+
+			parameters := nodeParameters(node)
+			parameters["current"] = name
+
+			res, err := tx.Run(query, parameters)
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, expectOne(res, ErrNodeNotFound)
 		})
 
-	return err
+	return translate(err, ErrNodeExists)
 }
 
 func (n *NodeNeo4jRepository) persistNode(tx neo4j.Transaction, node *Node) (interface{}, error) {
+	res, err := tx.Run("MATCH (n:Node {name: $name}) RETURN count(n) AS existing",
+		map[string]interface{}{
+			"name": node.Name,
+		})
+	if err != nil {
+		return nil, err
+	}
+	existing, err := countOf(res)
+	if err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrNodeExists
+	}
+
+	query := "CREATE (n:Node { name: $name, alias: $alias,gateway: $gateway, " +
+		"platform: $platform, os: $os, location: $location, state: $state, " +
+		"country: $country, city: $city, coordinates: " + coordinatesValue + "}) " +
+		"WITH n OPTIONAL MATCH (u:User {username: $owner}) " +
+		"FOREACH (owner IN CASE WHEN u IS NULL THEN [] ELSE [u] END | CREATE (owner)-[:OWNS]->(n))"
+
+	parameters := nodeParameters(node)
+	parameters["owner"] = node.Owner
+	parameters["state"] = nil
+	if node.State != "" {
+		parameters["state"] = string(node.State)
+	}
 
-	query := "CREATE (:Node { name: $name, alias: $alias,gateway: $gateway, " +
-		"platform: $platform, os: $os, location: $location})"
+	_, err = tx.Run(query, parameters)
 
+	return nil, err
+}
+
+func nodeParameters(node *Node) map[string]interface{} {
 	parameters := map[string]interface{}{
-		"name":     node.Name,
-		"alias":    node.Alias,
-		"gateway":  node.IsGateway,
-		"platform": node.Platform,
-		"os":       node.OperatingSystem,
-		"location": node.Location,
+		"name":      node.Name,
+		"alias":     node.Alias,
+		"gateway":   node.IsGateway,
+		"platform":  node.Platform,
+		"os":        node.OperatingSystem,
+		"location":  node.Location,
+		"country":   nil,
+		"city":      nil,
+		"latitude":  nil,
+		"longitude": nil,
 	}
+	if place := placeOf(node); place != nil {
+		parameters["country"] = place.Country
+		parameters["city"] = place.City
+		if place.Coordinates != nil {
+			parameters["latitude"] = place.Coordinates.Latitude
+			parameters["longitude"] = place.Coordinates.Longitude
+		}
+	}
+	return parameters
+}
 
-	_, err := tx.Run(query, parameters)
+// coordinatesValue is the point stored from the parameters latitude and
+// longitude, null without them.
+const coordinatesValue = "CASE WHEN $latitude IS NULL THEN null " +
+	"ELSE point({latitude: $latitude, longitude: $longitude}) END"
+
+// ownershipMatch and nodeReturn complete a query that matched nodes as
+// n, so that nodeFromRecord can read the result.
+const ownershipMatch = "OPTIONAL MATCH (o:User)-[:OWNS]->(n) " +
+	"OPTIONAL MATCH (m:User)-[:MAINTAINS]->(n) "
+
+const nodeReturn = "RETURN n, o.username AS owner, collect(m.username) AS maintainers, " +
+	"o {.display_name, .callsign, .contact} AS owner_contact"
+
+func nodeFromRecord(record *neo4j.Record) *Node {
+	value, _ := record.Get("n")
+	props := value.(neo4j.Node).Props
+
+	node := &Node{
+		Name:            props["name"].(string),
+		Alias:           props["alias"].(string),
+		IsGateway:       props["gateway"].(bool),
+		Platform:        props["platform"].(string),
+		OperatingSystem: props["os"].(string),
+		Location:        props["location"].(string),
+	}
 
-	return nil, err
+	// Nodes saved before places existed have no country.
+	if country, ok := props["country"].(string); ok {
+		node.Place = &Place{Country: country}
+		node.Place.City, _ = props["city"].(string)
+		if point, ok := props["coordinates"].(neo4j.Point2D); ok {
+			node.Place.Coordinates = &Coordinates{Latitude: point.Y, Longitude: point.X}
+		}
+	} else {
+		node.Place = ParsePlace(node.Location)
+	}
+
+	if state, ok := props["state"]; ok {
+		node.State = State(state.(string))
+	}
+	if reason, ok := props["reason"]; ok {
+		node.StateReason = reason.(string)
+	}
+
+	if owner, ok := record.Get("owner"); ok && owner != nil {
+		node.Owner = owner.(string)
+	}
+	if maintainers, ok := record.Get("maintainers"); ok {
+		for _, maintainer := range maintainers.([]interface{}) {
+			node.Maintainers = append(node.Maintainers, maintainer.(string))
+		}
+	}
+	if contact, ok := record.Get("owner_contact"); ok && contact != nil {
+		node.OwnerContact = ownerContactFromMap(contact.(map[string]interface{}))
+	}
+
+	return node
+}
+
+// ownerContactFromMap returns nil if the owner has no contact details.
+func ownerContactFromMap(values map[string]interface{}) *OwnerContact {
+	text := func(key string) string {
+		value, _ := values[key].(string)
+		return value
+	}
+	contact := &OwnerContact{
+		DisplayName: text("display_name"),
+		Callsign:    text("callsign"),
+		Contact:     text("contact"),
+	}
+	if *contact == (OwnerContact{}) {
+		return nil
+	}
+	return contact
 }
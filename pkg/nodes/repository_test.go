@@ -3,6 +3,7 @@ package nodes_test
 import (
 	"context"
 	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/migrations"
 	. "github.com/mvslovers/hnetdb/pkg/nodes"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	. "github.com/onsi/ginkgo"
@@ -14,41 +15,14 @@ import (
 
 var _ = Describe("Testing node repository", func() {
 
-	const username = "neo4j"
-	const password = "s3cr3t"
-
-	var ctx context.Context
-
-	var neo4jContainer testcontainers.Container
-	var driver neo4j.Driver
-
 	var repository NodeRepository
 
-	BeforeSuite(func() {
-		ctx = context.Background()
-		var err error
-
-		neo4jContainer, err = startContainer(ctx, username, password)
-		Expect(err).To(BeNil(), "Container should start")
-
-		port, err := neo4jContainer.MappedPort(ctx, "7687")
-		Expect(err).To(BeNil(), "Port should be resolved")
-
-		address := fmt.Sprintf("bolt://localhost:%d", port.Int())
-
-		driver, err = neo4j.NewDriver(address, neo4j.BasicAuth(username, password, ""))
-		Expect(err).To(BeNil(), "Driver should be created")
-
+	BeforeEach(func() {
 		repository = &NodeNeo4jRepository{
 			Driver: driver,
 		}
 	})
 
-	AfterSuite(func() {
-		Close(driver, "Driver")
-		Expect(neo4jContainer.Terminate(ctx)).To(BeNil(), "Container should stop")
-	})
-
 	It("Save", func() {
 		newNode := &Node{
 			Name:            "DRNBRX1A",
@@ -313,6 +287,96 @@ var _ = Describe("Testing node repository", func() {
 			})
 		Expect(err).To(Not(BeNil()), "Should end with an error")
 
+		// test repository function DeleteByName with a non existing node
+		err = repository.DeleteByName(testNode.Name)
+		Expect(err).To(Equal(ErrNodeNotFound))
+	})
+
+	It("Update", func() {
+		testNode := &Node{
+			Name:            "DRNUPD1A",
+			Alias:           "DEUPDMVS",
+			IsGateway:       false,
+			Platform:        "Hercules 4 on Linux",
+			OperatingSystem: "MVS3.8J",
+			Location:        "Germay",
+		}
+		otherNode := &Node{
+			Name:            "DRNUPD2A",
+			Platform:        "Linux",
+			OperatingSystem: "Linux",
+			Location:        "Germany",
+		}
+		Expect(repository.Save(testNode)).To(Succeed(), "Node should be created")
+		Expect(repository.Save(otherNode)).To(Succeed(), "Node should be created")
+
+		// test repository function Update
+		updatedNode := *testNode
+		updatedNode.Location = "Germany"
+		updatedNode.IsGateway = true
+		err := repository.Update(testNode.Name, &updatedNode)
+		Expect(err).To(BeNil(), "Node should be updated")
+
+		foundNode, err := repository.FindByName(testNode.Name)
+		Expect(err).To(BeNil(), "Node should be found")
+		Expect(foundNode).To(Equal(&updatedNode))
+
+		// test renaming a node to an existing name
+		renamedNode := updatedNode
+		renamedNode.Name = otherNode.Name
+		err = repository.Update(testNode.Name, &renamedNode)
+		Expect(err).To(Equal(ErrNodeExists))
+
+		// test updating a non existing node
+		err = repository.Update("DUMMY", &updatedNode)
+		Expect(err).To(Equal(ErrNodeNotFound))
+	})
+
+	It("Save rejects duplicate names", func() {
+		runner := &migrations.Runner{Driver: driver, Migrations: migrations.Builtin}
+		_, err := runner.Up()
+		Expect(err).To(BeNil(), "Migrations should be applied")
+
+		testNode := &Node{
+			Name:            "DRNDUP1A",
+			Platform:        "Linux",
+			OperatingSystem: "MVS3.8J",
+			Location:        "Germany",
+		}
+		Expect(repository.Save(testNode)).To(Succeed(), "Node should be created")
+
+		err = repository.Save(testNode)
+		Expect(err).To(Equal(ErrNodeExists))
+	})
+
+	It("SetState and FindAllByState", func() {
+		pendingNode := &Node{
+			Name:            "DRNSTA1A",
+			Platform:        "Linux",
+			OperatingSystem: "MVS3.8J",
+			Location:        "Germany",
+			State:           StatePending,
+		}
+		Expect(repository.Save(pendingNode)).To(Succeed(), "Node should be created")
+
+		pending, err := repository.FindAllByState(StatePending)
+		Expect(err).To(BeNil())
+		Expect(pending).To(ContainElement(pendingNode))
+
+		approved, err := repository.FindAllByState(StateApproved)
+		Expect(err).To(BeNil())
+		Expect(approved).NotTo(ContainElement(pendingNode))
+
+		err = repository.SetState(pendingNode.Name, StateRejected, "duplicate")
+		Expect(err).To(BeNil(), "State should be changed")
+
+		foundNode, err := repository.FindByName(pendingNode.Name)
+		Expect(err).To(BeNil())
+		Expect(foundNode.State).To(Equal(StateRejected))
+		Expect(foundNode.StateReason).To(Equal("duplicate"))
+
+		err = repository.SetState("DUMMY", StateApproved, "")
+		Expect(err).To(Equal(ErrNodeNotFound))
 	})
 
 })
@@ -0,0 +1,78 @@
+package nodes
+
+import (
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+	"strings"
+)
+
+type Hop struct {
+	Name      string `json:"name"`
+	IsGateway bool   `json:"gateway"`
+}
+
+// Route is the ordered list of nodes a job travels through, starting
+// with the origin and ending with the destination node.
+type Route struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Hops []*Hop `json:"hops"`
+}
+
+type RouteOptions struct {
+	// Exclude lists nodes the route must not pass through.
+	Exclude []string
+	// IncludeDown allows the route to use links marked down.
+	IncludeDown bool
+}
+
+type RouteHandler struct {
+	Path           string
+	LinkRepository LinkRepository
+}
+
+func (h *RouteHandler) Route(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	query := request.URL.Query()
+	from := NormalizeNodeName(query.Get("from"))
+	to := NormalizeNodeName(query.Get("to"))
+	var fields []apierror.FieldError
+	if from == "" {
+		fields = append(fields, apierror.FieldError{Field: "from", Message: "is required"})
+	}
+	if to == "" {
+		fields = append(fields, apierror.FieldError{Field: "to", Message: "is required"})
+	} else if from == to {
+		fields = append(fields, apierror.FieldError{Field: "to", Message: "has to differ from from"})
+	}
+	if len(fields) > 0 {
+		apierror.Invalid(writer, fields...)
+		return
+	}
+
+	options := RouteOptions{
+		IncludeDown: query.Get("include_down") == "true",
+	}
+	if exclude := query.Get("exclude"); exclude != "" {
+		for _, name := range strings.Split(exclude, ",") {
+			options.Exclude = append(options.Exclude, NormalizeNodeName(name))
+		}
+	}
+
+	route, err := h.LinkRepository.FindRoute(from, to, options)
+	if err != nil {
+		if errors.Is(err, ErrNodeNotFound) || errors.Is(err, ErrRouteNotFound) {
+			apierror.NotFound(writer, err.Error())
+			return
+		}
+		apierror.Internal(writer)
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, route)
+}
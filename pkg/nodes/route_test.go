@@ -0,0 +1,76 @@
+package nodes_test
+
+import (
+	"encoding/json"
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+)
+
+var _ = Describe("Route handler", func() {
+
+	var repository *FakeLinkRepository
+	var handler *RouteHandler
+
+	BeforeEach(func() {
+		repository = &FakeLinkRepository{}
+		handler = &RouteHandler{
+			Path:           "/routes",
+			LinkRepository: repository,
+		}
+	})
+
+	It("returns the hops of a route", func() {
+		repository.Route = &Route{
+			From: "DRNBRX1A",
+			To:   "DRNMIG3A",
+			Hops: []*Hop{
+				{Name: "DRNBRX1A"},
+				{Name: "DRNMIG1A", IsGateway: true},
+				{Name: "DRNMIG3A"},
+			},
+		}
+		recorder := httptest.NewRecorder()
+
+		handler.Route(recorder, httptest.NewRequest("GET",
+			"/routes?from=DRNBRX1A&to=DRNMIG3A&exclude=DRNMIG2A,DRNMIG4A", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		var route Route
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &route)).To(Succeed())
+		Expect(&route).To(Equal(repository.Route))
+		Expect(repository.Options).To(Equal(RouteOptions{
+			Exclude:     []string{"DRNMIG2A", "DRNMIG4A"},
+			IncludeDown: false,
+		}))
+	})
+
+	It("upper cases node names", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Route(recorder, httptest.NewRequest("GET",
+			"/routes?from=drnbrx1a&to=+drnmig3a&exclude=drnmig2a,+DrnMig4a", nil))
+
+		Expect(recorder.Code).To(Equal(404))
+		Expect(repository.Ends).To(Equal([2]string{"DRNBRX1A", "DRNMIG3A"}))
+		Expect(repository.Options.Exclude).To(Equal([]string{"DRNMIG2A", "DRNMIG4A"}))
+	})
+
+	It("returns not found if there is no route", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Route(recorder, httptest.NewRequest("GET", "/routes?from=DRNBRX1A&to=DRNMIG3A&include_down=true", nil))
+
+		Expect(recorder.Code).To(Equal(404))
+		Expect(repository.Options.IncludeDown).To(BeTrue())
+	})
+
+	It("requires both ends of the route", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Route(recorder, httptest.NewRequest("GET", "/routes?from=DRNBRX1A", nil))
+
+		Expect(recorder.Code).To(Equal(422))
+	})
+})
@@ -0,0 +1,328 @@
+package nodes
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DefaultSearchLimit  = 20
+	MaximumSearchLimit  = 100
+	maximumSearchLength = 100
+	maximumSearchTerms  = 8
+)
+
+// NodeSearcher finds approved nodes by what members remember of them,
+// like a word of the location or an alias.
+type NodeSearcher interface {
+	// Search returns the best matches first, at most search.Limit of them.
+	Search(search *NodeSearch) (results []*SearchResult, err error)
+}
+
+// NodeSearch looks for nodes with every word of Text in one of their
+// searchFields. Words match exactly, as prefix or with a typo or two,
+// see matchWeight.
+type NodeSearch struct {
+	Text  string
+	Limit int
+}
+
+// SearchResult is a node found by a NodeSearch. Scores only rank the
+// results of one search, higher is better.
+type SearchResult struct {
+	Node       *Node        `json:"node"`
+	Score      float64      `json:"score"`
+	Highlights []*Highlight `json:"highlights"`
+}
+
+// Highlight splits a field of a found node into the parts that matched
+// the search and the ones in between.
+type Highlight struct {
+	Field string           `json:"field"`
+	Parts []*HighlightPart `json:"parts"`
+}
+
+type HighlightPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// searchFields are the attributes searched, named like their Neo4j
+// property and JSON attribute, with the weight of matches in them.
+var searchFields = []struct {
+	name   string
+	weight float64
+	value  func(node *Node) string
+}{
+	{"name", 4, func(node *Node) string { return node.Name }},
+	{"alias", 3, func(node *Node) string { return node.Alias }},
+	{"location", 2, func(node *Node) string { return node.Location }},
+	{"platform", 1, func(node *Node) string { return node.Platform }},
+	{"os", 1, func(node *Node) string { return node.OperatingSystem }},
+}
+
+// ParseNodeSearch reads a search from the parameters q and limit.
+func ParseNodeSearch(values url.Values) (*NodeSearch, error) {
+	search := &NodeSearch{
+		Text:  strings.TrimSpace(values.Get("q")),
+		Limit: DefaultSearchLimit,
+	}
+	switch terms := search.terms(); {
+	case search.Text == "":
+		return nil, &ValidationError{Field: "q", Message: "is required"}
+	case utf8.RuneCountInString(search.Text) > maximumSearchLength:
+		return nil, &ValidationError{Field: "q", Message: fmt.Sprintf("may have at most %d characters",
+			maximumSearchLength)}
+	case len(terms) == 0:
+		return nil, &ValidationError{Field: "q", Message: "has to contain a letter or digit"}
+	case len(terms) > maximumSearchTerms:
+		return nil, &ValidationError{Field: "q", Message: fmt.Sprintf("may have at most %d words",
+			maximumSearchTerms)}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > MaximumSearchLimit {
+			return nil, &ValidationError{Field: "limit", Message: "has to be a number from 1 to " +
+				strconv.Itoa(MaximumSearchLimit)}
+		}
+		search.Limit = parsed
+	}
+	return search, nil
+}
+
+// terms returns the lower case words of Text.
+func (s *NodeSearch) terms() []string {
+	var terms []string
+	for _, word := range words(strings.ToLower(s.Text)) {
+		terms = append(terms, word.text)
+	}
+	return terms
+}
+
+func (s *NodeSearch) limit() int {
+	if s.Limit <= 0 || s.Limit > MaximumSearchLimit {
+		return DefaultSearchLimit
+	}
+	return s.Limit
+}
+
+// rank scores the candidates and returns the best matches first.
+func (s *NodeSearch) rank(candidates []*Node) []*SearchResult {
+	terms := s.terms()
+	results := []*SearchResult{}
+	for _, node := range candidates {
+		if score := score(node, terms); score > 0 {
+			results = append(results, &SearchResult{Node: node, Score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > s.limit() {
+		results = results[:s.limit()]
+	}
+	for _, result := range results {
+		result.Highlights = highlight(result.Node, terms)
+	}
+	return results
+}
+
+// luceneQuery builds the query of the node_search full-text index from
+// terms, weighted like score: every term has to match one of the fields.
+func luceneQuery(terms []string) string {
+	clauses := make([]string, len(terms))
+	for i, term := range terms {
+		alternatives := term + "^3 " + term + "*^2"
+		if distance := fuzziness(term); distance > 0 {
+			alternatives += " " + term + "~" + strconv.Itoa(distance)
+		}
+		var fields []string
+		for _, field := range searchFields {
+			fields = append(fields, fmt.Sprintf("%s:(%s)^%g", field.name, alternatives, field.weight))
+		}
+		clauses[i] = "+(" + strings.Join(fields, " ") + ")"
+	}
+	return strings.Join(clauses, " ")
+}
+
+// score adds up the best match of each term, weighted by field. It is
+// zero unless every term matches.
+func score(node *Node, terms []string) float64 {
+	total := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for _, field := range searchFields {
+			for _, word := range words(strings.ToLower(field.value(node))) {
+				if weight := matchWeight(term, word.text) * field.weight; weight > best {
+					best = weight
+				}
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// highlight marks the words of the fields of node matched by a term.
+// Fields without matches are left out.
+func highlight(node *Node, terms []string) []*Highlight {
+	highlights := []*Highlight{}
+	for _, field := range searchFields {
+		value := field.value(node)
+		marked := &Highlight{Field: field.name}
+		position := 0
+		// Lower case letters may have another length, so words are
+		// looked up in the value itself.
+		for _, word := range words(value) {
+			if !matchesAny(terms, strings.ToLower(word.text)) {
+				continue
+			}
+			if word.start > position {
+				marked.Parts = append(marked.Parts, &HighlightPart{Text: value[position:word.start]})
+			}
+			marked.Parts = append(marked.Parts, &HighlightPart{Text: word.text, Match: true})
+			position = word.end
+		}
+		if len(marked.Parts) == 0 {
+			continue
+		}
+		if position < len(value) {
+			marked.Parts = append(marked.Parts, &HighlightPart{Text: value[position:]})
+		}
+		highlights = append(highlights, marked)
+	}
+	return highlights
+}
+
+func matchesAny(terms []string, word string) bool {
+	for _, term := range terms {
+		if matchWeight(term, word) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeight tells how well word matches term: 3 if they are equal, 2
+// if term is a prefix of word, 1 if they are within the edit distance
+// tolerated for term and 0 otherwise.
+func matchWeight(term string, word string) float64 {
+	switch {
+	case word == term:
+		return 3
+	case strings.HasPrefix(word, term):
+		return 2
+	case fuzziness(term) > 0 && editDistance(term, word) <= fuzziness(term):
+		return 1
+	}
+	return 0
+}
+
+// fuzziness is the number of typos tolerated in term, none for short
+// terms like the 4 of "Hercules 4".
+func fuzziness(term string) int {
+	switch length := utf8.RuneCountInString(term); {
+	case length < 3:
+		return 0
+	case length < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance counts the insertions, deletions, substitutions and
+// transpositions of adjacent letters turning a into b, the way Lucene's
+// fuzzy queries do.
+func editDistance(a string, b string) int {
+	x, y := []rune(a), []rune(b)
+	distances := make([][]int, len(x)+1)
+	for i := range distances {
+		distances[i] = make([]int, len(y)+1)
+		distances[i][0] = i
+	}
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			distance := minimum(distances[i-1][j]+1, distances[i][j-1]+1, distances[i-1][j-1]+cost)
+			if i > 1 && j > 1 && x[i-1] == y[j-2] && x[i-2] == y[j-1] {
+				distance = minimum(distance, distances[i-2][j-2]+1)
+			}
+			distances[i][j] = distance
+		}
+	}
+	return distances[len(x)][len(y)]
+}
+
+func minimum(first int, others ...int) int {
+	for _, other := range others {
+		if other < first {
+			first = other
+		}
+	}
+	return first
+}
+
+// word is a word of a field value between the byte offsets start and
+// end.
+type word struct {
+	text       string
+	start, end int
+}
+
+// words splits value like the standard analyzer of the full-text index:
+// words are runs of letters and digits, also joined by dots and
+// apostrophes between two letters or two digits, like "MVS3.8J".
+func words(value string) []*word {
+	runes := []rune(value)
+	offsets := make([]int, len(runes)+1)
+	for i := range runes {
+		offsets[i+1] = offsets[i] + utf8.RuneLen(runes[i])
+	}
+	inWord := func(i int) bool {
+		r := runes[i]
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+		if (r != '.' && r != '\'') || i == 0 || i == len(runes)-1 {
+			return false
+		}
+		before, after := runes[i-1], runes[i+1]
+		return (unicode.IsLetter(before) && unicode.IsLetter(after)) ||
+			(unicode.IsDigit(before) && unicode.IsDigit(after))
+	}
+
+	var result []*word
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && inWord(i) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			result = append(result, &word{
+				text:  value[offsets[start]:offsets[i]],
+				start: offsets[start],
+				end:   offsets[i],
+			})
+			start = -1
+		}
+	}
+	return result
+}
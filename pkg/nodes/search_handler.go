@@ -0,0 +1,33 @@
+package nodes
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+)
+
+type SearchHandler struct {
+	Path         string
+	NodeSearcher NodeSearcher
+}
+
+// Search answers with the approved nodes matching the parameter q, see
+// ParseNodeSearch.
+func (h *SearchHandler) Search(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	search, err := ParseNodeSearch(request.URL.Query())
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+	results, err := h.NodeSearcher.Search(search)
+	if err != nil {
+		writeNodeError(writer, err)
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, results)
+}
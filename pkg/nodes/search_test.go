@@ -0,0 +1,92 @@
+package nodes_test
+
+import (
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+var _ = Describe("NodeSearch", func() {
+
+	It("reads searches", func() {
+		search, err := ParseNodeSearch(url.Values{"q": {" the Brixton node "}, "limit": {"5"}})
+
+		Expect(err).To(BeNil())
+		Expect(search).To(Equal(&NodeSearch{Text: "the Brixton node", Limit: 5}))
+
+		search, err = ParseNodeSearch(url.Values{"q": {"BRX"}})
+
+		Expect(err).To(BeNil())
+		Expect(search.Limit).To(Equal(DefaultSearchLimit))
+	})
+
+	DescribeTable("rejects invalid parameters",
+		func(values url.Values, expected *ValidationError) {
+			_, err := ParseNodeSearch(values)
+
+			Expect(err).To(Equal(expected))
+		},
+		Entry("no text", url.Values{"q": {" "}},
+			&ValidationError{Field: "q", Message: "is required"}),
+		Entry("long text", url.Values{"q": {strings.Repeat("x", 101)}},
+			&ValidationError{Field: "q", Message: "may have at most 100 characters"}),
+		Entry("no words", url.Values{"q": {"*/?"}},
+			&ValidationError{Field: "q", Message: "has to contain a letter or digit"}),
+		Entry("many words", url.Values{"q": {"a b c d e f g h i"}},
+			&ValidationError{Field: "q", Message: "may have at most 8 words"}),
+		Entry("limit", url.Values{"q": {"BRX"}, "limit": {"101"}},
+			&ValidationError{Field: "limit", Message: "has to be a number from 1 to 100"}),
+	)
+})
+
+var _ = Describe("SearchHandler", func() {
+
+	var handler *SearchHandler
+
+	BeforeEach(func() {
+		repository := &NodeMemoryRepository{Graph: NewMemoryGraph()}
+		Expect(repository.Save(&Node{Name: "DRNBRX1A", Platform: "Hercules", OperatingSystem: "MVS3.8J",
+			Location: "Brixton"})).To(Succeed())
+		handler = &SearchHandler{Path: "/search/nodes", NodeSearcher: repository}
+	})
+
+	It("answers with ranked and highlighted nodes", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Search(recorder, httptest.NewRequest("GET", "/search/nodes?q=brixtn", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(MatchJSON(`[{
+			"node": {"name": "DRNBRX1A", "gateway": false, "platform": "Hercules", "os": "MVS3.8J", "location": "Brixton"},
+			"score": 2,
+			"highlights": [{"field": "location", "parts": [{"text": "Brixton", "match": true}]}]
+		}]`))
+	})
+
+	It("answers with an empty list if nothing matches", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Search(recorder, httptest.NewRequest("GET", "/search/nodes?q=paris", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(MatchJSON(`[]`))
+	})
+
+	It("rejects invalid searches", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Search(recorder, httptest.NewRequest("GET", "/search/nodes", nil))
+
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"q"`))
+
+		recorder = httptest.NewRecorder()
+		handler.Search(recorder, httptest.NewRequest("POST", "/search/nodes?q=brx", nil))
+
+		Expect(recorder.Code).To(Equal(405))
+	})
+})
@@ -0,0 +1,64 @@
+package nodes
+
+import (
+	"regexp"
+	"strings"
+)
+
+// nodeNamePattern matches NJE node names: one to eight characters out
+// of A-Z, 0-9 and the national characters @, # and $, starting with a
+// letter.
+var nodeNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9@#$]{0,7}$`)
+
+// NormalizeNodeName brings a node name into the form it is stored in.
+// NJE names are not case sensitive, so they are upper cased.
+func NormalizeNodeName(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+func ValidateNodeName(field string, name string) error {
+	if name == "" {
+		return &ValidationError{Field: field, Message: "is required"}
+	}
+	if !nodeNamePattern.MatchString(name) {
+		return &ValidationError{
+			Field:   field,
+			Message: "must be 1-8 characters of A-Z, 0-9, @, # or $ starting with a letter",
+		}
+	}
+	return nil
+}
+
+// Validate checks the node against the NJE naming rules. Name and alias
+// are upper cased first, since NJE names are not case sensitive. A
+// missing place is parsed from the location, a missing location is
+// written from the place.
+func (n *Node) Validate() error {
+	n.Name = NormalizeNodeName(n.Name)
+	n.Alias = NormalizeNodeName(n.Alias)
+	n.Location = strings.TrimSpace(n.Location)
+
+	if err := ValidateNodeName("name", n.Name); err != nil {
+		return err
+	}
+	if n.Alias != "" {
+		if err := ValidateNodeName("alias", n.Alias); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(n.Platform) == "" {
+		return &ValidationError{Field: "platform", Message: "is required"}
+	}
+	if strings.TrimSpace(n.OperatingSystem) == "" {
+		return &ValidationError{Field: "os", Message: "is required"}
+	}
+	if n.Place == nil {
+		n.Place = ParsePlace(n.Location)
+	} else if err := n.Place.validate(); err != nil {
+		return err
+	}
+	if n.Location == "" && n.Place != nil {
+		n.Location = n.Place.String()
+	}
+	return nil
+}
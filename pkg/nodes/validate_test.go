@@ -0,0 +1,103 @@
+package nodes_test
+
+import (
+	. "github.com/mvslovers/hnetdb/pkg/nodes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"strings"
+)
+
+var _ = Describe("Validation", func() {
+
+	DescribeTable("node names",
+		func(name string, valid bool) {
+			err := ValidateNodeName("name", name)
+			if valid {
+				Expect(err).To(BeNil())
+			} else {
+				Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			}
+		},
+		Entry("single letter", "A", true),
+		Entry("eight characters", "DRNBRX1A", true),
+		Entry("national characters", "A@#$", true),
+		Entry("empty", "", false),
+		Entry("nine characters", "DRNBRX1AB", false),
+		Entry("leading digit", "1DRNBRX", false),
+		Entry("leading national character", "$DRN", false),
+		Entry("lower case", "drnbrx1a", false),
+		Entry("punctuation", "DRN-BRX", false),
+	)
+
+	It("normalizes and validates nodes", func() {
+		node := &Node{
+			Name:            " drnbrx1a ",
+			Alias:           "debrxmvs",
+			Platform:        "Hercules 4 on Linux",
+			OperatingSystem: "MVS3.8J",
+		}
+
+		Expect(node.Validate()).To(Succeed())
+		Expect(node.Name).To(Equal("DRNBRX1A"))
+		Expect(node.Alias).To(Equal("DEBRXMVS"))
+	})
+
+	It("validates aliases", func() {
+		node := &Node{
+			Name:            "DRNBRX1A",
+			Alias:           "DE-BRX-MVS",
+			Platform:        "Hercules 4 on Linux",
+			OperatingSystem: "MVS3.8J",
+		}
+
+		err := node.Validate()
+
+		Expect(err).To(Equal(&ValidationError{
+			Field:   "alias",
+			Message: "must be 1-8 characters of A-Z, 0-9, @, # or $ starting with a letter",
+		}))
+	})
+	It("parses places from locations and writes locations from places", func() {
+		node := &Node{Name: "DRNBRX1A", Platform: "Linux", OperatingSystem: "MVS3.8J", Location: " London, UK "}
+
+		Expect(node.Validate()).To(Succeed())
+		Expect(node.Location).To(Equal("London, UK"))
+		Expect(node.Place).To(Equal(&Place{Country: "GB", City: "London"}))
+
+		node.Location = ""
+		node.Place = &Place{Country: "de", City: " Berlin ", Coordinates: &Coordinates{Latitude: 52.52, Longitude: 13.4}}
+
+		Expect(node.Validate()).To(Succeed())
+		Expect(node.Location).To(Equal("Berlin, Germany"))
+		Expect(node.Place.Country).To(Equal("DE"))
+		Expect(node.Place.City).To(Equal("Berlin"))
+	})
+
+	DescribeTable("places",
+		func(place *Place, expected *ValidationError) {
+			node := &Node{Name: "DRNBRX1A", Platform: "Linux", OperatingSystem: "MVS3.8J", Place: place}
+
+			err := node.Validate()
+
+			if expected == nil {
+				Expect(err).To(BeNil())
+			} else {
+				Expect(err).To(Equal(expected))
+			}
+		},
+		Entry("country only", &Place{Country: "CA"}, nil),
+		Entry("no country", &Place{City: "Toronto"},
+			&ValidationError{Field: "place.country", Message: "is required"}),
+		Entry("unknown country", &Place{Country: "XX"},
+			&ValidationError{Field: "place.country", Message: "has to be an ISO 3166-1 alpha-2 code"}),
+		Entry("country name", &Place{Country: "Canada"},
+			&ValidationError{Field: "place.country", Message: "has to be an ISO 3166-1 alpha-2 code"}),
+		Entry("long city", &Place{Country: "CA", City: strings.Repeat("x", 65)},
+			&ValidationError{Field: "place.city", Message: "may have at most 64 characters"}),
+		Entry("latitude", &Place{Country: "CA", Coordinates: &Coordinates{Latitude: 91}},
+			&ValidationError{Field: "place.coordinates.latitude", Message: "has to be from -90 to 90"}),
+		Entry("longitude", &Place{Country: "CA", Coordinates: &Coordinates{Longitude: -181}},
+			&ValidationError{Field: "place.coordinates.longitude", Message: "has to be from -180 to 180"}),
+	)
+})
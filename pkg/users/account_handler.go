@@ -0,0 +1,124 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"io/ioutil"
+	"net/http"
+)
+
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+type TokenRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password,omitempty"`
+}
+
+// EmailVerificationHandler redeems verification tokens on Path and mails
+// new ones on Path/resend. If Throttle is set, it limits the mails sent
+// to an address and requested by a client.
+type EmailVerificationHandler struct {
+	Path         string
+	AccountMails *AccountMails
+	Throttle     *LoginThrottle
+}
+
+func (e *EmailVerificationHandler) Verify(writer http.ResponseWriter, request *http.Request) {
+	tokenRequest := TokenRequest{}
+	if !readAccountRequest(writer, request, &tokenRequest) {
+		return
+	}
+	writeAccountResult(writer, e.AccountMails.Verify(tokenRequest.Token), http.StatusNoContent)
+}
+
+// Resend answers with 202 whether or not the address is registered.
+func (e *EmailVerificationHandler) Resend(writer http.ResponseWriter, request *http.Request) {
+	emailRequest := EmailRequest{}
+	if !readAccountRequest(writer, request, &emailRequest) || !throttleMails(writer, request, e.Throttle,
+		emailRequest.Email) {
+		return
+	}
+	e.AccountMails.Background(func() error {
+		return e.AccountMails.ResendVerification(emailRequest.Email)
+	})
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+// PasswordResetHandler mails password reset tokens on Path/forgot and
+// redeems them on Path/reset. Throttle works like in
+// EmailVerificationHandler.
+type PasswordResetHandler struct {
+	Path         string
+	AccountMails *AccountMails
+	Throttle     *LoginThrottle
+}
+
+// Forgot answers with 202 whether or not the address is registered.
+func (p *PasswordResetHandler) Forgot(writer http.ResponseWriter, request *http.Request) {
+	emailRequest := EmailRequest{}
+	if !readAccountRequest(writer, request, &emailRequest) || !throttleMails(writer, request, p.Throttle,
+		emailRequest.Email) {
+		return
+	}
+	p.AccountMails.Background(func() error {
+		return p.AccountMails.SendPasswordReset(emailRequest.Email)
+	})
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+func (p *PasswordResetHandler) Reset(writer http.ResponseWriter, request *http.Request) {
+	tokenRequest := TokenRequest{}
+	if !readAccountRequest(writer, request, &tokenRequest) {
+		return
+	}
+	err := p.AccountMails.ResetPassword(tokenRequest.Token, tokenRequest.Password)
+	writeAccountResult(writer, err, http.StatusNoContent)
+}
+
+func readAccountRequest(writer http.ResponseWriter, request *http.Request, body interface{}) bool {
+	if request.Method != "POST" {
+		apierror.MethodNotAllowed(writer)
+		return false
+	}
+	requestBody, _ := ioutil.ReadAll(request.Body)
+	if json.Unmarshal(requestBody, body) != nil {
+		apierror.MalformedJSON(writer)
+		return false
+	}
+	return true
+}
+
+// throttleMails counts a mail requested for email by the client of
+// request. It answers with 429 and returns false if there were too many.
+func throttleMails(writer http.ResponseWriter, request *http.Request, throttle *LoginThrottle, email string) bool {
+	if throttle == nil {
+		return true
+	}
+	wait, err := throttle.Reserve(NormalizeEmail(email), throttle.ClientAddress(request))
+	if err != nil {
+		apierror.Internal(writer)
+		return false
+	}
+	if wait > 0 {
+		tooManyAttempts(writer, wait, "too many mails requested, try again later")
+		return false
+	}
+	return true
+}
+
+func writeAccountResult(writer http.ResponseWriter, err error, status int) {
+	var validationError *ValidationError
+	switch {
+	case errors.Is(err, ErrInvalidToken):
+		apierror.Write(writer, http.StatusBadRequest, "invalid_token", "token is invalid or expired")
+	case errors.As(err, &validationError):
+		apierror.Invalid(writer, validationError.Fields...)
+	case err != nil:
+		apierror.Internal(writer)
+	default:
+		writer.WriteHeader(status)
+	}
+}
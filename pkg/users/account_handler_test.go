@@ -0,0 +1,116 @@
+package users_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Verification and password reset", func() {
+
+	var repository *users.UserMemoryRepository
+	var mailer *RecordingMailer
+	var accountMails *users.AccountMails
+	var verificationHandler *users.EmailVerificationHandler
+	var passwordResetHandler *users.PasswordResetHandler
+
+	BeforeEach(func() {
+		repository = &users.UserMemoryRepository{}
+		Expect(repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed())
+		mailer = &RecordingMailer{}
+		accountMails = &users.AccountMails{
+			UserRepository: repository,
+			Tokens:         repository,
+			Mailer:         mailer,
+			PublicURL:      "https://hnet.example.org",
+		}
+		verificationHandler = &users.EmailVerificationHandler{Path: "/users/verify", AccountMails: accountMails}
+		passwordResetHandler = &users.PasswordResetHandler{Path: "/users/password", AccountMails: accountMails}
+	})
+
+	post := func(handler http.HandlerFunc, body string) int {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		return recorder.Code
+	}
+
+	lastToken := func() string {
+		accountMails.Wait()
+		Expect(mailer.Messages).NotTo(BeEmpty(), "A mail should be sent")
+		return linkToken.FindStringSubmatch(mailer.Messages[len(mailer.Messages)-1].Body)[1]
+	}
+
+	It("verifies email addresses", func() {
+		Expect(post(verificationHandler.Resend, `{"email": "flo@example.org"}`)).To(Equal(202))
+
+		Expect(post(verificationHandler.Verify, `{"token": "`+lastToken()+`"}`)).To(Equal(204))
+		Expect(post(verificationHandler.Verify, `{"token": "`+lastToken()+`"}`)).To(Equal(400))
+		user, _ := repository.FindByUsername("flo")
+		Expect(user.Verified).To(BeTrue())
+	})
+
+	It("does not tell which addresses are registered", func() {
+		Expect(post(verificationHandler.Resend, `{"email": "nobody@example.org"}`)).To(Equal(202))
+		Expect(post(passwordResetHandler.Forgot, `{"email": "nobody@example.org"}`)).To(Equal(202))
+		accountMails.Wait()
+		Expect(mailer.Messages).To(BeEmpty())
+	})
+
+	It("answers before the mail is out", func() {
+		accountMails.Mailer = &FailingMailer{}
+		accountMails.ErrorLog = log.New(ioutil.Discard, "", 0)
+
+		Expect(post(passwordResetHandler.Forgot, `{"email": "flo@example.org"}`)).To(Equal(202))
+		accountMails.Wait()
+	})
+
+	It("limits the mails requested", func() {
+		throttle := &users.LoginThrottle{
+			Repository:  &users.LoginAttemptMemoryRepository{},
+			Account:     users.ThrottlePolicy{FreeAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Hour},
+			Address:     users.ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour},
+			ForgetAfter: time.Hour,
+		}
+		verificationHandler.Throttle = throttle
+		passwordResetHandler.Throttle = throttle
+
+		Expect(post(passwordResetHandler.Forgot, `{"email": "flo@example.org"}`)).To(Equal(202))
+		Expect(post(verificationHandler.Resend, `{"email": "FLO@example.org"}`)).To(Equal(202))
+		Expect(post(passwordResetHandler.Forgot, `{"email": "flo@example.org"}`)).To(Equal(429))
+		Expect(post(passwordResetHandler.Forgot, `{"email": "nobody@example.org"}`)).To(Equal(202))
+		Expect(post(passwordResetHandler.Forgot, `{"email": "other@example.org"}`)).To(Equal(429))
+		accountMails.Wait()
+		Expect(mailer.Messages).To(HaveLen(2))
+	})
+
+	It("resets passwords", func() {
+		Expect(post(passwordResetHandler.Forgot, `{"email": "flo@example.org"}`)).To(Equal(202))
+		token := lastToken()
+
+		Expect(post(passwordResetHandler.Reset, `{"token": "`+token+`", "password": "short"}`)).To(Equal(422))
+		Expect(post(passwordResetHandler.Reset, `{"token": "`+token+`", "password": "n3w-passw0rd"}`)).
+			To(Equal(204))
+		Expect(post(passwordResetHandler.Reset, `{"token": "`+token+`", "password": "n3w-passw0rd"}`)).
+			To(Equal(400))
+		user, _ := repository.FindByEmailAndPassword("flo@example.org", "n3w-passw0rd")
+		Expect(user).NotTo(BeNil())
+	})
+
+	It("rejects malformed requests", func() {
+		Expect(post(passwordResetHandler.Reset, `{"token": `)).To(Equal(400))
+
+		recorder := httptest.NewRecorder()
+		passwordResetHandler.Forgot(recorder, httptest.NewRequest("GET", "/", nil))
+		Expect(recorder.Code).To(Equal(405))
+	})
+})
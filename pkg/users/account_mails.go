@@ -0,0 +1,204 @@
+package users
+
+import (
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/mail"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenPurpose tells what a one-time token was mailed for.
+type TokenPurpose string
+
+const (
+	PurposeVerification  TokenPurpose = "verification"
+	PurposePasswordReset TokenPurpose = "password-reset"
+)
+
+// The mails tell how long their tokens are valid, change them together.
+const (
+	verificationTTL  = 48 * time.Hour
+	passwordResetTTL = time.Hour
+)
+
+// OneTimeToken is the stored form of a token mailed to a user. It is
+// found by the hash of its secret and deleted when redeemed.
+type OneTimeToken struct {
+	Hash      string       `json:"hash"`
+	Username  string       `json:"username"`
+	Purpose   TokenPurpose `json:"purpose"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// OneTimeTokenRepository stores the tokens mailed to users.
+type OneTimeTokenRepository interface {
+	// SaveOneTimeToken replaces the tokens of the user with the same
+	// purpose. It fails with ErrUserNotFound for unknown users.
+	SaveOneTimeToken(token *OneTimeToken) error
+	// TakeOneTimeToken deletes and returns the token with purpose and
+	// hash. It fails with ErrInvalidToken if there is none.
+	TakeOneTimeToken(purpose TokenPurpose, hash string) (*OneTimeToken, error)
+}
+
+// AccountMails sends the mails to verify email addresses and to reset
+// forgotten passwords, and redeems the tokens in them. Links in the
+// mails point to PublicURL if set.
+type AccountMails struct {
+	UserRepository UserRepository
+	Tokens         OneTimeTokenRepository
+	Mailer         mail.Mailer
+	PublicURL      string
+	// Sessions, if set, are ended when a password is reset.
+	Sessions *Sessions
+	// ErrorLog receives the errors of mails sent in the background, the
+	// standard logger if nil.
+	ErrorLog *log.Logger
+	sending  sync.WaitGroup
+}
+
+// Background runs send without waiting for it, so that answers take the
+// same time whether or not an address is registered. Errors are logged.
+func (a *AccountMails) Background(send func() error) {
+	a.sending.Add(1)
+	go func() {
+		defer a.sending.Done()
+		if err := send(); err != nil {
+			a.logf("sending account mail: %v", err)
+		}
+	}()
+}
+
+// Wait waits until the mails sent in the background are out.
+func (a *AccountMails) Wait() {
+	a.sending.Wait()
+}
+
+func (a *AccountMails) logf(format string, args ...interface{}) {
+	if a.ErrorLog != nil {
+		a.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// SendVerification mails a verification token to the address of user.
+func (a *AccountMails) SendVerification(user *User) error {
+	token, err := a.issue(user.Username, PurposeVerification, verificationTTL)
+	if err != nil {
+		return err
+	}
+	return a.Mailer.Send(&mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address for HNET",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"please confirm that this is your email address%s\n\n"+
+			"This is possible for two days. If you did not register with HNET, ignore this mail.\n",
+			user.Username, a.instructions("verify-email", token)),
+	})
+}
+
+// ResendVerification mails a new verification token to the user with
+// email unless the address has been verified already. Unknown addresses
+// are ignored so that callers cannot tell which ones are registered.
+func (a *AccountMails) ResendVerification(email string) error {
+	user, err := a.UserRepository.FindByEmail(NormalizeEmail(email))
+	if err != nil || user == nil || user.Verified {
+		return err
+	}
+	return a.SendVerification(user)
+}
+
+// Verify redeems a verification token.
+func (a *AccountMails) Verify(token string) error {
+	stored, err := a.redeem(PurposeVerification, token)
+	if err != nil {
+		return err
+	}
+	return a.UserRepository.SetVerified(stored.Username)
+}
+
+// SendPasswordReset mails a password reset token to the user with email.
+// Unknown addresses are ignored like in ResendVerification.
+func (a *AccountMails) SendPasswordReset(email string) error {
+	user, err := a.UserRepository.FindByEmail(NormalizeEmail(email))
+	if err != nil || user == nil {
+		return err
+	}
+	token, err := a.issue(user.Username, PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	return a.Mailer.Send(&mail.Message{
+		To:      user.Email,
+		Subject: "Reset your HNET password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"somebody asked to reset your password. Choose a new one%s\n\n"+
+			"This is possible for one hour. If you did not ask for this, ignore this mail.\n",
+			user.Username, a.instructions("reset-password", token)),
+	})
+}
+
+// ResetPassword redeems a password reset token. As the token proves
+// access to the mailbox, it verifies the email address as well. The
+// password is checked before the token is redeemed, so without the
+// username.
+func (a *AccountMails) ResetPassword(token string, password string) error {
+	if err := ValidatePassword("", password); err != nil {
+		return err
+	}
+	stored, err := a.redeem(PurposePasswordReset, token)
+	if err != nil {
+		return err
+	}
+	if err := a.UserRepository.UpdatePassword(stored.Username, password); err != nil {
+		return err
+	}
+	if err := a.UserRepository.SetVerified(stored.Username); err != nil {
+		return err
+	}
+	if a.Sessions != nil {
+		return a.Sessions.EndAll(stored.Username)
+	}
+	return nil
+}
+
+func (a *AccountMails) issue(username string, purpose TokenPurpose, ttl time.Duration) (string, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	err = a.Tokens.SaveOneTimeToken(&OneTimeToken{
+		Hash:      hashSecret(token),
+		Username:  username,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	return token, err
+}
+
+func (a *AccountMails) redeem(purpose TokenPurpose, token string) (*OneTimeToken, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	stored, err := a.Tokens.TakeOneTimeToken(purpose, hashSecret(token))
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	return stored, nil
+}
+
+// instructions tells how to use token, by a link to page below PublicURL
+// if there is one.
+func (a *AccountMails) instructions(page string, token string) string {
+	if a.PublicURL == "" {
+		return " by using this token:\n\n    " + token
+	}
+	return " by opening this link:\n\n    " +
+		strings.TrimSuffix(a.PublicURL, "/") + "/" + page + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,137 @@
+package users_test
+
+import (
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/mail"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RecordingMailer keeps the mails it is asked to send. Mails sent in the
+// background are only in Messages after AccountMails.Wait.
+type RecordingMailer struct {
+	Messages []*mail.Message
+	mutex    sync.Mutex
+}
+
+func (r *RecordingMailer) Send(message *mail.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Messages = append(r.Messages, message)
+	return nil
+}
+
+// FailingMailer cannot send mails.
+type FailingMailer struct{}
+
+func (f *FailingMailer) Send(message *mail.Message) error {
+	return errors.New("mail server unreachable")
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+var _ = Describe("Account mails", func() {
+
+	var repository *users.UserMemoryRepository
+	var mailer *RecordingMailer
+	var accountMails *users.AccountMails
+
+	BeforeEach(func() {
+		repository = &users.UserMemoryRepository{}
+		Expect(repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed())
+		mailer = &RecordingMailer{}
+		accountMails = &users.AccountMails{
+			UserRepository: repository,
+			Tokens:         repository,
+			Mailer:         mailer,
+			PublicURL:      "https://hnet.example.org/",
+		}
+	})
+
+	// mailedToken returns the token of the last mail.
+	mailedToken := func() string {
+		Expect(mailer.Messages).NotTo(BeEmpty(), "A mail should be sent")
+		match := linkToken.FindStringSubmatch(mailer.Messages[len(mailer.Messages)-1].Body)
+		Expect(match).NotTo(BeNil(), "The mail should contain a link")
+		return match[1]
+	}
+
+	verified := func() bool {
+		user, err := repository.FindByUsername("flo")
+		Expect(err).To(BeNil())
+		return user.Verified
+	}
+
+	It("verifies email addresses", func() {
+		Expect(accountMails.SendVerification(&users.User{Username: "flo", Email: "flo@example.org"})).
+			To(Succeed())
+
+		Expect(mailer.Messages[0].To).To(Equal("flo@example.org"))
+		Expect(mailer.Messages[0].Body).To(ContainSubstring("https://hnet.example.org/verify-email?token="))
+		token := mailedToken()
+
+		Expect(accountMails.Verify(token)).To(Succeed())
+		Expect(verified()).To(BeTrue())
+		Expect(accountMails.Verify(token)).To(Equal(users.ErrInvalidToken))
+	})
+
+	It("only accepts the latest verification token", func() {
+		Expect(accountMails.ResendVerification("flo@example.org")).To(Succeed())
+		first := mailedToken()
+		Expect(accountMails.ResendVerification("flo@example.org")).To(Succeed())
+
+		Expect(accountMails.Verify(first)).To(Equal(users.ErrInvalidToken))
+		Expect(accountMails.Verify(mailedToken())).To(Succeed())
+	})
+
+	It("does not mail unknown or verified addresses", func() {
+		Expect(accountMails.ResendVerification("nobody@example.org")).To(Succeed())
+		Expect(accountMails.SendPasswordReset("nobody@example.org")).To(Succeed())
+		Expect(repository.SetVerified("flo")).To(Succeed())
+		Expect(accountMails.ResendVerification("flo@example.org")).To(Succeed())
+
+		Expect(mailer.Messages).To(BeEmpty())
+	})
+
+	It("resets passwords and ends sessions", func() {
+		sessions := &users.Sessions{Repository: repository, TTL: time.Hour}
+		accountMails.Sessions = sessions
+		session, _, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+
+		Expect(accountMails.SendPasswordReset("flo@example.org")).To(Succeed())
+		token := mailedToken()
+		Expect(accountMails.ResetPassword(token, "short")).To(BeAssignableToTypeOf(&users.ValidationError{}))
+		Expect(accountMails.ResetPassword(token, "n3w-passw0rd")).To(Succeed())
+
+		user, err := repository.FindByEmailAndPassword("flo@example.org", "n3w-passw0rd")
+		Expect(err).To(BeNil())
+		Expect(user).NotTo(BeNil())
+		Expect(user.Verified).To(BeTrue())
+		Expect(sessions.Active(session)).To(Equal(users.ErrInvalidToken))
+		Expect(accountMails.ResetPassword(token, "an0ther-passw0rd")).To(Equal(users.ErrInvalidToken))
+	})
+
+	It("keeps tokens apart by purpose", func() {
+		Expect(accountMails.SendPasswordReset("flo@example.org")).To(Succeed())
+
+		Expect(accountMails.Verify(mailedToken())).To(Equal(users.ErrInvalidToken))
+	})
+
+	It("mails bare tokens without public URL", func() {
+		accountMails.PublicURL = ""
+
+		Expect(accountMails.SendPasswordReset("flo@example.org")).To(Succeed())
+
+		Expect(mailer.Messages[0].Body).To(ContainSubstring("by using this token:"))
+		Expect(mailer.Messages[0].Body).NotTo(ContainSubstring("http"))
+	})
+})
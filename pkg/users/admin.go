@@ -0,0 +1,156 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type RoleChange struct {
+	Role Role `json:"role"`
+}
+
+// AdminHandler administers users. If Sessions is set, the sessions of
+// users are ended when they are locked or their role changes, so that
+// their access tokens stop working at once.
+type AdminHandler struct {
+	Path           string
+	UserRepository UserRepository
+	Sessions       *Sessions
+}
+
+// Users serves the user administration on Path, Path/{username}/role and
+// Path/{username}/lock. It has to be wrapped with RequireRole(RoleAdmin).
+func (a *AdminHandler) Users(writer http.ResponseWriter, request *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(request.URL.Path, a.Path), "/")
+	if rest == "" {
+		a.list(writer, request)
+		return
+	}
+
+	parts := strings.Split(rest, "/")
+	if len(parts) != 2 {
+		apierror.NotFound(writer, "no such resource")
+		return
+	}
+
+	var err error
+	switch {
+	case parts[1] == "role" && request.Method == "PUT":
+		requestBody, _ := ioutil.ReadAll(request.Body)
+		change := RoleChange{}
+		if json.Unmarshal(requestBody, &change) != nil {
+			apierror.MalformedJSON(writer)
+			return
+		}
+		if !change.Role.Valid() {
+			apierror.Invalid(writer, apierror.FieldError{Field: "role", Message: "is not a known role"})
+			return
+		}
+		err = a.endSessions(parts[0], a.UserRepository.UpdateRole(parts[0], change.Role))
+	case parts[1] == "lock" && request.Method == "POST":
+		err = a.endSessions(parts[0], a.UserRepository.SetLocked(parts[0], true))
+	case parts[1] == "lock" && request.Method == "DELETE":
+		err = a.UserRepository.SetLocked(parts[0], false)
+	case parts[1] == "role" || parts[1] == "lock":
+		apierror.MethodNotAllowed(writer)
+		return
+	default:
+		apierror.NotFound(writer, "no such resource")
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			apierror.NotFound(writer, "user not found")
+			return
+		}
+		apierror.Internal(writer)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// endSessions ends the sessions of username unless err tells that the
+// change before failed.
+func (a *AdminHandler) endSessions(username string, err error) error {
+	if err != nil || a.Sessions == nil {
+		return err
+	}
+	return a.Sessions.EndAll(username)
+}
+
+func (a *AdminHandler) list(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+
+	all, err := a.UserRepository.FindAll()
+	if err != nil {
+		apierror.Internal(writer)
+		return
+	}
+	if all == nil {
+		all = []*User{}
+	}
+
+	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	bytes, _ := json.Marshal(&all)
+	_, _ = writer.Write(bytes)
+}
+
+// Promote gives username role without going through the API, where only
+// admins may change roles. It is how the first admin comes to be. Like
+// AdminHandler, it ends the sessions of the user if sessions is set.
+func Promote(repository UserRepository, sessions *Sessions, username string, role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q, has to be member, moderator or admin", role)
+	}
+	if err := repository.UpdateRole(username, role); err != nil || sessions == nil {
+		return err
+	}
+	return sessions.EndAll(username)
+}
+
+// defaultFailedLogins is how many failed logins are listed without limit.
+const defaultFailedLogins = 100
+
+// FailedLoginHandler lists the latest failed logins on Path?limit=. It
+// has to be wrapped with RequireRole(RoleAdmin).
+type FailedLoginHandler struct {
+	Path       string
+	Repository LoginAttemptRepository
+}
+
+func (f *FailedLoginHandler) List(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+	limit := defaultFailedLogins
+	if value := request.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maximumMemoryFailedLogins {
+			apierror.Invalid(writer, apierror.FieldError{Field: "limit", Message: "has to be a positive number"})
+			return
+		}
+		limit = parsed
+	}
+
+	records, err := f.Repository.FindFailedLogins(limit)
+	if err != nil {
+		apierror.Internal(writer)
+		return
+	}
+	if records == nil {
+		records = []*FailedLogin{}
+	}
+	writeJSON(writer, http.StatusOK, &records)
+}
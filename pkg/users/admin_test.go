@@ -0,0 +1,207 @@
+package users_test
+
+import (
+	"encoding/json"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Administration", func() {
+
+	var repository *FakeUserRepository
+	var handler *users.AdminHandler
+
+	BeforeEach(func() {
+		repository = &FakeUserRepository{
+			Users: []*users.User{
+				{Username: "flo", Email: "florent@example.org", Role: users.RoleAdmin},
+				{Username: "mig", Email: "mig@example.org", Role: users.RoleMember},
+			},
+		}
+		handler = &users.AdminHandler{
+			Path:           "/admin/users",
+			UserRepository: repository,
+		}
+	})
+
+	It("lists users", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Users(recorder, httptest.NewRequest("GET", "/admin/users", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		var all []*users.User
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &all)).To(Succeed())
+		Expect(all).To(Equal(repository.Users))
+	})
+
+	It("changes roles", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Users(recorder, httptest.NewRequest("PUT", "/admin/users/mig/role",
+			strings.NewReader(`{"role":"moderator"}`)))
+
+		Expect(recorder.Code).To(Equal(204))
+		Expect(repository.Users[1].Role).To(Equal(users.RoleModerator))
+	})
+
+	It("rejects unknown roles", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Users(recorder, httptest.NewRequest("PUT", "/admin/users/mig/role",
+			strings.NewReader(`{"role":"root"}`)))
+
+		Expect(recorder.Code).To(Equal(422))
+	})
+
+	It("locks and unlocks accounts", func() {
+		recorder := httptest.NewRecorder()
+		handler.Users(recorder, httptest.NewRequest("POST", "/admin/users/mig/lock", nil))
+		Expect(recorder.Code).To(Equal(204))
+		Expect(repository.Users[1].Locked).To(BeTrue())
+
+		recorder = httptest.NewRecorder()
+		handler.Users(recorder, httptest.NewRequest("DELETE", "/admin/users/mig/lock", nil))
+		Expect(recorder.Code).To(Equal(204))
+		Expect(repository.Users[1].Locked).To(BeFalse())
+	})
+
+	It("returns not found for unknown users", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.Users(recorder, httptest.NewRequest("POST", "/admin/users/nobody/lock", nil))
+
+		Expect(recorder.Code).To(Equal(404))
+	})
+})
+
+var _ = Describe("Administration of logged in users", func() {
+
+	var repository *users.UserMemoryRepository
+	var authenticator *users.Authenticator
+	var handler *users.AdminHandler
+	var token string
+
+	BeforeEach(func() {
+		repository = &users.UserMemoryRepository{}
+		Expect(repository.RegisterUser(&users.User{
+			Username: "mig",
+			Email:    "mig@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed())
+		authenticator = &users.Authenticator{
+			Tokens:   &users.Tokens{Secret: []byte("test-secret"), TTL: 15 * time.Minute},
+			Sessions: &users.Sessions{Repository: repository, TTL: time.Hour},
+		}
+		handler = &users.AdminHandler{
+			Path:           "/admin/users",
+			UserRepository: repository,
+			Sessions:       authenticator.Sessions,
+		}
+		session, _, err := authenticator.Sessions.Start("mig")
+		Expect(err).To(BeNil())
+		token, err = authenticator.Tokens.Create(&users.User{Username: "mig", Role: users.RoleMember}, session)
+		Expect(err).To(BeNil())
+	})
+
+	authenticated := func() int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/users/me", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		authenticator.Authenticate(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusOK)
+		})(recorder, request)
+		return recorder.Code
+	}
+
+	It("rejects the tokens of locked users at once", func() {
+		Expect(authenticated()).To(Equal(200))
+
+		recorder := httptest.NewRecorder()
+		handler.Users(recorder, httptest.NewRequest("POST", "/admin/users/mig/lock", nil))
+		Expect(recorder.Code).To(Equal(204))
+
+		Expect(authenticated()).To(Equal(401))
+	})
+
+	It("rejects tokens with a former role at once", func() {
+		recorder := httptest.NewRecorder()
+		handler.Users(recorder, httptest.NewRequest("PUT", "/admin/users/mig/role",
+			strings.NewReader(`{"role":"moderator"}`)))
+		Expect(recorder.Code).To(Equal(204))
+
+		Expect(authenticated()).To(Equal(401))
+	})
+})
+
+var _ = Describe("Promotion", func() {
+
+	var repository *FakeUserRepository
+
+	BeforeEach(func() {
+		repository = &FakeUserRepository{
+			Users: []*users.User{
+				{Username: "mig", Email: "mig@example.org", Role: users.RoleMember},
+			},
+		}
+	})
+
+	It("promotes the first admin", func() {
+		Expect(users.Promote(repository, nil, "mig", users.RoleAdmin)).To(Succeed())
+
+		Expect(repository.Users[0].Role).To(Equal(users.RoleAdmin))
+	})
+
+	It("rejects unknown roles", func() {
+		Expect(users.Promote(repository, nil, "mig", "root")).To(MatchError(ContainSubstring("unknown role")))
+
+		Expect(repository.Users[0].Role).To(Equal(users.RoleMember))
+	})
+
+	It("fails for unknown users", func() {
+		Expect(users.Promote(repository, nil, "nobody", users.RoleAdmin)).To(MatchError(users.ErrUserNotFound))
+	})
+})
+
+var _ = Describe("Failed login audit", func() {
+
+	var repository *users.LoginAttemptMemoryRepository
+	var handler *users.FailedLoginHandler
+
+	BeforeEach(func() {
+		repository = &users.LoginAttemptMemoryRepository{}
+		handler = &users.FailedLoginHandler{
+			Path:       "/admin/failed-logins",
+			Repository: repository,
+		}
+	})
+
+	It("lists the latest failed logins", func() {
+		at := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+		for _, email := range []string{"flo@example.org", "mig@example.org"} {
+			Expect(repository.SaveFailedLogin(&users.FailedLogin{
+				Email: email, Address: "192.0.2.1", Reason: users.ReasonInvalidCredentials, At: at,
+			}, at.Add(-time.Hour))).To(Succeed())
+		}
+		recorder := httptest.NewRecorder()
+
+		handler.List(recorder, httptest.NewRequest("GET", "/admin/failed-logins?limit=1", nil))
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(MatchJSON(`[{"email": "mig@example.org", "address": "192.0.2.1",
+			"reason": "invalid_credentials", "at": "2021-03-01T12:00:00Z"}]`))
+	})
+
+	It("rejects invalid limits", func() {
+		recorder := httptest.NewRecorder()
+
+		handler.List(recorder, httptest.NewRequest("GET", "/admin/failed-logins?limit=-1", nil))
+
+		Expect(recorder.Code).To(Equal(422))
+	})
+})
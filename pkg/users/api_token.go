@@ -0,0 +1,224 @@
+package users
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Scope limits what an API token may be used for.
+type Scope string
+
+const (
+	ScopeNodesRead  Scope = "nodes:read"
+	ScopeNodesWrite Scope = "nodes:write"
+)
+
+func (s Scope) Valid() bool {
+	return s == ScopeNodesRead || s == ScopeNodesWrite
+}
+
+// apiTokenPrefix tells API tokens apart from login tokens.
+const apiTokenPrefix = "hnetdb_"
+
+// maximumTokenNameLength keeps token names readable in listings.
+const maximumTokenNameLength = 64
+
+// APIToken is a named, long-lived token for scripts and CI jobs. Only a
+// hash of its secret is stored; Token holds the plain text form right
+// after creation and is empty otherwise.
+type APIToken struct {
+	ID        string     `json:"id"`
+	Username  string     `json:"username"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash,omitempty"`
+	Token     string     `json:"token,omitempty"`
+	Scopes    []Scope    `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Grants tells whether the token may be used for scope.
+func (t *APIToken) Grants(scope Scope) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *APIToken) expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// APITokenRepository stores the API tokens of users.
+type APITokenRepository interface {
+	// SaveAPIToken fails with ErrUserNotFound for unknown users and with
+	// ErrTokenExists if the user already has a token with the same name.
+	SaveAPIToken(token *APIToken) error
+	// FindAPIToken fails with ErrInvalidToken for unknown IDs.
+	FindAPIToken(id string) (*APIToken, error)
+	// FindAPITokens returns the tokens of username ordered by name.
+	FindAPITokens(username string) ([]*APIToken, error)
+	// DeleteAPIToken fails with ErrTokenNotFound unless username has a
+	// token with the given ID.
+	DeleteAPIToken(username string, id string) error
+}
+
+// APITokens creates and checks API tokens. Their plain text form is
+// hnetdb_{id}.{secret}. Tokens of locked users are rejected.
+type APITokens struct {
+	Repository     APITokenRepository
+	UserRepository UserRepository
+}
+
+// Create issues a token for username. expiresAt may be nil for a token
+// that lasts until it is revoked.
+func (a *APITokens) Create(username string, name string, scopes []Scope, expiresAt *time.Time) (*APIToken, error) {
+	now := time.Now()
+	if err := validateAPIToken(name, scopes, expiresAt, now); err != nil {
+		return nil, err
+	}
+
+	id, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	token := &APIToken{
+		ID:        id,
+		Username:  username,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		CreatedAt: now.UTC(),
+		ExpiresAt: expiresAt,
+	}
+	if err := a.Repository.SaveAPIToken(token); err != nil {
+		return nil, err
+	}
+	token.Hash = ""
+	token.Token = apiTokenPrefix + id + "." + secret
+	return token, nil
+}
+
+// List returns the tokens of username without their hashes.
+func (a *APITokens) List(username string) ([]*APIToken, error) {
+	tokens, err := a.Repository.FindAPITokens(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		token.Hash = ""
+	}
+	return tokens, nil
+}
+
+func (a *APITokens) Revoke(username string, id string) error {
+	return a.Repository.DeleteAPIToken(username, id)
+}
+
+// Validate checks a token issued by Create and returns it together with
+// the user it belongs to.
+func (a *APITokens) Validate(tokenString string) (*APIToken, *User, error) {
+	parts := strings.SplitN(strings.TrimPrefix(tokenString, apiTokenPrefix), ".", 2)
+	if !strings.HasPrefix(tokenString, apiTokenPrefix) ||
+		len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, nil, ErrInvalidToken
+	}
+
+	token, err := a.Repository.FindAPIToken(parts[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashSecret(parts[1]))) != 1 ||
+		token.expired(time.Now()) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	user, err := a.UserRepository.FindByUsername(token.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || user.Locked {
+		return nil, nil, ErrInvalidToken
+	}
+	token.Hash = ""
+	return token, user, nil
+}
+
+func validateAPIToken(name string, scopes []Scope, expiresAt *time.Time, now time.Time) error {
+	v := &validation{}
+	if strings.TrimSpace(name) == "" {
+		v.reject("name", "is required")
+	} else if len(name) > maximumTokenNameLength {
+		v.reject("name", "is too long")
+	}
+	if len(scopes) == 0 {
+		v.reject("scopes", "need at least one scope")
+	}
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			v.reject("scopes", "contain unknown scope "+string(scope))
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		v.reject("expires_at", "has to be in the future")
+	}
+	return v.err()
+}
+
+const apiTokenKey contextKey = "api-token"
+
+// ContextWithAPIToken marks a request as authenticated with token.
+func ContextWithAPIToken(ctx context.Context, token *APIToken) context.Context {
+	return context.WithValue(ctx, apiTokenKey, token)
+}
+
+// APITokenFromContext returns the API token a request was authenticated
+// with. It returns false for login tokens and anonymous requests.
+func APITokenFromContext(ctx context.Context) (*APIToken, bool) {
+	token, ok := ctx.Value(apiTokenKey).(*APIToken)
+	return token, ok
+}
+
+// RequireScope only passes requests on to next if they were not
+// authenticated with an API token or with one granting scope.
+func RequireScope(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return RequireScopes(scope, scope, next)
+}
+
+// RequireScopes works like RequireScope, with read for GET and HEAD and
+// write for all other requests.
+func RequireScopes(read Scope, write Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		scope := write
+		if request.Method == "GET" || request.Method == "HEAD" {
+			scope = read
+		}
+		if token, ok := APITokenFromContext(request.Context()); ok && !token.Grants(scope) {
+			apierror.Forbidden(writer, "token lacks scope "+string(scope))
+			return
+		}
+		next(writer, request)
+	}
+}
+
+// RequireLoginToken rejects requests authenticated with an API token, so
+// that tokens cannot be used to manage the account.
+func RequireLoginToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if _, ok := APITokenFromContext(request.Context()); ok {
+			apierror.Forbidden(writer, "API tokens cannot be used here")
+			return
+		}
+		next(writer, request)
+	}
+}
@@ -0,0 +1,100 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type APITokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []Scope    `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APITokenHandler lets users manage their API tokens on Path and
+// Path/{id}. It has to be wrapped with Authenticate and
+// RequireLoginToken.
+type APITokenHandler struct {
+	Path      string
+	APITokens *APITokens
+}
+
+func (a *APITokenHandler) Tokens(writer http.ResponseWriter, request *http.Request) {
+	username, ok := UsernameFromContext(request.Context())
+	if !ok {
+		apierror.Unauthorized(writer)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(request.URL.Path, a.Path), "/")
+	switch {
+	case id == "" && request.Method == "GET":
+		a.list(writer, username)
+	case id == "" && request.Method == "POST":
+		a.create(writer, request, username)
+	case id != "" && !strings.Contains(id, "/") && request.Method == "DELETE":
+		a.revoke(writer, username, id)
+	case id == "" || !strings.Contains(id, "/"):
+		apierror.MethodNotAllowed(writer)
+	default:
+		apierror.NotFound(writer, "no such resource")
+	}
+}
+
+func (a *APITokenHandler) list(writer http.ResponseWriter, username string) {
+	tokens, err := a.APITokens.List(username)
+	if err != nil {
+		apierror.Internal(writer)
+		return
+	}
+	if tokens == nil {
+		tokens = []*APIToken{}
+	}
+	writeJSON(writer, http.StatusOK, &tokens)
+}
+
+func (a *APITokenHandler) create(writer http.ResponseWriter, request *http.Request, username string) {
+	requestBody, _ := ioutil.ReadAll(request.Body)
+	tokenRequest := APITokenRequest{}
+	if json.Unmarshal(requestBody, &tokenRequest) != nil {
+		apierror.MalformedJSON(writer)
+		return
+	}
+
+	token, err := a.APITokens.Create(username, tokenRequest.Name, tokenRequest.Scopes, tokenRequest.ExpiresAt)
+	var validationError *ValidationError
+	switch {
+	case errors.As(err, &validationError):
+		apierror.Invalid(writer, validationError.Fields...)
+	case errors.Is(err, ErrTokenExists):
+		apierror.Write(writer, http.StatusConflict, "token_name_taken", "a token with this name exists already")
+	case err != nil:
+		apierror.Internal(writer)
+	default:
+		writeJSON(writer, http.StatusCreated, token)
+	}
+}
+
+func (a *APITokenHandler) revoke(writer http.ResponseWriter, username string, id string) {
+	err := a.APITokens.Revoke(username, id)
+	switch {
+	case errors.Is(err, ErrTokenNotFound):
+		apierror.NotFound(writer, "token not found")
+	case err != nil:
+		apierror.Internal(writer)
+	default:
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeJSON(writer http.ResponseWriter, status int, value interface{}) {
+	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	bytes, _ := json.Marshal(value)
+	_, _ = writer.Write(bytes)
+}
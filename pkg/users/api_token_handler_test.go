@@ -0,0 +1,95 @@
+package users_test
+
+import (
+	"encoding/json"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("API token handler", func() {
+
+	var repository *users.UserMemoryRepository
+	var handler *users.APITokenHandler
+
+	BeforeEach(func() {
+		repository = &users.UserMemoryRepository{}
+		for _, username := range []string{"flo", "bob"} {
+			Expect(repository.RegisterUser(&users.User{
+				Username: username,
+				Email:    username + "@example.org",
+				Password: "sup3rpassw0rd",
+			})).To(Succeed())
+		}
+		handler = &users.APITokenHandler{
+			Path:      "/users/me/tokens",
+			APITokens: &users.APITokens{Repository: repository, UserRepository: repository},
+		}
+	})
+
+	serve := func(username string, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request = request.WithContext(users.ContextWithUsername(request.Context(), username))
+		recorder := httptest.NewRecorder()
+		handler.Tokens(recorder, request)
+		return recorder
+	}
+
+	created := func(recorder *httptest.ResponseRecorder) *users.APIToken {
+		Expect(recorder.Code).To(Equal(201))
+		token := &users.APIToken{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), token)).To(Succeed())
+		return token
+	}
+
+	It("creates tokens", func() {
+		token := created(serve("flo", "POST", handler.Path,
+			`{"name": "ci", "scopes": ["nodes:read", "nodes:write"], "expires_at": "2999-01-01T00:00:00Z"}`))
+
+		Expect(token.Name).To(Equal("ci"))
+		Expect(token.Scopes).To(Equal([]users.Scope{users.ScopeNodesRead, users.ScopeNodesWrite}))
+		Expect(token.ExpiresAt.Year()).To(Equal(2999))
+		Expect(token.Token).To(HavePrefix("hnetdb_"))
+		Expect(token.Hash).To(BeEmpty())
+	})
+
+	It("rejects invalid and duplicate tokens", func() {
+		created(serve("flo", "POST", handler.Path, `{"name": "ci", "scopes": ["nodes:read"]}`))
+
+		Expect(serve("flo", "POST", handler.Path, `{"name": "ci", "scopes": ["nodes:read"]}`).Code).
+			To(Equal(409))
+		Expect(serve("flo", "POST", handler.Path, `{"name": "other", "scopes": ["admin"]}`).Code).
+			To(Equal(422))
+		Expect(serve("flo", "POST", handler.Path, `{"name": `).Code).To(Equal(400))
+	})
+
+	It("lists the tokens of the user only", func() {
+		created(serve("flo", "POST", handler.Path, `{"name": "ci", "scopes": ["nodes:read"]}`))
+		created(serve("bob", "POST", handler.Path, `{"name": "bobs", "scopes": ["nodes:read"]}`))
+
+		recorder := serve("flo", "GET", handler.Path, "")
+
+		Expect(recorder.Code).To(Equal(200))
+		var tokens []*users.APIToken
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &tokens)).To(Succeed())
+		Expect(tokens).To(HaveLen(1))
+		Expect(tokens[0].Name).To(Equal("ci"))
+		Expect(tokens[0].Token).To(BeEmpty())
+		Expect(recorder.Body.String()).NotTo(ContainSubstring("hash"))
+	})
+
+	It("revokes own tokens only", func() {
+		token := created(serve("flo", "POST", handler.Path, `{"name": "ci", "scopes": ["nodes:read"]}`))
+
+		Expect(serve("bob", "DELETE", handler.Path+"/"+token.ID, "").Code).To(Equal(404))
+		Expect(serve("flo", "DELETE", handler.Path+"/"+token.ID, "").Code).To(Equal(204))
+		Expect(serve("flo", "DELETE", handler.Path+"/"+token.ID, "").Code).To(Equal(404))
+	})
+
+	It("rejects other methods", func() {
+		Expect(serve("flo", "PUT", handler.Path, "").Code).To(Equal(405))
+		Expect(serve("flo", "GET", handler.Path+"/some-id", "").Code).To(Equal(405))
+	})
+})
@@ -0,0 +1,171 @@
+package users_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("API tokens", func() {
+
+	var repository *users.UserMemoryRepository
+	var apiTokens *users.APITokens
+	var authenticator *users.Authenticator
+
+	BeforeEach(func() {
+		repository = &users.UserMemoryRepository{}
+		Expect(repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed())
+		Expect(repository.UpdateRole("flo", users.RoleAdmin)).To(Succeed())
+		apiTokens = &users.APITokens{Repository: repository, UserRepository: repository}
+		authenticator = &users.Authenticator{
+			Tokens:    &users.Tokens{Secret: []byte("test-secret"), TTL: 15 * time.Minute},
+			APITokens: apiTokens,
+		}
+	})
+
+	create := func(scopes ...users.Scope) *users.APIToken {
+		token, err := apiTokens.Create("flo", "ci", scopes, nil)
+		Expect(err).To(BeNil(), "Token should be created")
+		return token
+	}
+
+	It("hands out the plain token only once", func() {
+		token := create(users.ScopeNodesWrite)
+
+		Expect(token.Token).To(HavePrefix("hnetdb_" + token.ID + "."))
+		Expect(token.Hash).To(BeEmpty())
+
+		listed, err := apiTokens.List("flo")
+		Expect(err).To(BeNil())
+		Expect(listed).To(HaveLen(1))
+		Expect(listed[0].Name).To(Equal("ci"))
+		Expect(listed[0].Token).To(BeEmpty())
+		Expect(listed[0].Hash).To(BeEmpty())
+	})
+
+	It("validates names, scopes and expiry", func() {
+		past := time.Now().Add(-time.Hour)
+
+		_, err := apiTokens.Create("flo", " ", []users.Scope{"nodes:delete"}, &past)
+
+		Expect(err).To(BeAssignableToTypeOf(&users.ValidationError{}))
+		Expect(err.(*users.ValidationError).Fields).To(HaveLen(3))
+
+		_, err = apiTokens.Create("flo", "ci", nil, nil)
+		Expect(err).To(BeAssignableToTypeOf(&users.ValidationError{}))
+	})
+
+	It("checks tokens", func() {
+		token := create(users.ScopeNodesRead)
+
+		validated, user, err := apiTokens.Validate(token.Token)
+
+		Expect(err).To(BeNil())
+		Expect(validated.ID).To(Equal(token.ID))
+		Expect(user.Username).To(Equal("flo"))
+
+		for _, invalid := range []string{"", "hnetdb_", token.ID + ".secret", "hnetdb_" + token.ID + ".wrong"} {
+			_, _, err = apiTokens.Validate(invalid)
+			Expect(err).To(Equal(users.ErrInvalidToken), invalid)
+		}
+	})
+
+	It("rejects expired and revoked tokens and those of locked users", func() {
+		soon := time.Now().Add(50 * time.Millisecond)
+		expiring, err := apiTokens.Create("flo", "expiring", []users.Scope{users.ScopeNodesRead}, &soon)
+		Expect(err).To(BeNil())
+		Eventually(func() error {
+			_, _, err := apiTokens.Validate(expiring.Token)
+			return err
+		}).Should(Equal(users.ErrInvalidToken))
+
+		token := create(users.ScopeNodesRead)
+		Expect(repository.SetLocked("flo", true)).To(Succeed())
+		_, _, err = apiTokens.Validate(token.Token)
+		Expect(err).To(Equal(users.ErrInvalidToken))
+
+		Expect(repository.SetLocked("flo", false)).To(Succeed())
+		Expect(apiTokens.Revoke("flo", token.ID)).To(Succeed())
+		_, _, err = apiTokens.Validate(token.Token)
+		Expect(err).To(Equal(users.ErrInvalidToken))
+		Expect(apiTokens.Revoke("flo", token.ID)).To(Equal(users.ErrTokenNotFound))
+	})
+
+	It("rejects duplicate names", func() {
+		create(users.ScopeNodesRead)
+
+		_, err := apiTokens.Create("flo", "ci", []users.Scope{users.ScopeNodesRead}, nil)
+
+		Expect(err).To(Equal(users.ErrTokenExists))
+	})
+
+	Describe("authentication", func() {
+
+		serve := func(token string, method string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, "/node", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			recorder := httptest.NewRecorder()
+			authenticator.Authenticate(handler)(recorder, request)
+			return recorder
+		}
+
+		ok := func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusNoContent)
+		}
+
+		It("accepts API tokens with member rights", func() {
+			token := create(users.ScopeNodesWrite)
+			var user *users.User
+
+			recorder := serve(token.Token, "POST", func(writer http.ResponseWriter, request *http.Request) {
+				user, _ = users.UserFromContext(request.Context())
+				ok(writer, request)
+			})
+
+			Expect(recorder.Code).To(Equal(204))
+			Expect(user.Username).To(Equal("flo"))
+			Expect(user.Role).To(Equal(users.RoleMember))
+			Expect(serve(token.Token, "GET", users.RequireRole(users.RoleAdmin, ok)).Code).To(Equal(403))
+		})
+
+		It("rejects unknown API tokens", func() {
+			Expect(serve("hnetdb_unknown.secret", "POST", ok).Code).To(Equal(401))
+		})
+
+		It("enforces scopes", func() {
+			read := create(users.ScopeNodesRead)
+			handler := users.RequireScopes(users.ScopeNodesRead, users.ScopeNodesWrite, ok)
+
+			Expect(serve(read.Token, "GET", handler).Code).To(Equal(204))
+			Expect(serve(read.Token, "POST", handler).Code).To(Equal(403))
+		})
+
+		It("does not limit login tokens", func() {
+			login, err := authenticator.Tokens.Create(&users.User{Username: "flo"}, "")
+			Expect(err).To(BeNil())
+			handler := users.RequireScope(users.ScopeNodesWrite, users.RequireLoginToken(ok))
+
+			Expect(serve(login, "POST", handler).Code).To(Equal(204))
+		})
+
+		It("keeps API tokens from managing the account", func() {
+			token := create(users.ScopeNodesRead, users.ScopeNodesWrite)
+
+			Expect(serve(token.Token, "GET", users.RequireLoginToken(ok)).Code).To(Equal(403))
+		})
+
+		It("rejects API tokens unless they are enabled", func() {
+			authenticator.APITokens = nil
+			token := create(users.ScopeNodesWrite)
+
+			Expect(serve(token.Token, "POST", ok).Code).To(Equal(401))
+		})
+	})
+})
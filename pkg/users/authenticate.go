@@ -0,0 +1,116 @@
+package users
+
+import (
+	"context"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const userKey contextKey = "user"
+
+// Authenticator checks the bearer tokens of incoming requests. If
+// Sessions is set, access tokens are only accepted while the login
+// session they were issued for has not been revoked. If APITokens is
+// set, API tokens are accepted as well; requests made with them act
+// with member rights, whatever the role of the user.
+type Authenticator struct {
+	Tokens    *Tokens
+	Sessions  *Sessions
+	APITokens *APITokens
+}
+
+// Authenticate only passes requests carrying a valid bearer token on to
+// next. The authenticated user is available through UserFromContext.
+func (a *Authenticator) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		tokenString := bearerToken(request)
+		if tokenString == "" {
+			apierror.Unauthorized(writer)
+			return
+		}
+		if a.APITokens != nil && strings.HasPrefix(tokenString, apiTokenPrefix) {
+			token, user, err := a.APITokens.Validate(tokenString)
+			if err != nil {
+				apierror.Unauthorized(writer)
+				return
+			}
+			ctx := ContextWithUser(request.Context(), &User{
+				Username: user.Username,
+				Role:     RoleMember,
+				Verified: user.Verified,
+			})
+			ctx = ContextWithAPIToken(ctx, token)
+			next(writer, request.WithContext(ctx))
+			return
+		}
+		user, session, err := a.Tokens.Validate(tokenString)
+		if err != nil {
+			apierror.Unauthorized(writer)
+			return
+		}
+		if a.Sessions != nil {
+			if session == "" || a.Sessions.Active(session) != nil {
+				apierror.Unauthorized(writer)
+				return
+			}
+		}
+		next(writer, request.WithContext(ContextWithUser(request.Context(), user)))
+	}
+}
+
+// AuthenticateWrites works like Authenticate but lets reading requests
+// through without a token.
+func (a *Authenticator) AuthenticateWrites(next http.HandlerFunc) http.HandlerFunc {
+	authenticated := a.Authenticate(next)
+	return func(writer http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case "GET", "HEAD", "OPTIONS":
+			next(writer, request)
+		default:
+			authenticated(writer, request)
+		}
+	}
+}
+
+func ContextWithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// ContextWithUsername authenticates a verified member called username.
+func ContextWithUsername(ctx context.Context, username string) context.Context {
+	return ContextWithUser(ctx, &User{Username: username, Role: RoleMember, Verified: true})
+}
+
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userKey).(*User)
+	return user, ok
+}
+
+func UsernameFromContext(ctx context.Context) (string, bool) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return user.Username, true
+}
+
+// RoleFromContext returns the role of the authenticated user or an
+// empty role for anonymous requests.
+func RoleFromContext(ctx context.Context) Role {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return user.Role
+}
+
+func bearerToken(request *http.Request) string {
+	header := request.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
@@ -0,0 +1,133 @@
+package users_test
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Authentication", func() {
+
+	var calledWith string
+	var called bool
+	next := func(writer http.ResponseWriter, request *http.Request) {
+		called = true
+		calledWith, _ = users.UsernameFromContext(request.Context())
+	}
+
+	tokens := &users.Tokens{Secret: []byte("test-secret"), TTL: 15 * time.Minute}
+	authenticator := &users.Authenticator{Tokens: tokens}
+
+	BeforeEach(func() {
+		called = false
+		calledWith = ""
+	})
+
+	request := func(method string, token string) *http.Request {
+		request := httptest.NewRequest(method, "/node", nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		return request
+	}
+
+	signed := func(claims jwt.MapClaims, secret string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte(secret))
+		Expect(err).To(BeNil(), "token should be signed")
+		return token
+	}
+
+	It("passes authenticated requests on", func() {
+		token, err := tokens.Create(&users.User{Username: "flo"}, "")
+		Expect(err).To(BeNil())
+		recorder := httptest.NewRecorder()
+
+		authenticator.Authenticate(next)(recorder, request("POST", token))
+
+		Expect(called).To(BeTrue())
+		Expect(calledWith).To(Equal("flo"))
+	})
+
+	It("rejects requests without token", func() {
+		recorder := httptest.NewRecorder()
+
+		authenticator.Authenticate(next)(recorder, request("POST", ""))
+
+		Expect(called).To(BeFalse())
+		Expect(recorder.Code).To(Equal(401))
+		Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal("Bearer"))
+	})
+
+	It("rejects expired tokens", func() {
+		token := signed(jwt.MapClaims{
+			"authorized": true,
+			"user_id":    "flo",
+			"exp":        time.Now().Add(-time.Minute).Unix(),
+		}, "test-secret")
+		recorder := httptest.NewRecorder()
+
+		authenticator.Authenticate(next)(recorder, request("POST", token))
+
+		Expect(recorder.Code).To(Equal(401))
+	})
+
+	It("rejects tokens without expiry", func() {
+		token := signed(jwt.MapClaims{
+			"authorized": true,
+			"user_id":    "flo",
+		}, "test-secret")
+		recorder := httptest.NewRecorder()
+
+		authenticator.Authenticate(next)(recorder, request("POST", token))
+
+		Expect(recorder.Code).To(Equal(401))
+	})
+
+	It("rejects unauthorized tokens", func() {
+		token := signed(jwt.MapClaims{
+			"authorized": false,
+			"user_id":    "flo",
+			"exp":        time.Now().Add(time.Minute).Unix(),
+		}, "test-secret")
+		recorder := httptest.NewRecorder()
+
+		authenticator.Authenticate(next)(recorder, request("POST", token))
+
+		Expect(recorder.Code).To(Equal(401))
+	})
+
+	It("rejects tokens with a wrong signature", func() {
+		token := signed(jwt.MapClaims{
+			"authorized": true,
+			"user_id":    "flo",
+			"exp":        time.Now().Add(time.Minute).Unix(),
+		}, "some-other-secret")
+		recorder := httptest.NewRecorder()
+
+		authenticator.Authenticate(next)(recorder, request("POST", token))
+
+		Expect(recorder.Code).To(Equal(401))
+	})
+
+	It("lets reads through without token", func() {
+		recorder := httptest.NewRecorder()
+
+		authenticator.AuthenticateWrites(next)(recorder, request("GET", ""))
+
+		Expect(called).To(BeTrue())
+		Expect(calledWith).To(Equal(""))
+	})
+
+	It("requires a token for writes", func() {
+		recorder := httptest.NewRecorder()
+
+		authenticator.AuthenticateWrites(next)(recorder, request("DELETE", ""))
+
+		Expect(called).To(BeFalse())
+		Expect(recorder.Code).To(Equal(401))
+	})
+})
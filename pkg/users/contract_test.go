@@ -0,0 +1,613 @@
+package users_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/filestore"
+	"github.com/mvslovers/hnetdb/pkg/migrations"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/testcontainers/testcontainers-go"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// userRepositoryContract holds the specs every UserRepository has to
+// pass. newRepository is called before each spec and has to return an
+// empty repository.
+func userRepositoryContract(newRepository func() users.UserRepository) {
+
+	var repository users.UserRepository
+
+	BeforeEach(func() {
+		repository = newRepository()
+		Expect(repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed(), "User should be registered")
+	})
+
+	It("finds users by email and password", func() {
+		user, err := repository.FindByEmailAndPassword("flo@example.org", "sup3rpassw0rd")
+
+		Expect(err).To(BeNil())
+		Expect(user).To(Equal(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Role:     users.RoleMember,
+		}))
+	})
+
+	It("does not find users with the wrong password", func() {
+		user, err := repository.FindByEmailAndPassword("flo@example.org", "wrong")
+
+		Expect(err).To(BeNil())
+		Expect(user).To(BeNil())
+
+		user, err = repository.FindByEmailAndPassword("nobody@example.org", "sup3rpassw0rd")
+
+		Expect(err).To(BeNil())
+		Expect(user).To(BeNil())
+	})
+
+	It("rejects duplicate user names and email addresses", func() {
+		err := repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "other@example.org",
+			Password: "other-password",
+		})
+		Expect(err).To(Equal(users.ErrUserExists))
+
+		err = repository.RegisterUser(&users.User{
+			Username: "other",
+			Email:    "flo@example.org",
+			Password: "other-password",
+		})
+		Expect(err).To(Equal(users.ErrUserExists))
+	})
+
+	It("lists users by name", func() {
+		Expect(repository.RegisterUser(&users.User{
+			Username: "bob",
+			Email:    "bob@example.org",
+			Password: "bobs-password",
+		})).To(Succeed())
+
+		all, err := repository.FindAll()
+
+		Expect(err).To(BeNil())
+		Expect(all).To(Equal([]*users.User{
+			{Username: "bob", Email: "bob@example.org", Role: users.RoleMember},
+			{Username: "flo", Email: "flo@example.org", Role: users.RoleMember},
+		}))
+	})
+
+	It("changes roles and locks accounts", func() {
+		Expect(repository.UpdateRole("flo", users.RoleAdmin)).To(Succeed())
+		Expect(repository.SetLocked("flo", true)).To(Succeed())
+
+		user, err := repository.FindByEmailAndPassword("flo@example.org", "sup3rpassw0rd")
+
+		Expect(err).To(BeNil())
+		Expect(user.Role).To(Equal(users.RoleAdmin))
+		Expect(user.Locked).To(BeTrue())
+	})
+
+	It("reports unknown users", func() {
+		Expect(repository.UpdateRole("nobody", users.RoleAdmin)).To(Equal(users.ErrUserNotFound))
+		Expect(repository.SetLocked("nobody", true)).To(Equal(users.ErrUserNotFound))
+	})
+
+	It("finds users by name", func() {
+		user, err := repository.FindByUsername("flo")
+
+		Expect(err).To(BeNil())
+		Expect(user).To(Equal(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Role:     users.RoleMember,
+		}))
+
+		user, err = repository.FindByUsername("nobody")
+
+		Expect(err).To(BeNil())
+		Expect(user).To(BeNil())
+	})
+
+	It("finds users by email", func() {
+		user, err := repository.FindByEmail("flo@example.org")
+
+		Expect(err).To(BeNil())
+		Expect(user.Username).To(Equal("flo"))
+
+		user, err = repository.FindByEmail("nobody@example.org")
+
+		Expect(err).To(BeNil())
+		Expect(user).To(BeNil())
+	})
+
+	It("verifies users and changes passwords", func() {
+		Expect(repository.SetVerified("flo")).To(Succeed())
+		Expect(repository.UpdatePassword("flo", "n3w-passw0rd")).To(Succeed())
+
+		user, err := repository.FindByEmailAndPassword("flo@example.org", "n3w-passw0rd")
+
+		Expect(err).To(BeNil())
+		Expect(user.Verified).To(BeTrue())
+		Expect(repository.SetVerified("nobody")).To(Equal(users.ErrUserNotFound))
+		Expect(repository.UpdatePassword("nobody", "n3w-passw0rd")).To(Equal(users.ErrUserNotFound))
+	})
+
+	It("takes mailed tokens once", func() {
+		mailed := repository.(users.OneTimeTokenRepository)
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+		for _, hash := range []string{"replaced", "current"} {
+			Expect(mailed.SaveOneTimeToken(&users.OneTimeToken{
+				Hash:      hash,
+				Username:  "flo",
+				Purpose:   users.PurposeVerification,
+				ExpiresAt: expiresAt,
+			})).To(Succeed())
+		}
+		Expect(mailed.SaveOneTimeToken(&users.OneTimeToken{
+			Hash: "other", Username: "nobody", Purpose: users.PurposeVerification, ExpiresAt: expiresAt,
+		})).To(Equal(users.ErrUserNotFound))
+
+		_, err := mailed.TakeOneTimeToken(users.PurposeVerification, "replaced")
+		Expect(err).To(Equal(users.ErrInvalidToken))
+		_, err = mailed.TakeOneTimeToken(users.PurposePasswordReset, "current")
+		Expect(err).To(Equal(users.ErrInvalidToken))
+
+		token, err := mailed.TakeOneTimeToken(users.PurposeVerification, "current")
+		Expect(err).To(BeNil())
+		Expect(token.Username).To(Equal("flo"))
+		Expect(token.ExpiresAt).To(BeTemporally("==", expiresAt))
+
+		_, err = mailed.TakeOneTimeToken(users.PurposeVerification, "current")
+		Expect(err).To(Equal(users.ErrInvalidToken))
+	})
+
+	It("renames users with their tokens", func() {
+		Expect(repository.(users.RefreshTokenRepository).SaveRefreshToken(&users.RefreshToken{
+			ID: "session", Username: "flo", Hash: "hash", ExpiresAt: time.Now().Add(time.Hour),
+		})).To(Succeed())
+		Expect(repository.RegisterUser(&users.User{
+			Username: "bob",
+			Email:    "bob@example.org",
+			Password: "bobs-passw0rd",
+		})).To(Succeed())
+
+		Expect(repository.Rename("flo", "bob")).To(Equal(users.ErrUserExists))
+		Expect(repository.Rename("nobody", "alice")).To(Equal(users.ErrUserNotFound))
+		Expect(repository.Rename("flo", "florent")).To(Succeed())
+
+		Expect(repository.FindByUsername("flo")).To(BeNil())
+		user, err := repository.FindByEmailAndPassword("flo@example.org", "sup3rpassw0rd")
+		Expect(err).To(BeNil())
+		Expect(user.Username).To(Equal("florent"))
+		token, err := repository.(users.RefreshTokenRepository).FindRefreshToken("session")
+		Expect(err).To(BeNil())
+		Expect(token.Username).To(Equal("florent"))
+	})
+
+	It("changes email addresses, which have to be verified again", func() {
+		Expect(repository.SetVerified("flo")).To(Succeed())
+		Expect(repository.RegisterUser(&users.User{
+			Username: "bob",
+			Email:    "bob@example.org",
+			Password: "bobs-passw0rd",
+		})).To(Succeed())
+
+		Expect(repository.UpdateEmail("flo", "bob@example.org")).To(Equal(users.ErrUserExists))
+		Expect(repository.UpdateEmail("nobody", "nobody@example.org")).To(Equal(users.ErrUserNotFound))
+		Expect(repository.UpdateEmail("flo", "florent@example.org")).To(Succeed())
+
+		user, err := repository.FindByEmail("florent@example.org")
+		Expect(err).To(BeNil())
+		Expect(user.Username).To(Equal("flo"))
+		Expect(user.Verified).To(BeFalse())
+	})
+
+	It("stores contact details", func() {
+		details := users.ContactDetails{
+			DisplayName: "Florent",
+			Callsign:    "DL1ABC",
+			Contact:     "https://example.org/flo",
+		}
+
+		Expect(repository.UpdateContactDetails("flo", details)).To(Succeed())
+		Expect(repository.UpdateContactDetails("nobody", details)).To(Equal(users.ErrUserNotFound))
+
+		user, err := repository.FindByUsername("flo")
+		Expect(err).To(BeNil())
+		Expect(user.ContactDetails).To(Equal(details))
+		all, err := repository.FindAll()
+		Expect(err).To(BeNil())
+		Expect(all[0].ContactDetails).To(Equal(details))
+	})
+
+	It("changes profiles at once", func() {
+		Expect(repository.SetVerified("flo")).To(Succeed())
+		Expect(repository.RegisterUser(&users.User{
+			Username: "bob",
+			Email:    "bob@example.org",
+			Password: "bobs-passw0rd",
+		})).To(Succeed())
+		details := users.ContactDetails{Callsign: "DL1ABC"}
+
+		Expect(repository.UpdateProfile("flo", users.ProfileChange{
+			Username: "bob", Email: "florent@example.org", ContactDetails: &details,
+		})).To(Equal(users.ErrUserExists))
+		Expect(repository.UpdateProfile("flo", users.ProfileChange{
+			Username: "florent", Email: "bob@example.org", ContactDetails: &details,
+		})).To(Equal(users.ErrUserExists))
+		Expect(repository.UpdateProfile("nobody", users.ProfileChange{Username: "alice"})).
+			To(Equal(users.ErrUserNotFound))
+
+		user, err := repository.FindByUsername("flo")
+		Expect(err).To(BeNil())
+		Expect(user.Email).To(Equal("flo@example.org"))
+		Expect(user.Verified).To(BeTrue())
+		Expect(user.ContactDetails).To(BeZero())
+
+		Expect(repository.UpdateProfile("flo", users.ProfileChange{
+			Username: "florent", Email: "florent@example.org", ContactDetails: &details,
+		})).To(Succeed())
+
+		user, err = repository.FindByUsername("florent")
+		Expect(err).To(BeNil())
+		Expect(user.Email).To(Equal("florent@example.org"))
+		Expect(user.Verified).To(BeFalse())
+		Expect(user.ContactDetails).To(Equal(details))
+	})
+
+	It("deletes users with their tokens", func() {
+		Expect(repository.(users.RefreshTokenRepository).SaveRefreshToken(&users.RefreshToken{
+			ID: "session", Username: "flo", Hash: "hash", ExpiresAt: time.Now().Add(time.Hour),
+		})).To(Succeed())
+
+		Expect(repository.DeleteUser("flo")).To(Succeed())
+		Expect(repository.DeleteUser("flo")).To(Equal(users.ErrUserNotFound))
+
+		Expect(repository.FindByUsername("flo")).To(BeNil())
+		_, err := repository.(users.RefreshTokenRepository).FindRefreshToken("session")
+		Expect(err).To(Equal(users.ErrInvalidToken))
+		Expect(repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed(), "name and address should be free again")
+	})
+
+	Describe("refresh tokens", func() {
+
+		var tokens users.RefreshTokenRepository
+		var expiresAt time.Time
+
+		BeforeEach(func() {
+			tokens = repository.(users.RefreshTokenRepository)
+			expiresAt = time.Now().Add(time.Hour).Truncate(time.Millisecond)
+			Expect(tokens.SaveRefreshToken(&users.RefreshToken{
+				ID:        "session",
+				Username:  "flo",
+				Hash:      "first",
+				ExpiresAt: expiresAt,
+			})).To(Succeed())
+		})
+
+		It("finds saved tokens", func() {
+			token, err := tokens.FindRefreshToken("session")
+
+			Expect(err).To(BeNil())
+			Expect(token.Username).To(Equal("flo"))
+			Expect(token.Hash).To(Equal("first"))
+			Expect(token.ExpiresAt).To(BeTemporally("==", expiresAt))
+
+			_, err = tokens.FindRefreshToken("unknown")
+			Expect(err).To(Equal(users.ErrInvalidToken))
+		})
+
+		It("only saves tokens of known users", func() {
+			err := tokens.SaveRefreshToken(&users.RefreshToken{
+				ID:        "other",
+				Username:  "nobody",
+				Hash:      "hash",
+				ExpiresAt: expiresAt,
+			})
+
+			Expect(err).To(Equal(users.ErrUserNotFound))
+		})
+
+		It("drops expired tokens of the user", func() {
+			Expect(tokens.SaveRefreshToken(&users.RefreshToken{
+				ID:        "expired",
+				Username:  "flo",
+				Hash:      "hash",
+				ExpiresAt: time.Now().Add(-time.Minute),
+			})).To(Succeed())
+			Expect(tokens.SaveRefreshToken(&users.RefreshToken{
+				ID:        "new",
+				Username:  "flo",
+				Hash:      "hash",
+				ExpiresAt: expiresAt,
+			})).To(Succeed())
+
+			_, err := tokens.FindRefreshToken("expired")
+
+			Expect(err).To(Equal(users.ErrInvalidToken))
+			_, err = tokens.FindRefreshToken("session")
+			Expect(err).To(BeNil())
+		})
+
+		It("rotates tokens once", func() {
+			later := expiresAt.Add(time.Hour)
+			rotated := &users.RefreshToken{ID: "session", Username: "flo", Hash: "second", ExpiresAt: later}
+
+			Expect(tokens.RotateRefreshToken(rotated, "first")).To(Succeed())
+			Expect(tokens.RotateRefreshToken(rotated, "first")).To(Equal(users.ErrInvalidToken))
+
+			token, err := tokens.FindRefreshToken("session")
+			Expect(err).To(BeNil())
+			Expect(token.Hash).To(Equal("second"))
+			Expect(token.ExpiresAt).To(BeTemporally("==", later))
+		})
+
+		It("deletes one or all tokens of a user", func() {
+			Expect(tokens.SaveRefreshToken(&users.RefreshToken{
+				ID:        "other",
+				Username:  "flo",
+				Hash:      "hash",
+				ExpiresAt: expiresAt,
+			})).To(Succeed())
+
+			Expect(tokens.DeleteRefreshToken("session")).To(Succeed())
+			Expect(tokens.DeleteRefreshToken("session")).To(Succeed())
+			_, err := tokens.FindRefreshToken("session")
+			Expect(err).To(Equal(users.ErrInvalidToken))
+			_, err = tokens.FindRefreshToken("other")
+			Expect(err).To(BeNil())
+
+			Expect(tokens.DeleteRefreshTokens("flo")).To(Succeed())
+			_, err = tokens.FindRefreshToken("other")
+			Expect(err).To(Equal(users.ErrInvalidToken))
+		})
+	})
+
+	Describe("API tokens", func() {
+
+		var tokens users.APITokenRepository
+		var createdAt time.Time
+		var expiresAt time.Time
+
+		BeforeEach(func() {
+			tokens = repository.(users.APITokenRepository)
+			createdAt = time.Now().Truncate(time.Millisecond)
+			expiresAt = createdAt.Add(time.Hour)
+			Expect(tokens.SaveAPIToken(&users.APIToken{
+				ID:        "sync",
+				Username:  "flo",
+				Name:      "sync",
+				Hash:      "hash",
+				Scopes:    []users.Scope{users.ScopeNodesRead, users.ScopeNodesWrite},
+				CreatedAt: createdAt,
+				ExpiresAt: &expiresAt,
+			})).To(Succeed())
+			Expect(tokens.SaveAPIToken(&users.APIToken{
+				ID:        "ci",
+				Username:  "flo",
+				Name:      "ci",
+				Hash:      "other-hash",
+				Scopes:    []users.Scope{users.ScopeNodesRead},
+				CreatedAt: createdAt,
+			})).To(Succeed())
+		})
+
+		It("finds tokens by ID and user", func() {
+			token, err := tokens.FindAPIToken("sync")
+
+			Expect(err).To(BeNil())
+			Expect(token.Username).To(Equal("flo"))
+			Expect(token.Hash).To(Equal("hash"))
+			Expect(token.Scopes).To(Equal([]users.Scope{users.ScopeNodesRead, users.ScopeNodesWrite}))
+			Expect(token.CreatedAt).To(BeTemporally("==", createdAt))
+			Expect(*token.ExpiresAt).To(BeTemporally("==", expiresAt))
+
+			_, err = tokens.FindAPIToken("unknown")
+			Expect(err).To(Equal(users.ErrInvalidToken))
+
+			all, err := tokens.FindAPITokens("flo")
+			Expect(err).To(BeNil())
+			Expect(all).To(HaveLen(2))
+			Expect(all[0].Name).To(Equal("ci"))
+			Expect(all[0].ExpiresAt).To(BeNil())
+			Expect(all[1].Name).To(Equal("sync"))
+		})
+
+		It("rejects duplicate names and unknown users", func() {
+			err := tokens.SaveAPIToken(&users.APIToken{ID: "other", Username: "flo", Name: "ci", CreatedAt: createdAt})
+			Expect(err).To(Equal(users.ErrTokenExists))
+
+			err = tokens.SaveAPIToken(&users.APIToken{ID: "other", Username: "nobody", Name: "ci", CreatedAt: createdAt})
+			Expect(err).To(Equal(users.ErrUserNotFound))
+		})
+
+		It("deletes tokens of their user only", func() {
+			Expect(tokens.DeleteAPIToken("nobody", "ci")).To(Equal(users.ErrTokenNotFound))
+			Expect(tokens.DeleteAPIToken("flo", "ci")).To(Succeed())
+			Expect(tokens.DeleteAPIToken("flo", "ci")).To(Equal(users.ErrTokenNotFound))
+
+			all, err := tokens.FindAPITokens("flo")
+			Expect(err).To(BeNil())
+			Expect(all).To(HaveLen(1))
+		})
+	})
+}
+
+// loginAttemptContract holds the specs every LoginAttemptRepository has
+// to pass.
+func loginAttemptContract(newRepository func() users.LoginAttemptRepository) {
+
+	var repository users.LoginAttemptRepository
+	var now time.Time
+
+	BeforeEach(func() {
+		repository = newRepository()
+		now = time.Now().UTC().Truncate(time.Millisecond)
+	})
+
+	It("counts failures per key", func() {
+		_, err := repository.AddLoginFailure("account:flo@example.org", now.Add(-time.Minute), now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+		failures, err := repository.AddLoginFailure("account:flo@example.org", now, now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(failures.Count).To(Equal(2))
+
+		found, err := repository.FindLoginFailures("account:flo@example.org")
+		Expect(err).To(BeNil())
+		Expect(found.Count).To(Equal(2))
+		Expect(found.LastFailure.Equal(now)).To(BeTrue())
+		Expect(repository.FindLoginFailures("address:192.0.2.1")).To(BeNil())
+	})
+
+	It("forgets old failures", func() {
+		_, err := repository.AddLoginFailure("address:192.0.2.1", now.Add(-2*time.Hour), now.Add(-3*time.Hour))
+		Expect(err).To(BeNil())
+
+		failures, err := repository.AddLoginFailure("account:flo@example.org", now, now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+		Expect(failures.Count).To(Equal(1))
+		Expect(repository.FindLoginFailures("address:192.0.2.1")).To(BeNil())
+	})
+
+	It("takes back failures", func() {
+		_, err := repository.AddLoginFailure("account:flo@example.org", now.Add(-time.Minute), now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+		_, err = repository.AddLoginFailure("account:flo@example.org", now, now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+
+		Expect(repository.ReleaseLoginFailure("account:flo@example.org")).To(Succeed())
+		found, err := repository.FindLoginFailures("account:flo@example.org")
+		Expect(err).To(BeNil())
+		Expect(found.Count).To(Equal(1))
+		Expect(found.LastFailure.Equal(now)).To(BeTrue())
+
+		Expect(repository.ReleaseLoginFailure("account:flo@example.org")).To(Succeed())
+		Expect(repository.ReleaseLoginFailure("account:flo@example.org")).To(Succeed())
+		Expect(repository.FindLoginFailures("account:flo@example.org")).To(BeNil())
+	})
+
+	It("deletes failures", func() {
+		_, err := repository.AddLoginFailure("account:flo@example.org", now, now.Add(-time.Hour))
+		Expect(err).To(BeNil())
+
+		Expect(repository.DeleteLoginFailures("account:flo@example.org")).To(Succeed())
+		Expect(repository.DeleteLoginFailures("account:flo@example.org")).To(Succeed())
+
+		Expect(repository.FindLoginFailures("account:flo@example.org")).To(BeNil())
+	})
+
+	It("keeps failed logins for audits", func() {
+		for i, reason := range []string{users.ReasonInvalidCredentials, users.ReasonThrottled, users.ReasonAccountLocked} {
+			Expect(repository.SaveFailedLogin(&users.FailedLogin{
+				Email:   "flo@example.org",
+				Address: "192.0.2.1",
+				Reason:  reason,
+				At:      now.Add(time.Duration(i-3) * time.Minute),
+			}, now.Add(-time.Hour))).To(Succeed())
+		}
+
+		records, err := repository.FindFailedLogins(2)
+
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(2))
+		Expect(records[0].Reason).To(Equal(users.ReasonAccountLocked))
+		Expect(records[0].At.Equal(now.Add(-time.Minute))).To(BeTrue())
+		Expect(records[1].Reason).To(Equal(users.ReasonThrottled))
+
+		Expect(repository.SaveFailedLogin(&users.FailedLogin{
+			Email: "flo@example.org", Address: "192.0.2.1", Reason: users.ReasonInvalidCredentials, At: now,
+		}, now.Add(-90*time.Second))).To(Succeed())
+		Expect(repository.FindFailedLogins(10)).To(HaveLen(2))
+	})
+}
+
+var _ = Describe("In-memory users", func() {
+
+	userRepositoryContract(func() users.UserRepository {
+		return &users.UserMemoryRepository{}
+	})
+})
+
+var _ = Describe("In-memory login attempts", func() {
+
+	loginAttemptContract(func() users.LoginAttemptRepository {
+		return &users.LoginAttemptMemoryRepository{}
+	})
+})
+
+var _ = Describe("File-backed users", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "filestore")
+		Expect(err).To(BeNil(), "Directory should be created")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	userRepositoryContract(func() users.UserRepository {
+		store, err := filestore.Open(filepath.Join(dir, "hnetdb.json"))
+		Expect(err).To(BeNil(), "Store should open")
+		return store.Users()
+	})
+})
+
+var _ = Describe("Neo4j user repository contract", func() {
+
+	const username = "neo4j"
+	const password = "s3cr3t"
+
+	var ctx context.Context
+	var neo4jContainer testcontainers.Container
+	var driver neo4j.Driver
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		neo4jContainer, err = startContainer(ctx, username, password)
+		Expect(err).To(BeNil(), "Container should start")
+		port, err := neo4jContainer.MappedPort(ctx, "7687")
+		Expect(err).To(BeNil(), "Port should be resolved")
+		address := fmt.Sprintf("bolt://localhost:%d", port.Int())
+		driver, err = neo4j.NewDriver(address, neo4j.BasicAuth(username, password, ""))
+		Expect(err).To(BeNil(), "Driver should be created")
+		runner := &migrations.Runner{Driver: driver, Migrations: migrations.Builtin}
+		_, err = runner.Up()
+		Expect(err).To(BeNil(), "Migrations should be applied")
+	})
+
+	AfterEach(func() {
+		Close(driver, "Driver")
+		Expect(neo4jContainer.Terminate(ctx)).To(BeNil(), "Container should stop")
+	})
+
+	userRepositoryContract(func() users.UserRepository {
+		return &users.UserNeo4jRepository{Driver: driver}
+	})
+
+	Describe("login attempts", func() {
+
+		loginAttemptContract(func() users.LoginAttemptRepository {
+			return &users.LoginAttemptNeo4jRepository{Driver: driver}
+		})
+	})
+})
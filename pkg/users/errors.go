@@ -0,0 +1,11 @@
+package users
+
+import "errors"
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUserExists    = errors.New("user already exists")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrTokenExists   = errors.New("token already exists")
+	ErrTokenNotFound = errors.New("token not found")
+)
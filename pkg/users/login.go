@@ -2,10 +2,10 @@ package users
 
 import (
 	"encoding/json"
-	"github.com/dgrijalva/jwt-go"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 )
 
@@ -14,14 +14,23 @@ type UserLogin struct {
 }
 
 type LoggedInUser struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Token    string `json:"token"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Role         Role   `json:"role"`
+	Verified     bool   `json:"verified"`
 }
 
+// UserLoginHandler hands out access tokens. If Sessions is set, every
+// login also starts a session and returns its refresh token. If
+// Throttle is set, failed logins slow down further attempts.
 type UserLoginHandler struct {
 	Path           string
 	UserRepository UserRepository
+	Tokens         *Tokens
+	Sessions       *Sessions
+	Throttle       *LoginThrottle
 }
 
 func (u *UserLoginHandler) Login(writer http.ResponseWriter, request *http.Request) {
@@ -29,33 +38,80 @@ func (u *UserLoginHandler) Login(writer http.ResponseWriter, request *http.Reque
 	userLoginRequest := UserLogin{}
 	_ = json.Unmarshal(requestBody, &userLoginRequest)
 	requestUser := userLoginRequest.User
-	user, _ := u.UserRepository.FindByEmailAndPassword(
-		requestUser.Email,
-		requestUser.Password)
+	email := NormalizeEmail(requestUser.Email)
 
+	// The attempt is counted as failed up front and only taken back for
+	// the right password, see LoginThrottle.Reserve.
+	var address string
+	if u.Throttle != nil {
+		address = u.Throttle.ClientAddress(request)
+		wait, err := u.Throttle.Reserve(email, address)
+		if err != nil {
+			apierror.Internal(writer)
+			return
+		}
+		if wait > 0 {
+			_ = u.Throttle.Audit(email, address, ReasonThrottled)
+			tooManyAttempts(writer, wait, "too many failed logins, try again later")
+			return
+		}
+	}
+
+	user, _ := u.UserRepository.FindByEmailAndPassword(email, requestUser.Password)
 	if user == nil {
-		writer.WriteHeader(http.StatusUnauthorized)
+		if u.Throttle != nil {
+			_ = u.Throttle.Audit(email, address, ReasonInvalidCredentials)
+		}
+		apierror.Write(writer, http.StatusUnauthorized, "invalid_credentials", "email or password is wrong")
 		return
 	}
+	if user.Locked {
+		if u.Throttle != nil {
+			_ = u.Throttle.Release(email, address)
+			_ = u.Throttle.Audit(email, address, ReasonAccountLocked)
+		}
+		apierror.Write(writer, http.StatusForbidden, "account_locked", "account is locked")
+		return
+	}
+	if u.Throttle != nil {
+		_ = u.Throttle.Succeed(email, address)
+	}
+	if !user.Role.Valid() {
+		user.Role = RoleMember
+	}
 
-	token, _ := CreateToken(user)
+	var session, refreshToken string
+	if u.Sessions != nil {
+		var err error
+		session, refreshToken, err = u.Sessions.Start(user.Username)
+		if err != nil {
+			apierror.Internal(writer)
+			return
+		}
+	}
+	token, _ := u.Tokens.Create(user, session)
 
+	writeLoggedInUser(writer, user, token, refreshToken)
+}
+
+func writeLoggedInUser(writer http.ResponseWriter, user *User, token string, refreshToken string) {
+	writer.Header().Add("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusOK)
-	responseBody := UserLogin{
-		User: User{
-			Username: user.Username,
-			Email:    user.Email,
-			Token:    token,
-		}}
+	responseBody := LoggedInUser{
+		Username:     user.Username,
+		Email:        user.Email,
+		Token:        token,
+		RefreshToken: refreshToken,
+		Role:         user.Role,
+		Verified:     user.Verified,
+	}
 	bytes, _ := json.Marshal(&responseBody)
 	_, _ = writer.Write(bytes)
 }
 
-func CreateToken(user *User) (string, error) {
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["user_id"] = user.Username
-	claims["exp"] = time.Now().Add(time.Minute * 15).Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	return token.SignedString([]byte(os.Getenv("SECRET_ACCESS")))
+// tooManyAttempts tells the client to wait, in whole seconds rounded up.
+func tooManyAttempts(writer http.ResponseWriter, wait time.Duration, message string) {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	writer.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	apierror.Write(writer, http.StatusTooManyRequests, "too_many_attempts", message)
 }
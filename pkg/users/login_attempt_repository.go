@@ -0,0 +1,267 @@
+package users
+
+import (
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"sync"
+	"time"
+)
+
+// maximumMemoryFailedLogins bounds the audit records kept in memory.
+const maximumMemoryFailedLogins = 10000
+
+// LoginAttemptMemoryRepository keeps failed logins in memory, so they
+// are forgotten on restart.
+type LoginAttemptMemoryRepository struct {
+	mutex    sync.Mutex
+	failures map[string]*LoginFailures
+	audit    []*FailedLogin
+}
+
+func (l *LoginAttemptMemoryRepository) AddLoginFailure(key string, at time.Time, since time.Time) (*LoginFailures, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.failures == nil {
+		l.failures = map[string]*LoginFailures{}
+	}
+	for existing, failures := range l.failures {
+		if failures.LastFailure.Before(since) {
+			delete(l.failures, existing)
+		}
+	}
+	failures, ok := l.failures[key]
+	if !ok {
+		failures = &LoginFailures{Key: key}
+		l.failures[key] = failures
+	}
+	failures.Count++
+	failures.LastFailure = at
+	copied := *failures
+	return &copied, nil
+}
+
+func (l *LoginAttemptMemoryRepository) FindLoginFailures(key string) (*LoginFailures, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	failures, ok := l.failures[key]
+	if !ok {
+		return nil, nil
+	}
+	copied := *failures
+	return &copied, nil
+}
+
+func (l *LoginAttemptMemoryRepository) ReleaseLoginFailure(key string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	failures, ok := l.failures[key]
+	if !ok {
+		return nil
+	}
+	failures.Count--
+	if failures.Count <= 0 {
+		delete(l.failures, key)
+	}
+	return nil
+}
+
+func (l *LoginAttemptMemoryRepository) DeleteLoginFailures(key string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.failures, key)
+	return nil
+}
+
+func (l *LoginAttemptMemoryRepository) SaveFailedLogin(record *FailedLogin, since time.Time) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	kept := l.audit[:0]
+	for _, existing := range l.audit {
+		if !existing.At.Before(since) {
+			kept = append(kept, existing)
+		}
+	}
+	if len(kept) >= maximumMemoryFailedLogins {
+		kept = kept[len(kept)-maximumMemoryFailedLogins+1:]
+	}
+	copied := *record
+	l.audit = append(kept, &copied)
+	return nil
+}
+
+func (l *LoginAttemptMemoryRepository) FindFailedLogins(limit int) ([]*FailedLogin, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var records []*FailedLogin
+	for i := len(l.audit) - 1; i >= 0 && len(records) < limit; i-- {
+		copied := *l.audit[i]
+		records = append(records, &copied)
+	}
+	return records, nil
+}
+
+// LoginAttemptNeo4jRepository keeps failed logins in (:LoginFailures)
+// and (:FailedLogin) nodes.
+type LoginAttemptNeo4jRepository struct {
+	Driver neo4j.Driver
+}
+
+func (l *LoginAttemptNeo4jRepository) AddLoginFailure(key string, at time.Time, since time.Time) (failures *LoginFailures, err error) {
+	err = l.write(func(tx neo4j.Transaction) error {
+		if _, err := tx.Run("MATCH (f:LoginFailures) WHERE f.last_failure < $since DELETE f",
+			map[string]interface{}{
+				"since": since.UTC(),
+			}); err != nil {
+			return err
+		}
+		res, err := tx.Run("MERGE (f:LoginFailures {key: $key}) "+
+			"SET f.count = coalesce(f.count, 0) + 1, f.last_failure = $at "+
+			"RETURN f.count AS count",
+			map[string]interface{}{
+				"key": key,
+				"at":  at.UTC(),
+			})
+		if err != nil {
+			return err
+		}
+		record, err := res.Single()
+		if err != nil {
+			return err
+		}
+		failures = &LoginFailures{
+			Key:         key,
+			Count:       int(record.Values[0].(int64)),
+			LastFailure: at,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+func (l *LoginAttemptNeo4jRepository) FindLoginFailures(key string) (failures *LoginFailures, err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("MATCH (f:LoginFailures {key: $key}) RETURN f.count AS count, f.last_failure AS last_failure",
+			map[string]interface{}{
+				"key": key,
+			})
+		if err != nil {
+			return nil, err
+		}
+		if !res.Next() {
+			return nil, res.Err()
+		}
+		record := res.Record()
+		return &LoginFailures{
+			Key:         key,
+			Count:       int(record.Values[0].(int64)),
+			LastFailure: record.Values[1].(time.Time),
+		}, nil
+	})
+	if result == nil {
+		return nil, err
+	}
+	return result.(*LoginFailures), err
+}
+
+func (l *LoginAttemptNeo4jRepository) ReleaseLoginFailure(key string) error {
+	return l.write(func(tx neo4j.Transaction) error {
+		_, err := tx.Run("MATCH (f:LoginFailures {key: $key}) SET f.count = f.count - 1 "+
+			"WITH f WHERE f.count <= 0 DELETE f",
+			map[string]interface{}{
+				"key": key,
+			})
+		return err
+	})
+}
+
+func (l *LoginAttemptNeo4jRepository) DeleteLoginFailures(key string) error {
+	return l.write(func(tx neo4j.Transaction) error {
+		_, err := tx.Run("MATCH (f:LoginFailures {key: $key}) DELETE f",
+			map[string]interface{}{
+				"key": key,
+			})
+		return err
+	})
+}
+
+func (l *LoginAttemptNeo4jRepository) SaveFailedLogin(record *FailedLogin, since time.Time) error {
+	return l.write(func(tx neo4j.Transaction) error {
+		if _, err := tx.Run("MATCH (f:FailedLogin) WHERE f.at < $since DELETE f",
+			map[string]interface{}{
+				"since": since.UTC(),
+			}); err != nil {
+			return err
+		}
+		_, err := tx.Run("CREATE (:FailedLogin {email: $email, address: $address, reason: $reason, at: $at})",
+			map[string]interface{}{
+				"email":   record.Email,
+				"address": record.Address,
+				"reason":  record.Reason,
+				"at":      record.At.UTC(),
+			})
+		return err
+	})
+}
+
+func (l *LoginAttemptNeo4jRepository) FindFailedLogins(limit int) (records []*FailedLogin, err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("MATCH (f:FailedLogin) RETURN f.email AS email, f.address AS address, "+
+			"f.reason AS reason, f.at AS at ORDER BY f.at DESC LIMIT $limit",
+			map[string]interface{}{
+				"limit": limit,
+			})
+		if err != nil {
+			return nil, err
+		}
+		var records []*FailedLogin
+		for res.Next() {
+			record := res.Record()
+			records = append(records, &FailedLogin{
+				Email:   record.Values[0].(string),
+				Address: record.Values[1].(string),
+				Reason:  record.Values[2].(string),
+				At:      record.Values[3].(time.Time),
+			})
+		}
+		return records, res.Err()
+	})
+	if result == nil {
+		return nil, err
+	}
+	return result.([]*FailedLogin), err
+}
+
+func (l *LoginAttemptNeo4jRepository) write(work func(tx neo4j.Transaction) error) (err error) {
+	session := l.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		return nil, work(tx)
+	})
+	return err
+}
@@ -8,9 +8,12 @@ import (
 	. "github.com/onsi/gomega"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"time"
 )
 
 var _ = Describe("Users", func() {
+	tokens := &users.Tokens{Secret: []byte("test-secret"), TTL: 15 * time.Minute}
 	userLoginRequest := users.UserLogin{
 		User: users.User{
 			Email:    "florent@example.org",
@@ -21,7 +24,8 @@ var _ = Describe("Users", func() {
 	It("should log in", func() {
 		email := "florent@example.org"
 		handler := users.UserLoginHandler{
-			Path: "/users/login",
+			Path:   "/users/login",
+			Tokens: tokens,
 			UserRepository: &FakeUserRepository{
 				LoginResult: &users.User{
 					Username: "flo",
@@ -43,11 +47,64 @@ var _ = Describe("Users", func() {
 		Expect(login.Email).To(Equal(email))
 		Expect(login.Username).To(Equal("flo"))
 		Expect(login.Token).NotTo(Equal(""), "token should be set")
+		Expect(login.Role).To(Equal(users.RoleMember))
+	})
+
+	It("embeds the role in the token", func() {
+		handler := users.UserLoginHandler{
+			Path:   "/users/login",
+			Tokens: tokens,
+			UserRepository: &FakeUserRepository{
+				LoginResult: &users.User{
+					Username: "flo",
+					Email:    "florent@example.org",
+					Role:     users.RoleModerator,
+				},
+			},
+		}
+		testResponseWriter := httptest.NewRecorder()
+
+		handler.Login(
+			testResponseWriter,
+			httptest.NewRequest(
+				"POST",
+				"/users/login",
+				strings.NewReader(marshalLogin(&userLoginRequest))))
+
+		login := unmarshalLogin(testResponseWriter.Body)
+		user, _, err := tokens.Validate(login.Token)
+		Expect(err).To(BeNil(), "token should be valid")
+		Expect(user.Role).To(Equal(users.RoleModerator))
+	})
+
+	It("refuses to log in locked users", func() {
+		handler := users.UserLoginHandler{
+			Path:   "/users/login",
+			Tokens: tokens,
+			UserRepository: &FakeUserRepository{
+				LoginResult: &users.User{
+					Username: "flo",
+					Email:    "florent@example.org",
+					Locked:   true,
+				},
+			},
+		}
+		testResponseWriter := httptest.NewRecorder()
+
+		handler.Login(
+			testResponseWriter,
+			httptest.NewRequest(
+				"POST",
+				"/users/login",
+				strings.NewReader(marshalLogin(&userLoginRequest))))
+
+		Expect(testResponseWriter.Code).To(Equal(403))
 	})
 
 	It("should returns unauthorized response if login fails", func() {
 		handler := users.UserLoginHandler{
-			Path: "/users/login",
+			Path:   "/users/login",
+			Tokens: tokens,
 			UserRepository: &FakeUserRepository{
 				LoginResult: nil,
 			},
@@ -77,3 +134,88 @@ func marshalLogin(login *users.UserLogin) string {
 	Expect(err).To(BeNil(), "JSON marshalling should work")
 	return string(payload)
 }
+
+var _ = Describe("Throttled login", func() {
+
+	var handler *users.UserLoginHandler
+	var attempts *users.LoginAttemptMemoryRepository
+
+	BeforeEach(func() {
+		repository := &users.UserMemoryRepository{}
+		Expect(repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed())
+		attempts = &users.LoginAttemptMemoryRepository{}
+		handler = &users.UserLoginHandler{
+			Path:           "/users/login",
+			UserRepository: repository,
+			Tokens:         &users.Tokens{Secret: []byte("test-secret"), TTL: 15 * time.Minute},
+			Throttle: &users.LoginThrottle{
+				Repository:  attempts,
+				Account:     users.ThrottlePolicy{FreeAttempts: 1, BaseDelay: 90 * time.Second, MaxDelay: time.Hour},
+				Address:     users.ThrottlePolicy{FreeAttempts: 10, BaseDelay: time.Second, MaxDelay: time.Hour},
+				ForgetAfter: time.Hour,
+			},
+		}
+	})
+
+	login := func(password string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.Login(recorder, httptest.NewRequest("POST", "/users/login", strings.NewReader(
+			`{"user": {"email": "flo@example.org", "password": "`+password+`"}}`)))
+		return recorder
+	}
+
+	It("asks to wait after failed logins", func() {
+		Expect(login("wrong").Code).To(Equal(401))
+		Expect(login("wrong").Code).To(Equal(401))
+
+		recorder := login("sup3rpassw0rd")
+
+		Expect(recorder.Code).To(Equal(429))
+		Expect(recorder.Header().Get("Retry-After")).To(Equal("90"))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"too_many_attempts"`))
+		records, err := attempts.FindFailedLogins(10)
+		Expect(err).To(BeNil())
+		Expect(records).To(HaveLen(3))
+		Expect(records[0].Reason).To(Equal(users.ReasonThrottled))
+		Expect(records[0].Address).To(Equal("192.0.2.1"))
+	})
+
+	It("forgets failures of the account after a login", func() {
+		Expect(login("wrong").Code).To(Equal(401))
+		Expect(login("sup3rpassw0rd").Code).To(Equal(200))
+
+		Expect(attempts.FindLoginFailures("account:flo@example.org")).To(BeNil())
+		failures, err := attempts.FindLoginFailures("address:192.0.2.1")
+		Expect(err).To(BeNil())
+		Expect(failures.Count).To(Equal(1))
+	})
+
+	It("throttles simultaneous failed logins", func() {
+		codes := make(chan int, 20)
+
+		var logins sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			logins.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer logins.Done()
+				codes <- login("wrong").Code
+			}()
+		}
+		logins.Wait()
+		close(codes)
+
+		counted := map[int]int{}
+		for code := range codes {
+			counted[code]++
+		}
+		Expect(counted).To(Equal(map[int]int{401: 2, 429: 18}))
+		failures, err := attempts.FindLoginFailures("account:flo@example.org")
+		Expect(err).To(BeNil())
+		Expect(failures.Count).To(Equal(2))
+	})
+})
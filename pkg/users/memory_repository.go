@@ -0,0 +1,565 @@
+package users
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// UserMemoryRepository keeps users in memory. It is meant for local
+// development and tests; everything is lost on exit. Passwords are
+// hashed just like in Neo4j.
+type UserMemoryRepository struct {
+	mutex     sync.RWMutex
+	users     []*User
+	tokens    []*RefreshToken
+	apiTokens []*APIToken
+	mailed    []*OneTimeToken
+}
+
+func (u *UserMemoryRepository) RegisterUser(user *User) error {
+	hashedPassword, err := hash(user.Password)
+	if err != nil {
+		return err
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for _, existing := range u.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return ErrUserExists
+		}
+	}
+	u.users = append(u.users, &User{
+		Username: user.Username,
+		Email:    user.Email,
+		Password: hashedPassword,
+		Role:     RoleMember,
+	})
+	return nil
+}
+
+func (u *UserMemoryRepository) FindByEmailAndPassword(email string, password string) (*User, error) {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	for _, user := range u.users {
+		if user.Email != email {
+			continue
+		}
+		if !passwordsMatch(user.Password, password) {
+			return nil, nil
+		}
+		return withoutPassword(user), nil
+	}
+	return nil, nil
+}
+
+func (u *UserMemoryRepository) FindByUsername(username string) (*User, error) {
+	return u.findUser(func(user *User) bool {
+		return user.Username == username
+	}), nil
+}
+
+func (u *UserMemoryRepository) FindByEmail(email string) (*User, error) {
+	return u.findUser(func(user *User) bool {
+		return user.Email == email
+	}), nil
+}
+
+func (u *UserMemoryRepository) findUser(matches func(user *User) bool) *User {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	for _, user := range u.users {
+		if matches(user) {
+			return withoutPassword(user)
+		}
+	}
+	return nil
+}
+
+func (u *UserMemoryRepository) FindAll() ([]*User, error) {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	var users []*User
+	for _, user := range u.users {
+		users = append(users, withoutPassword(user))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users, nil
+}
+
+func (u *UserMemoryRepository) UpdateRole(username string, role Role) error {
+	return u.updateUser(username, func(user *User) {
+		user.Role = role
+	})
+}
+
+func (u *UserMemoryRepository) SetLocked(username string, locked bool) error {
+	return u.updateUser(username, func(user *User) {
+		user.Locked = locked
+	})
+}
+
+func (u *UserMemoryRepository) SetVerified(username string) error {
+	return u.updateUser(username, func(user *User) {
+		user.Verified = true
+	})
+}
+
+func (u *UserMemoryRepository) UpdatePassword(username string, password string) error {
+	hashedPassword, err := hash(password)
+	if err != nil {
+		return err
+	}
+	return u.updateUser(username, func(user *User) {
+		user.Password = hashedPassword
+	})
+}
+
+func (u *UserMemoryRepository) Rename(username string, newUsername string) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.exists(newUsername) {
+		return ErrUserExists
+	}
+	if !u.exists(username) {
+		return ErrUserNotFound
+	}
+	u.rename(username, newUsername)
+	return nil
+}
+
+// rename renames the user together with its tokens.
+func (u *UserMemoryRepository) rename(username string, newUsername string) {
+	for _, user := range u.users {
+		if user.Username == username {
+			user.Username = newUsername
+		}
+	}
+	for _, token := range u.tokens {
+		if token.Username == username {
+			token.Username = newUsername
+		}
+	}
+	for _, token := range u.apiTokens {
+		if token.Username == username {
+			token.Username = newUsername
+		}
+	}
+	for _, token := range u.mailed {
+		if token.Username == username {
+			token.Username = newUsername
+		}
+	}
+}
+
+func (u *UserMemoryRepository) UpdateEmail(username string, email string) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	var found *User
+	for _, user := range u.users {
+		if user.Email == email && user.Username != username {
+			return ErrUserExists
+		}
+		if user.Username == username {
+			found = user
+		}
+	}
+	if found == nil {
+		return ErrUserNotFound
+	}
+	found.Email = email
+	found.Verified = false
+	return nil
+}
+
+func (u *UserMemoryRepository) UpdateContactDetails(username string, details ContactDetails) error {
+	return u.updateUser(username, func(user *User) {
+		user.ContactDetails = details
+	})
+}
+
+func (u *UserMemoryRepository) UpdateProfile(username string, change ProfileChange) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	var found *User
+	for _, user := range u.users {
+		if user.Username != username &&
+			(change.Username != "" && user.Username == change.Username ||
+				change.Email != "" && user.Email == change.Email) {
+			return ErrUserExists
+		}
+		if user.Username == username {
+			found = user
+		}
+	}
+	if found == nil {
+		return ErrUserNotFound
+	}
+	if change.ContactDetails != nil {
+		found.ContactDetails = *change.ContactDetails
+	}
+	if change.Email != "" {
+		found.Email = change.Email
+		found.Verified = false
+	}
+	if change.Username != "" {
+		u.rename(username, change.Username)
+	}
+	return nil
+}
+
+func (u *UserMemoryRepository) DeleteUser(username string) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if !u.exists(username) {
+		return ErrUserNotFound
+	}
+	var users []*User
+	for _, user := range u.users {
+		if user.Username != username {
+			users = append(users, user)
+		}
+	}
+	u.users = users
+	var tokens []*RefreshToken
+	for _, token := range u.tokens {
+		if token.Username != username {
+			tokens = append(tokens, token)
+		}
+	}
+	u.tokens = tokens
+	var apiTokens []*APIToken
+	for _, token := range u.apiTokens {
+		if token.Username != username {
+			apiTokens = append(apiTokens, token)
+		}
+	}
+	u.apiTokens = apiTokens
+	var mailed []*OneTimeToken
+	for _, token := range u.mailed {
+		if token.Username != username {
+			mailed = append(mailed, token)
+		}
+	}
+	u.mailed = mailed
+	return nil
+}
+
+// Exists reports whether a user called username is registered. It is
+// meant for nodes.MemoryGraph.UserExists.
+func (u *UserMemoryRepository) Exists(username string) bool {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	return u.exists(username)
+}
+
+func (u *UserMemoryRepository) exists(username string) bool {
+	for _, user := range u.users {
+		if user.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Export returns a copy of all users including their password hashes,
+// e.g. for keeping them on disk.
+func (u *UserMemoryRepository) Export() []*User {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	users := []*User{}
+	for _, user := range u.users {
+		exported := *user
+		users = append(users, &exported)
+	}
+	return users
+}
+
+// Import replaces all users with a copy of users, whose passwords have
+// to be hashed already.
+func (u *UserMemoryRepository) Import(users []*User) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.users = nil
+	for _, user := range users {
+		imported := *user
+		u.users = append(u.users, &imported)
+	}
+}
+
+func (u *UserMemoryRepository) SaveRefreshToken(token *RefreshToken) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if !u.exists(token.Username) {
+		return ErrUserNotFound
+	}
+	now := time.Now()
+	var kept []*RefreshToken
+	for _, existing := range u.tokens {
+		if existing.Username != token.Username || now.Before(existing.ExpiresAt) {
+			kept = append(kept, existing)
+		}
+	}
+	saved := *token
+	u.tokens = append(kept, &saved)
+	return nil
+}
+
+func (u *UserMemoryRepository) FindRefreshToken(id string) (*RefreshToken, error) {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	for _, token := range u.tokens {
+		if token.ID == id {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+func (u *UserMemoryRepository) RotateRefreshToken(token *RefreshToken, previousHash string) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for _, existing := range u.tokens {
+		if existing.ID == token.ID && existing.Hash == previousHash {
+			existing.Hash = token.Hash
+			existing.ExpiresAt = token.ExpiresAt
+			return nil
+		}
+	}
+	return ErrInvalidToken
+}
+
+func (u *UserMemoryRepository) DeleteRefreshToken(id string) error {
+	return u.deleteRefreshTokens(func(token *RefreshToken) bool {
+		return token.ID == id
+	})
+}
+
+func (u *UserMemoryRepository) DeleteRefreshTokens(username string) error {
+	return u.deleteRefreshTokens(func(token *RefreshToken) bool {
+		return token.Username == username
+	})
+}
+
+// ExportRefreshTokens returns a copy of all refresh tokens, see Export.
+func (u *UserMemoryRepository) ExportRefreshTokens() []*RefreshToken {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	tokens := []*RefreshToken{}
+	for _, token := range u.tokens {
+		exported := *token
+		tokens = append(tokens, &exported)
+	}
+	return tokens
+}
+
+// ImportRefreshTokens replaces all refresh tokens with a copy of tokens.
+func (u *UserMemoryRepository) ImportRefreshTokens(tokens []*RefreshToken) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.tokens = nil
+	for _, token := range tokens {
+		imported := *token
+		u.tokens = append(u.tokens, &imported)
+	}
+}
+
+func (u *UserMemoryRepository) SaveAPIToken(token *APIToken) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if !u.exists(token.Username) {
+		return ErrUserNotFound
+	}
+	for _, existing := range u.apiTokens {
+		if existing.Username == token.Username && existing.Name == token.Name {
+			return ErrTokenExists
+		}
+	}
+	u.apiTokens = append(u.apiTokens, copyAPIToken(token))
+	return nil
+}
+
+func (u *UserMemoryRepository) FindAPIToken(id string) (*APIToken, error) {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	for _, token := range u.apiTokens {
+		if token.ID == id {
+			return copyAPIToken(token), nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+func (u *UserMemoryRepository) FindAPITokens(username string) ([]*APIToken, error) {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	var tokens []*APIToken
+	for _, token := range u.apiTokens {
+		if token.Username == username {
+			tokens = append(tokens, copyAPIToken(token))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Name < tokens[j].Name
+	})
+	return tokens, nil
+}
+
+func (u *UserMemoryRepository) DeleteAPIToken(username string, id string) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for i, token := range u.apiTokens {
+		if token.ID == id && token.Username == username {
+			u.apiTokens = append(u.apiTokens[:i:i], u.apiTokens[i+1:]...)
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// ExportAPITokens returns a copy of all API tokens, see Export.
+func (u *UserMemoryRepository) ExportAPITokens() []*APIToken {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	tokens := []*APIToken{}
+	for _, token := range u.apiTokens {
+		tokens = append(tokens, copyAPIToken(token))
+	}
+	return tokens
+}
+
+// ImportAPITokens replaces all API tokens with a copy of tokens.
+func (u *UserMemoryRepository) ImportAPITokens(tokens []*APIToken) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.apiTokens = nil
+	for _, token := range tokens {
+		u.apiTokens = append(u.apiTokens, copyAPIToken(token))
+	}
+}
+
+func (u *UserMemoryRepository) SaveOneTimeToken(token *OneTimeToken) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if !u.exists(token.Username) {
+		return ErrUserNotFound
+	}
+	var kept []*OneTimeToken
+	for _, existing := range u.mailed {
+		if existing.Username != token.Username || existing.Purpose != token.Purpose {
+			kept = append(kept, existing)
+		}
+	}
+	saved := *token
+	u.mailed = append(kept, &saved)
+	return nil
+}
+
+func (u *UserMemoryRepository) TakeOneTimeToken(purpose TokenPurpose, hash string) (*OneTimeToken, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for i, token := range u.mailed {
+		if token.Purpose == purpose && token.Hash == hash {
+			u.mailed = append(u.mailed[:i:i], u.mailed[i+1:]...)
+			return token, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// ExportOneTimeTokens returns a copy of all mailed tokens, see Export.
+func (u *UserMemoryRepository) ExportOneTimeTokens() []*OneTimeToken {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	tokens := []*OneTimeToken{}
+	for _, token := range u.mailed {
+		exported := *token
+		tokens = append(tokens, &exported)
+	}
+	return tokens
+}
+
+// ImportOneTimeTokens replaces all mailed tokens with a copy of tokens.
+func (u *UserMemoryRepository) ImportOneTimeTokens(tokens []*OneTimeToken) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.mailed = nil
+	for _, token := range tokens {
+		imported := *token
+		u.mailed = append(u.mailed, &imported)
+	}
+}
+
+func (u *UserMemoryRepository) deleteRefreshTokens(matches func(token *RefreshToken) bool) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	var kept []*RefreshToken
+	for _, token := range u.tokens {
+		if !matches(token) {
+			kept = append(kept, token)
+		}
+	}
+	u.tokens = kept
+	return nil
+}
+
+func (u *UserMemoryRepository) updateUser(username string, update func(user *User)) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for _, user := range u.users {
+		if user.Username == username {
+			update(user)
+			return nil
+		}
+	}
+	return ErrUserNotFound
+}
+
+func withoutPassword(user *User) *User {
+	result := *user
+	result.Password = ""
+	return &result
+}
+
+func copyAPIToken(token *APIToken) *APIToken {
+	result := *token
+	result.Token = ""
+	result.Scopes = append([]Scope(nil), token.Scopes...)
+	if token.ExpiresAt != nil {
+		expiresAt := *token.ExpiresAt
+		result.ExpiresAt = &expiresAt
+	}
+	return &result
+}
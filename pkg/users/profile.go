@@ -0,0 +1,326 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Profile is what users see of their own account. Token and
+// RefreshToken are only set when a change replaced the login session.
+type Profile struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     Role   `json:"role"`
+	Verified bool   `json:"verified"`
+	ContactDetails
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// ProfilePatch holds a partial update of a profile. Fields left nil are
+// not changed, empty contact details are cleared.
+type ProfilePatch struct {
+	Username    *string `json:"username"`
+	Email       *string `json:"email"`
+	DisplayName *string `json:"display_name"`
+	Callsign    *string `json:"callsign"`
+	Contact     *string `json:"contact"`
+}
+
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type AccountDeletion struct {
+	Password string `json:"password"`
+}
+
+// ProfileHandler lets users read, change and delete their own account on
+// Path and change their password on Path/password. It has to be wrapped
+// with Authenticate and RequireLoginToken.
+//
+// Access tokens carry username and verification state, so changing the
+// username, the email address or the password ends all sessions and
+// answers with the tokens of a new one. References follow renamed and
+// deleted users. All changes of a PATCH are stored at once. If
+// AccountMails is set, changed email addresses are then mailed a
+// verification token.
+type ProfileHandler struct {
+	Path           string
+	UserRepository UserRepository
+	References     UserReferences
+	AccountMails   *AccountMails
+	Tokens         *Tokens
+	Sessions       *Sessions
+}
+
+func (p *ProfileHandler) Profile(writer http.ResponseWriter, request *http.Request) {
+	user, ok := p.currentUser(writer, request)
+	if !ok {
+		return
+	}
+	switch request.Method {
+	case "GET":
+		writeJSON(writer, http.StatusOK, profileOf(user))
+	case "PATCH":
+		p.update(writer, request, user)
+	case "DELETE":
+		p.delete(writer, request, user)
+	default:
+		apierror.MethodNotAllowed(writer)
+	}
+}
+
+func (p *ProfileHandler) Password(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "POST" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+	user, ok := p.currentUser(writer, request)
+	if !ok {
+		return
+	}
+	change := PasswordChange{}
+	if !readProfileRequest(writer, request, &change) {
+		return
+	}
+	if !p.checkPassword(writer, user, "current_password", change.CurrentPassword) {
+		return
+	}
+	var validationError *ValidationError
+	if errors.As(ValidatePassword(user.Username, change.NewPassword), &validationError) {
+		for i := range validationError.Fields {
+			validationError.Fields[i].Field = "new_password"
+		}
+		apierror.Invalid(writer, validationError.Fields...)
+		return
+	}
+	if err := p.UserRepository.UpdatePassword(user.Username, change.NewPassword); err != nil {
+		apierror.Internal(writer)
+		return
+	}
+	p.writeRenewedProfile(writer, user)
+}
+
+func (p *ProfileHandler) update(writer http.ResponseWriter, request *http.Request, user *User) {
+	patch := ProfilePatch{}
+	if !readProfileRequest(writer, request, &patch) {
+		return
+	}
+	normalizeProfilePatch(&patch, user)
+	var validationError *ValidationError
+	if errors.As(ValidateProfilePatch(&patch), &validationError) {
+		apierror.Invalid(writer, validationError.Fields...)
+		return
+	}
+	var username, email string
+	if patch.Username != nil {
+		username = *patch.Username
+	}
+	if patch.Email != nil {
+		email = *patch.Email
+	}
+	if !checkAvailable(writer, p.UserRepository, username, email) {
+		return
+	}
+
+	change := ProfileChange{Username: username, Email: email}
+	if patch.DisplayName != nil || patch.Callsign != nil || patch.Contact != nil {
+		details := user.ContactDetails
+		if patch.DisplayName != nil {
+			details.DisplayName = *patch.DisplayName
+		}
+		if patch.Callsign != nil {
+			details.Callsign = *patch.Callsign
+		}
+		if patch.Contact != nil {
+			details.Contact = *patch.Contact
+		}
+		change.ContactDetails = &details
+	}
+	if change != (ProfileChange{}) {
+		if !p.writeUpdateError(writer, p.UserRepository.UpdateProfile(user.Username, change)) {
+			return
+		}
+	}
+	if change.ContactDetails != nil {
+		user.ContactDetails = *change.ContactDetails
+	}
+	if username != "" {
+		if p.References != nil {
+			if err := p.References.RenameUser(user.Username, username); err != nil {
+				apierror.Internal(writer)
+				return
+			}
+		}
+		user.Username = username
+	}
+	if email != "" {
+		user.Email = email
+		user.Verified = false
+		if p.AccountMails != nil {
+			changed := *user
+			p.AccountMails.Background(func() error {
+				return p.AccountMails.SendVerification(&changed)
+			})
+		}
+	}
+
+	if username == "" && email == "" {
+		writeJSON(writer, http.StatusOK, profileOf(user))
+		return
+	}
+	p.writeRenewedProfile(writer, user)
+}
+
+// delete hands the nodes of the user on before deleting it, see
+// UserReferences.ReleaseUser.
+func (p *ProfileHandler) delete(writer http.ResponseWriter, request *http.Request, user *User) {
+	deletion := AccountDeletion{}
+	if !readProfileRequest(writer, request, &deletion) {
+		return
+	}
+	if !p.checkPassword(writer, user, "password", deletion.Password) {
+		return
+	}
+	if p.References != nil {
+		if err := p.References.ReleaseUser(user.Username); err != nil {
+			apierror.Internal(writer)
+			return
+		}
+	}
+	if err := p.UserRepository.DeleteUser(user.Username); err != nil {
+		apierror.Internal(writer)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// currentUser reads the authenticated user from the repository, as the
+// access token may be older than the last change.
+func (p *ProfileHandler) currentUser(writer http.ResponseWriter, request *http.Request) (*User, bool) {
+	username, ok := UsernameFromContext(request.Context())
+	if !ok {
+		apierror.Unauthorized(writer)
+		return nil, false
+	}
+	user, err := p.UserRepository.FindByUsername(username)
+	if err != nil {
+		apierror.Internal(writer)
+		return nil, false
+	}
+	if user == nil {
+		apierror.Unauthorized(writer)
+		return nil, false
+	}
+	if !user.Role.Valid() {
+		user.Role = RoleMember
+	}
+	return user, true
+}
+
+// checkPassword answers with 422 on field unless password is the one of
+// user.
+func (p *ProfileHandler) checkPassword(writer http.ResponseWriter, user *User, field string, password string) bool {
+	if password == "" {
+		apierror.Invalid(writer, apierror.FieldError{Field: field, Message: "is required"})
+		return false
+	}
+	confirmed, err := p.UserRepository.FindByEmailAndPassword(user.Email, password)
+	if err != nil {
+		apierror.Internal(writer)
+		return false
+	}
+	if confirmed == nil {
+		apierror.Invalid(writer, apierror.FieldError{Field: field, Message: "is wrong"})
+		return false
+	}
+	return true
+}
+
+// writeUpdateError answers with 409 if a concurrent request took the
+// username or email address since the check.
+func (p *ProfileHandler) writeUpdateError(writer http.ResponseWriter, err error) bool {
+	if errors.Is(err, ErrUserExists) {
+		apierror.Write(writer, http.StatusConflict, "user_exists", "username or email address is taken")
+		return false
+	}
+	if err != nil {
+		apierror.Internal(writer)
+		return false
+	}
+	return true
+}
+
+// writeRenewedProfile ends all sessions of user and answers with the
+// tokens of a new one.
+func (p *ProfileHandler) writeRenewedProfile(writer http.ResponseWriter, user *User) {
+	var session, refreshToken string
+	if p.Sessions != nil {
+		err := p.Sessions.EndAll(user.Username)
+		if err == nil {
+			session, refreshToken, err = p.Sessions.Start(user.Username)
+		}
+		if err != nil {
+			apierror.Internal(writer)
+			return
+		}
+	}
+	profile := profileOf(user)
+	if p.Tokens != nil {
+		token, err := p.Tokens.Create(user, session)
+		if err != nil {
+			apierror.Internal(writer)
+			return
+		}
+		profile.Token = token
+		profile.RefreshToken = refreshToken
+	}
+	writeJSON(writer, http.StatusOK, profile)
+}
+
+// normalizeProfilePatch trims the fields of patch and drops username and
+// email address if they do not change.
+func normalizeProfilePatch(patch *ProfilePatch, user *User) {
+	for _, field := range []*string{patch.Username, patch.DisplayName, patch.Callsign, patch.Contact} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+	if patch.Email != nil {
+		*patch.Email = NormalizeEmail(*patch.Email)
+	}
+	if patch.Callsign != nil {
+		*patch.Callsign = strings.ToUpper(*patch.Callsign)
+	}
+	if patch.Username != nil && *patch.Username == user.Username {
+		patch.Username = nil
+	}
+	if patch.Email != nil && *patch.Email == user.Email {
+		patch.Email = nil
+	}
+}
+
+func profileOf(user *User) *Profile {
+	return &Profile{
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           user.Role,
+		Verified:       user.Verified,
+		ContactDetails: user.ContactDetails,
+	}
+}
+
+func readProfileRequest(writer http.ResponseWriter, request *http.Request, body interface{}) bool {
+	requestBody, _ := ioutil.ReadAll(request.Body)
+	if json.Unmarshal(requestBody, body) != nil {
+		apierror.MalformedJSON(writer)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package users_test
+
+import (
+	"encoding/json"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+// RecordingReferences keeps the renamed and released users.
+type RecordingReferences struct {
+	Renamed  []string
+	Released []string
+}
+
+func (r *RecordingReferences) RenameUser(username string, newUsername string) error {
+	r.Renamed = append(r.Renamed, username+" "+newUsername)
+	return nil
+}
+
+func (r *RecordingReferences) ReleaseUser(username string) error {
+	r.Released = append(r.Released, username)
+	return nil
+}
+
+// conflictingRepository fails profile updates as if a concurrent request
+// took the new username or email address since they were checked.
+type conflictingRepository struct {
+	*users.UserMemoryRepository
+}
+
+func (c conflictingRepository) UpdateProfile(username string, change users.ProfileChange) error {
+	return users.ErrUserExists
+}
+
+var _ = Describe("Profile", func() {
+
+	var repository *users.UserMemoryRepository
+	var references *RecordingReferences
+	var mailer *RecordingMailer
+	var sessions *users.Sessions
+	var handler *users.ProfileHandler
+
+	BeforeEach(func() {
+		repository = &users.UserMemoryRepository{}
+		for _, username := range []string{"flo", "bob"} {
+			Expect(repository.RegisterUser(&users.User{
+				Username: username,
+				Email:    username + "@example.org",
+				Password: "sup3rpassw0rd",
+			})).To(Succeed())
+		}
+		Expect(repository.SetVerified("flo")).To(Succeed())
+		references = &RecordingReferences{}
+		mailer = &RecordingMailer{}
+		sessions = &users.Sessions{Repository: repository, TTL: time.Hour}
+		handler = &users.ProfileHandler{
+			Path:           "/users/me",
+			UserRepository: repository,
+			References:     references,
+			AccountMails: &users.AccountMails{
+				UserRepository: repository,
+				Tokens:         repository,
+				Mailer:         mailer,
+			},
+			Tokens:   &users.Tokens{Secret: []byte("test-secret"), TTL: 15 * time.Minute},
+			Sessions: sessions,
+		}
+	})
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request = request.WithContext(users.ContextWithUsername(request.Context(), "flo"))
+		recorder := httptest.NewRecorder()
+		if strings.HasSuffix(path, "/password") {
+			handler.Password(recorder, request)
+		} else {
+			handler.Profile(recorder, request)
+		}
+		return recorder
+	}
+
+	profile := func(recorder *httptest.ResponseRecorder) *users.Profile {
+		Expect(recorder.Code).To(Equal(200), recorder.Body.String())
+		result := &users.Profile{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), result)).To(Succeed())
+		return result
+	}
+
+	It("shows the profile", func() {
+		Expect(repository.UpdateContactDetails("flo", users.ContactDetails{Callsign: "DL1ABC"})).To(Succeed())
+
+		result := profile(request("GET", "/users/me", ""))
+
+		Expect(result).To(Equal(&users.Profile{
+			Username:       "flo",
+			Email:          "flo@example.org",
+			Role:           users.RoleMember,
+			Verified:       true,
+			ContactDetails: users.ContactDetails{Callsign: "DL1ABC"},
+		}))
+	})
+
+	It("changes contact details without a new session", func() {
+		_, _, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+
+		result := profile(request("PATCH", "/users/me",
+			`{"display_name": " Florent ", "callsign": "dl1abc/p", "contact": "https://example.org/flo"}`))
+
+		Expect(result.ContactDetails).To(Equal(users.ContactDetails{
+			DisplayName: "Florent",
+			Callsign:    "DL1ABC/P",
+			Contact:     "https://example.org/flo",
+		}))
+		Expect(result.Token).To(BeEmpty())
+		Expect(repository.ExportRefreshTokens()).To(HaveLen(1))
+
+		result = profile(request("PATCH", "/users/me", `{"contact": ""}`))
+
+		Expect(result.DisplayName).To(Equal("Florent"))
+		Expect(result.Contact).To(BeEmpty())
+	})
+
+	It("renames users and their references", func() {
+		_, _, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+
+		result := profile(request("PATCH", "/users/me", `{"username": "florent"}`))
+
+		Expect(result.Username).To(Equal("florent"))
+		Expect(result.Token).NotTo(BeEmpty())
+		Expect(result.RefreshToken).NotTo(BeEmpty())
+		Expect(references.Renamed).To(Equal([]string{"flo florent"}))
+		Expect(repository.ExportRefreshTokens()).To(HaveLen(1), "Old sessions should be ended")
+		user, _, err := handler.Tokens.Validate(result.Token)
+		Expect(err).To(BeNil())
+		Expect(user.Username).To(Equal("florent"))
+	})
+
+	It("verifies changed email addresses", func() {
+		result := profile(request("PATCH", "/users/me", `{"email": " Florent@Example.org"}`))
+
+		Expect(result.Email).To(Equal("florent@example.org"))
+		Expect(result.Verified).To(BeFalse())
+		handler.AccountMails.Wait()
+		Expect(mailer.Messages).To(HaveLen(1))
+		Expect(mailer.Messages[0].To).To(Equal("florent@example.org"))
+	})
+
+	It("rejects invalid and taken usernames and email addresses", func() {
+		recorder := request("PATCH", "/users/me", `{"username": "bob"}`)
+		Expect(recorder.Code).To(Equal(409))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"username_taken"`))
+
+		recorder = request("PATCH", "/users/me", `{"email": "bob@example.org"}`)
+		Expect(recorder.Code).To(Equal(409))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"email_taken"`))
+
+		recorder = request("PATCH", "/users/me", `{"username": "me", "callsign": "DL1 ABC"}`)
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"username"`))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"callsign"`))
+
+		Expect(request("PATCH", "/users/me", `{"username": `).Code).To(Equal(400))
+		Expect(references.Renamed).To(BeEmpty())
+	})
+
+	It("changes nothing if a concurrent request took the username", func() {
+		handler.UserRepository = conflictingRepository{repository}
+
+		recorder := request("PATCH", "/users/me",
+			`{"username": "florent", "email": "florent@example.org", "callsign": "DL1ABC"}`)
+
+		Expect(recorder.Code).To(Equal(409))
+		user, err := repository.FindByUsername("flo")
+		Expect(err).To(BeNil())
+		Expect(user.Email).To(Equal("flo@example.org"))
+		Expect(user.Verified).To(BeTrue())
+		Expect(user.Callsign).To(BeEmpty())
+		Expect(references.Renamed).To(BeEmpty())
+		handler.AccountMails.Wait()
+		Expect(mailer.Messages).To(BeEmpty())
+	})
+
+	It("accepts the current username and email address", func() {
+		result := profile(request("PATCH", "/users/me", `{"username": "flo", "email": "flo@example.org"}`))
+
+		Expect(result.Verified).To(BeTrue())
+		Expect(result.Token).To(BeEmpty())
+		handler.AccountMails.Wait()
+		Expect(mailer.Messages).To(BeEmpty())
+	})
+
+	It("changes passwords with the current one", func() {
+		_, _, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+
+		recorder := request("POST", "/users/me/password",
+			`{"current_password": "wrong", "new_password": "n3w-passw0rd"}`)
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"current_password"`))
+
+		recorder = request("POST", "/users/me/password",
+			`{"current_password": "sup3rpassw0rd", "new_password": "short"}`)
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"field":"new_password"`))
+
+		result := profile(request("POST", "/users/me/password",
+			`{"current_password": "sup3rpassw0rd", "new_password": "n3w-passw0rd"}`))
+
+		Expect(result.Token).NotTo(BeEmpty())
+		Expect(repository.ExportRefreshTokens()).To(HaveLen(1), "Old sessions should be ended")
+		user, err := repository.FindByEmailAndPassword("flo@example.org", "n3w-passw0rd")
+		Expect(err).To(BeNil())
+		Expect(user).NotTo(BeNil())
+	})
+
+	It("deletes accounts after releasing their references", func() {
+		recorder := request("DELETE", "/users/me", `{"password": "wrong"}`)
+		Expect(recorder.Code).To(Equal(422))
+		Expect(references.Released).To(BeEmpty())
+
+		recorder = request("DELETE", "/users/me", `{"password": "sup3rpassw0rd"}`)
+
+		Expect(recorder.Code).To(Equal(204))
+		Expect(references.Released).To(Equal([]string{"flo"}))
+		Expect(repository.FindByUsername("flo")).To(BeNil())
+		Expect(request("GET", "/users/me", "").Code).To(Equal(401))
+	})
+
+	It("only allows known methods", func() {
+		Expect(request("PUT", "/users/me", "{}").Code).To(Equal(405))
+		Expect(request("GET", "/users/me/password", "").Code).To(Equal(405))
+	})
+})
@@ -2,6 +2,8 @@ package users
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
 	"io/ioutil"
 	"net/http"
 )
@@ -15,17 +17,38 @@ type User struct {
 	Email    string `json:"email"`
 	Password string `json:"password,omitempty"`
 	Token    string `json:"token"`
+	Role     Role   `json:"role,omitempty"`
+	Locked   bool   `json:"locked,omitempty"`
+	// Verified tells whether the user has proven to own the email
+	// address. Unverified users cannot register nodes.
+	Verified bool `json:"verified,omitempty"`
+	ContactDetails
 }
 
+// ContactDetails are shown with the nodes a user owns.
+type ContactDetails struct {
+	DisplayName string `json:"display_name,omitempty"`
+	// Callsign is the amateur radio callsign of the user, if any.
+	Callsign string `json:"callsign,omitempty"`
+	// Contact tells how to reach the user, e.g. a mail address or a
+	// website.
+	Contact string `json:"contact,omitempty"`
+}
+
+// UserRegistrationHandler registers unverified users. If AccountMails
+// is set, they are mailed a verification token; if that fails, they can
+// ask for another one. Invalid registrations are rejected with 422,
+// taken usernames and email addresses with 409.
 type UserRegistrationHandler struct {
 	Path           string
 	UserRepository UserRepository
+	AccountMails   *AccountMails
 }
 
 func (u *UserRegistrationHandler) Register(writer http.ResponseWriter, request *http.Request) {
 	method := request.Method
 	if method != "POST" {
-		writer.WriteHeader(http.StatusMethodNotAllowed)
+		apierror.MethodNotAllowed(writer)
 		return
 	}
 
@@ -33,19 +56,39 @@ func (u *UserRegistrationHandler) Register(writer http.ResponseWriter, request *
 	userRegistrationRequest := UserRegistration{}
 	err := json.Unmarshal(requestBody, &userRegistrationRequest)
 	if err != nil {
-		writer.WriteHeader(http.StatusBadRequest)
+		apierror.MalformedJSON(writer)
 		return
 	}
 
 	requestUser := userRegistrationRequest.User
+	requestUser.Email = NormalizeEmail(requestUser.Email)
+	var validationError *ValidationError
+	if errors.As(ValidateRegistration(&requestUser), &validationError) {
+		apierror.Invalid(writer, validationError.Fields...)
+		return
+	}
+	if !checkAvailable(writer, u.UserRepository, requestUser.Username, requestUser.Email) {
+		return
+	}
 	err = u.UserRepository.RegisterUser(&requestUser)
+	if errors.Is(err, ErrUserExists) {
+		// registered by a concurrent request since the check
+		apierror.Write(writer, http.StatusConflict, "user_exists", "username or email address is taken")
+		return
+	}
 	if err != nil {
-
-		panic(err)
+		apierror.Internal(writer)
+		return
+	}
+	if u.AccountMails != nil {
+		registered := requestUser
+		u.AccountMails.Background(func() error {
+			return u.AccountMails.SendVerification(&registered)
+		})
 	}
 
-	writer.WriteHeader(201)
 	writer.Header().Add("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusCreated)
 	userRegistrationResponse := UserRegistration{
 		User: User{
 			Username: requestUser.Username,
@@ -54,3 +97,31 @@ func (u *UserRegistrationHandler) Register(writer http.ResponseWriter, request *
 	bytes, _ := json.Marshal(&userRegistrationResponse)
 	_, _ = writer.Write(bytes)
 }
+
+// checkAvailable answers with 409 if username or email address are
+// taken, telling which one. Empty values are not checked.
+func checkAvailable(writer http.ResponseWriter, repository UserRepository, username string, email string) bool {
+	if username != "" {
+		existing, err := repository.FindByUsername(username)
+		if err != nil {
+			apierror.Internal(writer)
+			return false
+		}
+		if existing != nil {
+			apierror.Write(writer, http.StatusConflict, "username_taken", "username is taken")
+			return false
+		}
+	}
+	if email != "" {
+		existing, err := repository.FindByEmail(email)
+		if err != nil {
+			apierror.Internal(writer)
+			return false
+		}
+		if existing != nil {
+			apierror.Write(writer, http.StatusConflict, "email_taken", "email address is already registered")
+			return false
+		}
+	}
+	return true
+}
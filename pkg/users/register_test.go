@@ -3,6 +3,7 @@ package users_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/mvslovers/hnetdb/pkg/users"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -11,24 +12,139 @@ import (
 )
 
 type FakeUserRepository struct {
-	LoginResult *users.User
+	LoginResult   *users.User
+	Users         []*users.User
+	RegisterError error
 }
 
 func (fur FakeUserRepository) RegisterUser(user *users.User) error {
-	return nil
+	return fur.RegisterError
 }
 
 func (fur FakeUserRepository) FindByEmailAndPassword(email, password string) (*users.User, error) {
 	return fur.LoginResult, nil
 }
 
+func (fur FakeUserRepository) FindAll() ([]*users.User, error) {
+	return fur.Users, nil
+}
+
+func (fur FakeUserRepository) FindByUsername(username string) (*users.User, error) {
+	user, err := fur.find(username)
+	if err != nil {
+		return nil, nil
+	}
+	return user, nil
+}
+
+func (fur FakeUserRepository) FindByEmail(email string) (*users.User, error) {
+	for _, user := range fur.Users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (fur FakeUserRepository) find(username string) (*users.User, error) {
+	for _, user := range fur.Users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, users.ErrUserNotFound
+}
+
+func (fur FakeUserRepository) UpdateRole(username string, role users.Role) error {
+	user, err := fur.find(username)
+	if err != nil {
+		return err
+	}
+	user.Role = role
+	return nil
+}
+
+func (fur FakeUserRepository) SetLocked(username string, locked bool) error {
+	user, err := fur.find(username)
+	if err != nil {
+		return err
+	}
+	user.Locked = locked
+	return nil
+}
+
+func (fur FakeUserRepository) SetVerified(username string) error {
+	user, err := fur.find(username)
+	if err != nil {
+		return err
+	}
+	user.Verified = true
+	return nil
+}
+
+func (fur FakeUserRepository) UpdatePassword(username string, password string) error {
+	_, err := fur.find(username)
+	return err
+}
+
+func (fur FakeUserRepository) Rename(username string, newUsername string) error {
+	user, err := fur.find(username)
+	if err != nil {
+		return err
+	}
+	user.Username = newUsername
+	return nil
+}
+
+func (fur FakeUserRepository) UpdateEmail(username string, email string) error {
+	user, err := fur.find(username)
+	if err != nil {
+		return err
+	}
+	user.Email = email
+	user.Verified = false
+	return nil
+}
+
+func (fur FakeUserRepository) UpdateContactDetails(username string, details users.ContactDetails) error {
+	user, err := fur.find(username)
+	if err != nil {
+		return err
+	}
+	user.ContactDetails = details
+	return nil
+}
+
+func (fur FakeUserRepository) UpdateProfile(username string, change users.ProfileChange) error {
+	user, err := fur.find(username)
+	if err != nil {
+		return err
+	}
+	if change.ContactDetails != nil {
+		user.ContactDetails = *change.ContactDetails
+	}
+	if change.Email != "" {
+		user.Email = change.Email
+		user.Verified = false
+	}
+	if change.Username != "" {
+		user.Username = change.Username
+	}
+	return nil
+}
+
+func (fur FakeUserRepository) DeleteUser(username string) error {
+	_, err := fur.find(username)
+	return err
+}
+
 var _ = Describe("Users", func() {
 
 	var userRequest = users.UserRegistration{
 		User: users.User{
 			Username: "user",
 			Email:    "user@example.com",
-			Password: "s3cr3t",
+			Password: "s3cr3t-passw0rd",
 		}}
 
 	var expectedUserResponse = users.UserRegistration{
@@ -52,6 +168,94 @@ var _ = Describe("Users", func() {
 		Expect(unmarshalRegistration(testResponseWriter.Body)).To(Equal(&expectedUserResponse))
 	})
 
+	It("mails a verification token on registration", func() {
+		repository := &users.UserMemoryRepository{}
+		mailer := &RecordingMailer{}
+		handler := users.UserRegistrationHandler{
+			Path:           "/users",
+			UserRepository: repository,
+			AccountMails: &users.AccountMails{
+				UserRepository: repository,
+				Tokens:         repository,
+				Mailer:         mailer,
+			},
+		}
+
+		handler.Register(
+			httptest.NewRecorder(),
+			httptest.NewRequest("POST", "/users", strings.NewReader(marshalRegistration(userRequest))))
+
+		handler.AccountMails.Wait()
+		Expect(mailer.Messages).To(HaveLen(1))
+		Expect(mailer.Messages[0].To).To(Equal("user@example.com"))
+		user, err := repository.FindByUsername("user")
+		Expect(err).To(BeNil())
+		Expect(user.Verified).To(BeFalse())
+	})
+
+	register := func(repository users.UserRepository, body string) *httptest.ResponseRecorder {
+		handler := users.UserRegistrationHandler{
+			Path:           "/users",
+			UserRepository: repository,
+		}
+		recorder := httptest.NewRecorder()
+		handler.Register(recorder, httptest.NewRequest("POST", "/users", strings.NewReader(body)))
+		return recorder
+	}
+
+	It("normalizes email addresses", func() {
+		repository := &users.UserMemoryRepository{}
+
+		recorder := register(repository,
+			`{"user": {"username": "user", "email": " User@Example.COM ", "password": "s3cr3t-passw0rd"}}`)
+
+		Expect(recorder.Code).To(Equal(201))
+		user, err := repository.FindByEmail("user@example.com")
+		Expect(err).To(BeNil())
+		Expect(user).NotTo(BeNil())
+	})
+
+	It("rejects invalid registrations with the invalid fields", func() {
+		recorder := register(&FakeUserRepository{},
+			`{"user": {"username": "-x", "email": "no address", "password": ""}}`)
+
+		Expect(recorder.Code).To(Equal(422))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"error": {
+			"code": "validation_failed",
+			"message": "request has invalid fields",
+			"fields": [
+				{"field": "username", "message": "has to have 3 to 32 characters"},
+				{"field": "email", "message": "is not a valid email address"},
+				{"field": "password", "message": "has to have at least 8 characters"}
+			]
+		}}`))
+	})
+
+	It("rejects taken usernames", func() {
+		repository := &FakeUserRepository{Users: []*users.User{{Username: "user", Email: "other@example.com"}}}
+
+		recorder := register(repository, marshalRegistration(userRequest))
+
+		Expect(recorder.Code).To(Equal(409))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"username_taken"`))
+	})
+
+	It("rejects taken email addresses", func() {
+		repository := &FakeUserRepository{Users: []*users.User{{Username: "other", Email: "user@example.com"}}}
+
+		recorder := register(repository, marshalRegistration(userRequest))
+
+		Expect(recorder.Code).To(Equal(409))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"code":"email_taken"`))
+	})
+
+	It("answers repository failures with 500", func() {
+		recorder := register(&FakeUserRepository{RegisterError: errors.New("database down")},
+			marshalRegistration(userRequest))
+
+		Expect(recorder.Code).To(Equal(500))
+		Expect(recorder.Body.String()).NotTo(ContainSubstring("database down"))
+	})
 })
 
 func marshalRegistration(registration users.UserRegistration) string {
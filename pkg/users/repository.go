@@ -3,13 +3,61 @@ package users
 import (
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
 )
 
 type UserRepository interface {
 	RegisterUser(user *User) error
 	FindByEmailAndPassword(email string, password string) (*User, error)
+	// FindByUsername returns the user without password or nil if there is
+	// no user called username.
+	FindByUsername(username string) (*User, error)
+	// FindByEmail works like FindByUsername.
+	FindByEmail(email string) (*User, error)
+	FindAll() ([]*User, error)
+	UpdateRole(username string, role Role) error
+	SetLocked(username string, locked bool) error
+	SetVerified(username string) error
+	// UpdatePassword hashes and stores a new password.
+	UpdatePassword(username string, password string) error
+	// Rename changes the username, keeping the tokens of the user. It
+	// returns ErrUserExists if newUsername is taken.
+	Rename(username string, newUsername string) error
+	// UpdateEmail changes the email address, which has to be verified
+	// again. It returns ErrUserExists if the address is taken.
+	UpdateEmail(username string, email string) error
+	UpdateContactDetails(username string, details ContactDetails) error
+	// UpdateProfile applies change at once, so nothing is changed if the
+	// new username or email address is taken.
+	UpdateProfile(username string, change ProfileChange) error
+	// DeleteUser deletes the user together with all its tokens.
+	DeleteUser(username string) error
 }
 
+// ProfileChange is a change of a user made by UpdateProfile. Empty
+// username and email address and nil contact details are not changed.
+type ProfileChange struct {
+	Username       string
+	Email          string
+	ContactDetails *ContactDetails
+}
+
+// UserReferences is implemented by whatever refers to users by name, so
+// renamed and deleted users can be followed there.
+type UserReferences interface {
+	RenameUser(username string, newUsername string) error
+	// ReleaseUser removes the user from everything it is part of.
+	ReleaseUser(username string) error
+}
+
+// userColumns are returned for every user read from Neo4j, in the order
+// userFromRecord expects them.
+const userColumns = "u.username AS username, u.email AS email, coalesce(u.role, 'member') AS role, " +
+	"coalesce(u.locked, false) AS locked, coalesce(u.verified, false) AS verified, " +
+	"coalesce(u.display_name, '') AS display_name, coalesce(u.callsign, '') AS callsign, " +
+	"coalesce(u.contact, '') AS contact"
+
 type UserNeo4jRepository struct {
 	Driver neo4j.Driver
 }
@@ -19,12 +67,18 @@ func (u *UserNeo4jRepository) RegisterUser(user *User) (err error) {
 		AccessMode: neo4j.AccessModeWrite,
 	})
 	defer func() {
-		err = session.Close()
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
 	}()
 	if _, err := session.
 		WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
 			return u.persistUser(tx, user)
 		}); err != nil {
+		if neo4jError, ok := err.(*neo4j.Neo4jError); ok &&
+			neo4jError.Code == "Neo.ClientError.Schema.ConstraintValidationFailed" {
+			return ErrUserExists
+		}
 		return err
 	}
 	return nil
@@ -33,7 +87,9 @@ func (u *UserNeo4jRepository) RegisterUser(user *User) (err error) {
 func (u *UserNeo4jRepository) FindByEmailAndPassword(email string, password string) (user *User, err error) {
 	session := u.Driver.NewSession(neo4j.SessionConfig{})
 	defer func() {
-		err = session.Close()
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
 	}()
 	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
 		return u.findUser(tx, email, password)
@@ -45,8 +101,499 @@ func (u *UserNeo4jRepository) FindByEmailAndPassword(email string, password stri
 	return user, err
 }
 
+func (u *UserNeo4jRepository) FindByUsername(username string) (*User, error) {
+	return u.findUserBy("username", username)
+}
+
+func (u *UserNeo4jRepository) FindByEmail(email string) (*User, error) {
+	return u.findUserBy("email", email)
+}
+
+// findUserBy finds the user whose property has the given value.
+func (u *UserNeo4jRepository) findUserBy(property string, value string) (user *User, err error) {
+	session := u.Driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("MATCH (u:User {"+property+": $value}) RETURN "+userColumns,
+			map[string]interface{}{
+				"value": value,
+			})
+		if err != nil {
+			return nil, err
+		}
+		if !res.Next() {
+			return nil, res.Err()
+		}
+		return userFromRecord(res.Record()), nil
+	})
+	if result == nil {
+		return nil, err
+	}
+	return result.(*User), err
+}
+
+func (u *UserNeo4jRepository) FindAll() (users []*User, err error) {
+	session := u.Driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("MATCH (u:User) RETURN "+userColumns+" ORDER BY u.username", nil)
+		if err != nil {
+			return nil, err
+		}
+		var users []*User
+		for res.Next() {
+			users = append(users, userFromRecord(res.Record()))
+		}
+		return users, res.Err()
+	})
+	if result == nil {
+		return nil, err
+	}
+	return result.([]*User), err
+}
+
+func (u *UserNeo4jRepository) UpdateRole(username string, role Role) (err error) {
+	return u.updateUser(username, "SET u.role = $value", string(role))
+}
+
+func (u *UserNeo4jRepository) SetLocked(username string, locked bool) (err error) {
+	return u.updateUser(username, "SET u.locked = $value", locked)
+}
+
+func (u *UserNeo4jRepository) SetVerified(username string) (err error) {
+	return u.updateUser(username, "SET u.verified = $value", true)
+}
+
+func (u *UserNeo4jRepository) UpdatePassword(username string, password string) (err error) {
+	hashedPassword, err := hash(password)
+	if err != nil {
+		return err
+	}
+	return u.updateUser(username, "SET u.password = $value", hashedPassword)
+}
+
+func (u *UserNeo4jRepository) Rename(username string, newUsername string) error {
+	return u.uniqueUpdate(u.updateUser(username, "SET u.username = $value", newUsername))
+}
+
+func (u *UserNeo4jRepository) UpdateEmail(username string, email string) error {
+	return u.uniqueUpdate(u.updateUser(username, "SET u.email = $value, u.verified = false", email))
+}
+
+func (u *UserNeo4jRepository) UpdateContactDetails(username string, details ContactDetails) error {
+	return u.updateUser(username, "SET u.display_name = $value.display_name, "+
+		"u.callsign = $value.callsign, u.contact = $value.contact",
+		map[string]interface{}{
+			"display_name": details.DisplayName,
+			"callsign":     details.Callsign,
+			"contact":      details.Contact,
+		})
+}
+
+func (u *UserNeo4jRepository) UpdateProfile(username string, change ProfileChange) error {
+	var set []string
+	value := map[string]interface{}{
+		"username": change.Username,
+		"email":    change.Email,
+	}
+	if details := change.ContactDetails; details != nil {
+		set = append(set, "u.display_name = $value.display_name, u.callsign = $value.callsign, "+
+			"u.contact = $value.contact")
+		value["display_name"] = details.DisplayName
+		value["callsign"] = details.Callsign
+		value["contact"] = details.Contact
+	}
+	if change.Email != "" {
+		set = append(set, "u.email = $value.email, u.verified = false")
+	}
+	if change.Username != "" {
+		set = append(set, "u.username = $value.username")
+	}
+	if len(set) == 0 {
+		return u.updateUser(username, "", nil)
+	}
+	return u.uniqueUpdate(u.updateUser(username, "SET "+strings.Join(set, ", "), value))
+}
+
+func (u *UserNeo4jRepository) DeleteUser(username string) error {
+	return u.updateUser(username, "OPTIONAL MATCH (u)-[:HAS_REFRESH_TOKEN|HAS_API_TOKEN|HAS_ONE_TIME_TOKEN]->(t) "+
+		"DETACH DELETE t WITH DISTINCT u DETACH DELETE u", nil)
+}
+
+// uniqueUpdate turns constraint violations of an update into
+// ErrUserExists.
+func (u *UserNeo4jRepository) uniqueUpdate(err error) error {
+	if neo4jError, ok := err.(*neo4j.Neo4jError); ok &&
+		neo4jError.Code == "Neo.ClientError.Schema.ConstraintValidationFailed" {
+		return ErrUserExists
+	}
+	return err
+}
+
+// updateUser runs the set clause against the user called username,
+// passing value as $value.
+func (u *UserNeo4jRepository) updateUser(username string, set string, value interface{}) (err error) {
+	session := u.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("MATCH (u:User {username: $username}) "+set+" RETURN count(u) AS updated",
+			map[string]interface{}{
+				"username": username,
+				"value":    value,
+			})
+		if err != nil {
+			return nil, err
+		}
+		record, err := res.Single()
+		if err != nil {
+			return nil, err
+		}
+		if record.Values[0].(int64) == 0 {
+			return nil, ErrUserNotFound
+		}
+		return nil, nil
+	})
+	return err
+}
+
+func (u *UserNeo4jRepository) SaveRefreshToken(token *RefreshToken) error {
+	return u.writeTokens(func(tx neo4j.Transaction) error {
+		if _, err := tx.Run("MATCH (:User {username: $username})-[:HAS_REFRESH_TOKEN]->(t:RefreshToken) "+
+			"WHERE t.expires_at < datetime() DETACH DELETE t",
+			map[string]interface{}{
+				"username": token.Username,
+			}); err != nil {
+			return err
+		}
+		res, err := tx.Run("MATCH (u:User {username: $username}) "+
+			"CREATE (u)-[:HAS_REFRESH_TOKEN]->(:RefreshToken {id: $id, hash: $hash, expires_at: $expires_at}) "+
+			"RETURN count(u) AS created",
+			map[string]interface{}{
+				"username":   token.Username,
+				"id":         token.ID,
+				"hash":       token.Hash,
+				"expires_at": token.ExpiresAt.UTC(),
+			})
+		if err != nil {
+			return err
+		}
+		record, err := res.Single()
+		if err != nil {
+			return err
+		}
+		if record.Values[0].(int64) == 0 {
+			return ErrUserNotFound
+		}
+		return nil
+	})
+}
+
+func (u *UserNeo4jRepository) FindRefreshToken(id string) (token *RefreshToken, err error) {
+	session := u.Driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run("MATCH (u:User)-[:HAS_REFRESH_TOKEN]->(t:RefreshToken {id: $id}) "+
+			"RETURN u.username AS username, t.hash AS hash, t.expires_at AS expires_at",
+			map[string]interface{}{
+				"id": id,
+			})
+		if err != nil {
+			return nil, err
+		}
+		if !res.Next() {
+			if err := res.Err(); err != nil {
+				return nil, err
+			}
+			return nil, ErrInvalidToken
+		}
+		record := res.Record()
+		return &RefreshToken{
+			ID:        id,
+			Username:  record.Values[0].(string),
+			Hash:      record.Values[1].(string),
+			ExpiresAt: record.Values[2].(time.Time),
+		}, nil
+	})
+	if result == nil {
+		return nil, err
+	}
+	return result.(*RefreshToken), err
+}
+
+func (u *UserNeo4jRepository) RotateRefreshToken(token *RefreshToken, previousHash string) error {
+	return u.writeTokens(func(tx neo4j.Transaction) error {
+		res, err := tx.Run("MATCH (t:RefreshToken {id: $id, hash: $previous_hash}) "+
+			"SET t.hash = $hash, t.expires_at = $expires_at RETURN count(t) AS rotated",
+			map[string]interface{}{
+				"id":            token.ID,
+				"previous_hash": previousHash,
+				"hash":          token.Hash,
+				"expires_at":    token.ExpiresAt.UTC(),
+			})
+		if err != nil {
+			return err
+		}
+		record, err := res.Single()
+		if err != nil {
+			return err
+		}
+		if record.Values[0].(int64) == 0 {
+			return ErrInvalidToken
+		}
+		return nil
+	})
+}
+
+func (u *UserNeo4jRepository) DeleteRefreshToken(id string) error {
+	return u.writeTokens(func(tx neo4j.Transaction) error {
+		_, err := tx.Run("MATCH (t:RefreshToken {id: $id}) DETACH DELETE t",
+			map[string]interface{}{
+				"id": id,
+			})
+		return err
+	})
+}
+
+func (u *UserNeo4jRepository) DeleteRefreshTokens(username string) error {
+	return u.writeTokens(func(tx neo4j.Transaction) error {
+		_, err := tx.Run("MATCH (:User {username: $username})-[:HAS_REFRESH_TOKEN]->(t:RefreshToken) "+
+			"DETACH DELETE t",
+			map[string]interface{}{
+				"username": username,
+			})
+		return err
+	})
+}
+
+func (u *UserNeo4jRepository) SaveAPIToken(token *APIToken) error {
+	return u.writeTokens(func(tx neo4j.Transaction) error {
+		res, err := tx.Run("MATCH (u:User {username: $username}) "+
+			"OPTIONAL MATCH (u)-[:HAS_API_TOKEN]->(t:APIToken {name: $name}) "+
+			"RETURN count(DISTINCT u) AS users, count(t) AS tokens",
+			map[string]interface{}{
+				"username": token.Username,
+				"name":     token.Name,
+			})
+		if err != nil {
+			return err
+		}
+		record, err := res.Single()
+		if err != nil {
+			return err
+		}
+		if record.Values[0].(int64) == 0 {
+			return ErrUserNotFound
+		}
+		if record.Values[1].(int64) > 0 {
+			return ErrTokenExists
+		}
+
+		scopes := make([]string, len(token.Scopes))
+		for i, scope := range token.Scopes {
+			scopes[i] = string(scope)
+		}
+		var expiresAt interface{}
+		if token.ExpiresAt != nil {
+			expiresAt = token.ExpiresAt.UTC()
+		}
+		_, err = tx.Run("MATCH (u:User {username: $username}) "+
+			"CREATE (u)-[:HAS_API_TOKEN]->(:APIToken {id: $id, name: $name, hash: $hash, scopes: $scopes, "+
+			"created_at: $created_at, expires_at: $expires_at})",
+			map[string]interface{}{
+				"username":   token.Username,
+				"id":         token.ID,
+				"name":       token.Name,
+				"hash":       token.Hash,
+				"scopes":     scopes,
+				"created_at": token.CreatedAt.UTC(),
+				"expires_at": expiresAt,
+			})
+		return err
+	})
+}
+
+func (u *UserNeo4jRepository) FindAPIToken(id string) (*APIToken, error) {
+	tokens, err := u.findAPITokens("MATCH (u:User)-[:HAS_API_TOKEN]->(t:APIToken {id: $id})",
+		map[string]interface{}{
+			"id": id,
+		})
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, ErrInvalidToken
+	}
+	return tokens[0], nil
+}
+
+func (u *UserNeo4jRepository) FindAPITokens(username string) ([]*APIToken, error) {
+	return u.findAPITokens("MATCH (u:User {username: $username})-[:HAS_API_TOKEN]->(t:APIToken)",
+		map[string]interface{}{
+			"username": username,
+		})
+}
+
+func (u *UserNeo4jRepository) DeleteAPIToken(username string, id string) error {
+	return u.writeTokens(func(tx neo4j.Transaction) error {
+		res, err := tx.Run("MATCH (:User {username: $username})-[:HAS_API_TOKEN]->(t:APIToken {id: $id}) "+
+			"DETACH DELETE t RETURN count(t) AS deleted",
+			map[string]interface{}{
+				"username": username,
+				"id":       id,
+			})
+		if err != nil {
+			return err
+		}
+		record, err := res.Single()
+		if err != nil {
+			return err
+		}
+		if record.Values[0].(int64) == 0 {
+			return ErrTokenNotFound
+		}
+		return nil
+	})
+}
+
+func (u *UserNeo4jRepository) SaveOneTimeToken(token *OneTimeToken) error {
+	return u.writeTokens(func(tx neo4j.Transaction) error {
+		res, err := tx.Run("MATCH (u:User {username: $username}) "+
+			"OPTIONAL MATCH (u)-[:HAS_ONE_TIME_TOKEN]->(t:OneTimeToken {purpose: $purpose}) "+
+			"DETACH DELETE t "+
+			"WITH DISTINCT u "+
+			"CREATE (u)-[:HAS_ONE_TIME_TOKEN]->(:OneTimeToken {hash: $hash, purpose: $purpose, "+
+			"expires_at: $expires_at}) "+
+			"RETURN count(u) AS created",
+			map[string]interface{}{
+				"username":   token.Username,
+				"purpose":    string(token.Purpose),
+				"hash":       token.Hash,
+				"expires_at": token.ExpiresAt.UTC(),
+			})
+		if err != nil {
+			return err
+		}
+		record, err := res.Single()
+		if err != nil {
+			return err
+		}
+		if record.Values[0].(int64) == 0 {
+			return ErrUserNotFound
+		}
+		return nil
+	})
+}
+
+func (u *UserNeo4jRepository) TakeOneTimeToken(purpose TokenPurpose, hash string) (token *OneTimeToken, err error) {
+	err = u.writeTokens(func(tx neo4j.Transaction) error {
+		res, err := tx.Run("MATCH (u:User)-[:HAS_ONE_TIME_TOKEN]->(t:OneTimeToken {purpose: $purpose, hash: $hash}) "+
+			"WITH u, t, t.expires_at AS expires_at DETACH DELETE t "+
+			"RETURN u.username AS username, expires_at",
+			map[string]interface{}{
+				"purpose": string(purpose),
+				"hash":    hash,
+			})
+		if err != nil {
+			return err
+		}
+		if !res.Next() {
+			if err := res.Err(); err != nil {
+				return err
+			}
+			return ErrInvalidToken
+		}
+		record := res.Record()
+		token = &OneTimeToken{
+			Hash:      hash,
+			Username:  record.Values[0].(string),
+			Purpose:   purpose,
+			ExpiresAt: record.Values[1].(time.Time),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// findAPITokens returns the tokens t matched by match for user u.
+func (u *UserNeo4jRepository) findAPITokens(match string, parameters map[string]interface{}) (tokens []*APIToken, err error) {
+	session := u.Driver.NewSession(neo4j.SessionConfig{})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	result, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		res, err := tx.Run(match+" RETURN t.id AS id, u.username AS username, t.name AS name, "+
+			"t.hash AS hash, t.scopes AS scopes, t.created_at AS created_at, t.expires_at AS expires_at "+
+			"ORDER BY t.name", parameters)
+		if err != nil {
+			return nil, err
+		}
+		var tokens []*APIToken
+		for res.Next() {
+			record := res.Record()
+			token := &APIToken{
+				ID:        record.Values[0].(string),
+				Username:  record.Values[1].(string),
+				Name:      record.Values[2].(string),
+				Hash:      record.Values[3].(string),
+				CreatedAt: record.Values[5].(time.Time),
+			}
+			for _, scope := range record.Values[4].([]interface{}) {
+				token.Scopes = append(token.Scopes, Scope(scope.(string)))
+			}
+			if expiresAt, ok := record.Values[6].(time.Time); ok {
+				token.ExpiresAt = &expiresAt
+			}
+			tokens = append(tokens, token)
+		}
+		return tokens, res.Err()
+	})
+	if result == nil {
+		return nil, err
+	}
+	return result.([]*APIToken), err
+}
+
+func (u *UserNeo4jRepository) writeTokens(work func(tx neo4j.Transaction) error) (err error) {
+	session := u.Driver.NewSession(neo4j.SessionConfig{
+		AccessMode: neo4j.AccessModeWrite,
+	})
+	defer func() {
+		if closeErr := session.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		return nil, work(tx)
+	})
+	return err
+}
+
 func (u *UserNeo4jRepository) persistUser(tx neo4j.Transaction, user *User) (interface{}, error) {
-	query := "CREATE (:User {email: $email, username: $username, password: $password})"
+	query := "CREATE (:User {email: $email, username: $username, password: $password, " +
+		"role: $role, locked: false, verified: false})"
 	hashedPassword, err := hash(user.Password)
 	if err != nil {
 		return nil, err
@@ -55,6 +602,7 @@ func (u *UserNeo4jRepository) persistUser(tx neo4j.Transaction, user *User) (int
 		"email":    user.Email,
 		"username": user.Username,
 		"password": hashedPassword,
+		"role":     string(RoleMember),
 	}
 	_, err = tx.Run(query, parameters)
 	return nil, err
@@ -62,7 +610,7 @@ func (u *UserNeo4jRepository) persistUser(tx neo4j.Transaction, user *User) (int
 
 func (u *UserNeo4jRepository) findUser(tx neo4j.Transaction, email string, password string) (*User, error) {
 	result, err := tx.Run(
-		"MATCH (u:User {email: $email}) RETURN u.username AS username, u.password AS password",
+		"MATCH (u:User {email: $email}) RETURN "+userColumns+", u.password AS password",
 		map[string]interface{}{
 			"email": email,
 		},
@@ -70,19 +618,30 @@ func (u *UserNeo4jRepository) findUser(tx neo4j.Transaction, email string, passw
 	if err != nil {
 		return nil, err
 	}
-	record, err := result.Single()
-	if err != nil {
-		return nil, err
+	if !result.Next() {
+		return nil, result.Err()
 	}
+	record := result.Record()
 	hashedPassword, _ := record.Get("password")
 	if !passwordsMatch(hashedPassword.(string), password) {
 		return nil, nil
 	}
-	username, _ := record.Get("username")
+	return userFromRecord(record), nil
+}
+
+func userFromRecord(record *neo4j.Record) *User {
 	return &User{
-		Username: username.(string),
-		Email:    email,
-	}, nil
+		Username: record.Values[0].(string),
+		Email:    record.Values[1].(string),
+		Role:     Role(record.Values[2].(string)),
+		Locked:   record.Values[3].(bool),
+		Verified: record.Values[4].(bool),
+		ContactDetails: ContactDetails{
+			DisplayName: record.Values[5].(string),
+			Callsign:    record.Values[6].(string),
+			Contact:     record.Values[7].(string),
+		},
+	}
 }
 
 func passwordsMatch(hashedPassword string, clearTextPassword string) bool {
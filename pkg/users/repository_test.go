@@ -3,6 +3,7 @@ package users_test
 import (
 	"context"
 	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/migrations"
 	"github.com/mvslovers/hnetdb/pkg/users"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	. "github.com/onsi/ginkgo"
@@ -116,6 +117,71 @@ var _ = Describe("User repository", func() {
 		Expect(err).To(BeNil(), "Login should not fail")
 		Expect(user).To(BeNil(), "User should not be found")
 	})
+
+	It("rejects duplicate users", func() {
+		runner := &migrations.Runner{Driver: driver, Migrations: migrations.Builtin}
+		_, err := runner.Up()
+		Expect(err).To(BeNil(), "Migrations should be applied")
+		user := &users.User{
+			Username: "some-user",
+			Email:    "some-user@example.com",
+			Password: "some-password",
+		}
+		Expect(repository.RegisterUser(user)).To(Succeed(), "User should be registered")
+
+		err = repository.RegisterUser(&users.User{
+			Username: "other-user",
+			Email:    "some-user@example.com",
+			Password: "other-password",
+		})
+		Expect(err).To(Equal(users.ErrUserExists))
+
+		err = repository.RegisterUser(&users.User{
+			Username: "some-user",
+			Email:    "other-user@example.com",
+			Password: "other-password",
+		})
+		Expect(err).To(Equal(users.ErrUserExists))
+	})
+
+	It("registers users as members", func() {
+		err := repository.RegisterUser(&users.User{
+			Username: "some-user",
+			Email:    "some-user@example.com",
+			Password: "some-password",
+			Role:     users.RoleAdmin,
+		})
+		Expect(err).To(BeNil(), "User should be registered")
+
+		user, err := repository.FindByEmailAndPassword("some-user@example.com", "some-password")
+		Expect(err).To(BeNil(), "Login should not fail")
+		Expect(user.Role).To(Equal(users.RoleMember))
+		Expect(user.Locked).To(BeFalse())
+	})
+
+	It("changes roles and locks users", func() {
+		err := repository.RegisterUser(&users.User{
+			Username: "some-user",
+			Email:    "some-user@example.com",
+			Password: "some-password",
+		})
+		Expect(err).To(BeNil(), "User should be registered")
+
+		Expect(repository.UpdateRole("some-user", users.RoleModerator)).To(Succeed())
+		Expect(repository.SetLocked("some-user", true)).To(Succeed())
+
+		all, err := repository.FindAll()
+		Expect(err).To(BeNil())
+		Expect(all).To(Equal([]*users.User{{
+			Username: "some-user",
+			Email:    "some-user@example.com",
+			Role:     users.RoleModerator,
+			Locked:   true,
+		}}))
+
+		Expect(repository.UpdateRole("nobody", users.RoleAdmin)).To(Equal(users.ErrUserNotFound))
+		Expect(repository.SetLocked("nobody", true)).To(Equal(users.ErrUserNotFound))
+	})
 })
 
 func hash(password string) string {
@@ -0,0 +1,43 @@
+package users
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/http"
+)
+
+type Role string
+
+const (
+	RoleMember    Role = "member"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleMember:    1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes tells whether r grants everything other grants. Admins can
+// do what moderators can, moderators can do what members can.
+func (r Role) Includes(other Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[other]
+}
+
+// RequireRole only passes requests of users holding at least role on to
+// next. It has to run behind Authenticate.
+func RequireRole(role Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if !RoleFromContext(request.Context()).Includes(role) {
+			apierror.Forbidden(writer, "requires role "+string(role))
+			return
+		}
+		next(writer, request)
+	}
+}
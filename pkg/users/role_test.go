@@ -0,0 +1,38 @@
+package users_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Roles", func() {
+
+	It("ranks roles", func() {
+		Expect(users.RoleAdmin.Includes(users.RoleModerator)).To(BeTrue())
+		Expect(users.RoleModerator.Includes(users.RoleModerator)).To(BeTrue())
+		Expect(users.RoleMember.Includes(users.RoleModerator)).To(BeFalse())
+		Expect(users.Role("root").Includes(users.RoleMember)).To(BeFalse())
+	})
+
+	It("requires a role", func() {
+		called := false
+		handler := users.RequireRole(users.RoleModerator, func(writer http.ResponseWriter, request *http.Request) {
+			called = true
+		})
+
+		request := httptest.NewRequest("POST", "/moderation", nil)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request.WithContext(users.ContextWithUser(request.Context(),
+			&users.User{Username: "flo", Role: users.RoleMember})))
+		Expect(recorder.Code).To(Equal(403))
+		Expect(called).To(BeFalse())
+
+		recorder = httptest.NewRecorder()
+		handler(recorder, request.WithContext(users.ContextWithUser(request.Context(),
+			&users.User{Username: "flo", Role: users.RoleAdmin})))
+		Expect(called).To(BeTrue())
+	})
+})
@@ -0,0 +1,160 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// RefreshToken is the stored form of a refresh token. Only a hash of the
+// secret part is kept. The ID stays the same when the token is rotated
+// and identifies the login session; access tokens carry it so that they
+// stop working once the session is revoked.
+type RefreshToken struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Hash      string    `json:"hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RefreshTokenRepository stores the refresh tokens of users.
+type RefreshTokenRepository interface {
+	// SaveRefreshToken stores a new token and drops the expired ones of
+	// the same user. It fails with ErrUserNotFound for unknown users.
+	SaveRefreshToken(token *RefreshToken) error
+	// FindRefreshToken fails with ErrInvalidToken for unknown IDs.
+	FindRefreshToken(id string) (*RefreshToken, error)
+	// RotateRefreshToken replaces hash and expiry of the token with the
+	// ID of token, but only if its hash still is previousHash. Otherwise
+	// it fails with ErrInvalidToken, so that a token can be rotated once.
+	RotateRefreshToken(token *RefreshToken, previousHash string) error
+	// DeleteRefreshToken and DeleteRefreshTokens ignore unknown tokens.
+	DeleteRefreshToken(id string) error
+	DeleteRefreshTokens(username string) error
+}
+
+// Sessions hands out refresh tokens that expire after TTL. Their plain
+// text form is {id}.{secret}.
+type Sessions struct {
+	Repository RefreshTokenRepository
+	TTL        time.Duration
+}
+
+// Start opens a session for username and returns its first refresh
+// token.
+func (s *Sessions) Start(username string) (id string, refreshToken string, err error) {
+	id, err = randomString(16)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	err = s.Repository.SaveRefreshToken(&RefreshToken{
+		ID:        id,
+		Username:  username,
+		Hash:      hashSecret(secret),
+		ExpiresAt: time.Now().Add(s.TTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return id, id + "." + secret, nil
+}
+
+// Rotate trades refreshToken for a new one of the same session. A token
+// that has been rotated before is a sign of theft, using it again ends
+// the session.
+func (s *Sessions) Rotate(refreshToken string) (*RefreshToken, string, error) {
+	stored, err := s.check(refreshToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+	rotated := &RefreshToken{
+		ID:        stored.ID,
+		Username:  stored.Username,
+		Hash:      hashSecret(secret),
+		ExpiresAt: time.Now().Add(s.TTL),
+	}
+	if err := s.Repository.RotateRefreshToken(rotated, stored.Hash); err != nil {
+		return nil, "", err
+	}
+	return rotated, rotated.ID + "." + secret, nil
+}
+
+// End revokes the session refreshToken belongs to.
+func (s *Sessions) End(refreshToken string) (*RefreshToken, error) {
+	stored, err := s.check(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return stored, s.Repository.DeleteRefreshToken(stored.ID)
+}
+
+// EndAll revokes all sessions of username.
+func (s *Sessions) EndAll(username string) error {
+	return s.Repository.DeleteRefreshTokens(username)
+}
+
+// Active fails with ErrInvalidToken unless the session with the given ID
+// exists and has not expired.
+func (s *Sessions) Active(id string) error {
+	stored, err := s.Repository.FindRefreshToken(id)
+	if errors.Is(err, ErrInvalidToken) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *Sessions) check(refreshToken string) (*RefreshToken, error) {
+	parts := strings.SplitN(refreshToken, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, ErrInvalidToken
+	}
+
+	stored, err := s.Repository.FindRefreshToken(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(hashSecret(parts[1]))) != 1 {
+		_ = s.Repository.DeleteRefreshToken(stored.ID)
+		return nil, ErrInvalidToken
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		_ = s.Repository.DeleteRefreshToken(stored.ID)
+		return nil, ErrInvalidToken
+	}
+	return stored, nil
+}
+
+// hashSecret hashes the random part of a token. The secrets are long
+// random strings, so a fast hash is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
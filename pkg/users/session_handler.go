@@ -0,0 +1,109 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"io/ioutil"
+	"net/http"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	// All ends every session of the user on logout, not just this one.
+	All bool `json:"all,omitempty"`
+}
+
+// TokenRefreshHandler trades a refresh token for a new access token and
+// a new refresh token. Role and lock state are read again, so changes
+// by an admin take effect on the next refresh.
+type TokenRefreshHandler struct {
+	Path           string
+	UserRepository UserRepository
+	Tokens         *Tokens
+	Sessions       *Sessions
+}
+
+func (t *TokenRefreshHandler) Refresh(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "POST" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+	refresh, ok := readRefreshRequest(writer, request)
+	if !ok {
+		return
+	}
+
+	session, refreshToken, err := t.Sessions.Rotate(refresh.RefreshToken)
+	if err != nil {
+		writeSessionError(writer, err)
+		return
+	}
+	user, err := t.UserRepository.FindByUsername(session.Username)
+	if err != nil {
+		apierror.Internal(writer)
+		return
+	}
+	if user == nil || user.Locked {
+		_ = t.Sessions.EndAll(session.Username)
+		if user == nil {
+			apierror.Unauthorized(writer)
+			return
+		}
+		apierror.Write(writer, http.StatusForbidden, "account_locked", "account is locked")
+		return
+	}
+	if !user.Role.Valid() {
+		user.Role = RoleMember
+	}
+
+	token, _ := t.Tokens.Create(user, session.ID)
+	writeLoggedInUser(writer, user, token, refreshToken)
+}
+
+// LogoutHandler ends the session of the given refresh token, or all
+// sessions of its user. Access tokens of ended sessions are rejected by
+// an Authenticator with Sessions.
+type LogoutHandler struct {
+	Path     string
+	Sessions *Sessions
+}
+
+func (l *LogoutHandler) Logout(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != "POST" {
+		apierror.MethodNotAllowed(writer)
+		return
+	}
+	refresh, ok := readRefreshRequest(writer, request)
+	if !ok {
+		return
+	}
+
+	session, err := l.Sessions.End(refresh.RefreshToken)
+	if err == nil && refresh.All {
+		err = l.Sessions.EndAll(session.Username)
+	}
+	if err != nil {
+		writeSessionError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func readRefreshRequest(writer http.ResponseWriter, request *http.Request) (*RefreshRequest, bool) {
+	requestBody, _ := ioutil.ReadAll(request.Body)
+	refresh := RefreshRequest{}
+	if json.Unmarshal(requestBody, &refresh) != nil || refresh.RefreshToken == "" {
+		apierror.BadRequest(writer, "request needs a refresh_token")
+		return nil, false
+	}
+	return &refresh, true
+}
+
+func writeSessionError(writer http.ResponseWriter, err error) {
+	if errors.Is(err, ErrInvalidToken) {
+		apierror.Unauthorized(writer)
+		return
+	}
+	apierror.Internal(writer)
+}
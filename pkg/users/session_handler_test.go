@@ -0,0 +1,172 @@
+package users_test
+
+import (
+	"encoding/json"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Refresh and logout", func() {
+
+	tokens := &users.Tokens{Secret: []byte("test-secret"), TTL: 15 * time.Minute}
+	var repository *users.UserMemoryRepository
+	var sessions *users.Sessions
+	var refreshHandler *users.TokenRefreshHandler
+	var logoutHandler *users.LogoutHandler
+
+	BeforeEach(func() {
+		repository = &users.UserMemoryRepository{}
+		Expect(repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed())
+		sessions = &users.Sessions{Repository: repository, TTL: time.Hour}
+		refreshHandler = &users.TokenRefreshHandler{
+			Path:           "/users/token/refresh",
+			UserRepository: repository,
+			Tokens:         tokens,
+			Sessions:       sessions,
+		}
+		logoutHandler = &users.LogoutHandler{
+			Path:     "/users/logout",
+			Sessions: sessions,
+		}
+	})
+
+	body := func(refresh users.RefreshRequest) *strings.Reader {
+		payload, err := json.Marshal(&refresh)
+		Expect(err).To(BeNil(), "JSON marshalling should work")
+		return strings.NewReader(string(payload))
+	}
+
+	login := func() *users.LoggedInUser {
+		handler := users.UserLoginHandler{
+			Path:           "/users/login",
+			UserRepository: repository,
+			Tokens:         tokens,
+			Sessions:       sessions,
+		}
+		recorder := httptest.NewRecorder()
+		handler.Login(recorder, httptest.NewRequest("POST", "/users/login", strings.NewReader(
+			`{"user":{"email":"flo@example.org","password":"sup3rpassw0rd"}}`)))
+		Expect(recorder.Code).To(Equal(200))
+		return unmarshalLogin(recorder.Body)
+	}
+
+	It("returns a refresh token on login", func() {
+		loggedIn := login()
+
+		Expect(loggedIn.RefreshToken).NotTo(BeEmpty())
+		_, session, err := tokens.Validate(loggedIn.Token)
+		Expect(err).To(BeNil())
+		Expect(loggedIn.RefreshToken).To(HavePrefix(session + "."))
+	})
+
+	It("trades a refresh token for new tokens", func() {
+		loggedIn := login()
+		Expect(repository.UpdateRole("flo", users.RoleModerator)).To(Succeed())
+		recorder := httptest.NewRecorder()
+
+		refreshHandler.Refresh(recorder, httptest.NewRequest("POST", refreshHandler.Path,
+			body(users.RefreshRequest{RefreshToken: loggedIn.RefreshToken})))
+
+		Expect(recorder.Code).To(Equal(200))
+		refreshed := unmarshalLogin(recorder.Body)
+		Expect(refreshed.Username).To(Equal("flo"))
+		Expect(refreshed.Role).To(Equal(users.RoleModerator))
+		Expect(refreshed.RefreshToken).NotTo(Equal(loggedIn.RefreshToken))
+		user, _, err := tokens.Validate(refreshed.Token)
+		Expect(err).To(BeNil())
+		Expect(user.Role).To(Equal(users.RoleModerator))
+	})
+
+	It("rejects refresh tokens that were used before", func() {
+		loggedIn := login()
+		request := users.RefreshRequest{RefreshToken: loggedIn.RefreshToken}
+		refreshHandler.Refresh(httptest.NewRecorder(), httptest.NewRequest("POST", refreshHandler.Path, body(request)))
+		recorder := httptest.NewRecorder()
+
+		refreshHandler.Refresh(recorder, httptest.NewRequest("POST", refreshHandler.Path, body(request)))
+
+		Expect(recorder.Code).To(Equal(401))
+	})
+
+	It("refuses to refresh for locked users", func() {
+		loggedIn := login()
+		Expect(repository.SetLocked("flo", true)).To(Succeed())
+		recorder := httptest.NewRecorder()
+
+		refreshHandler.Refresh(recorder, httptest.NewRequest("POST", refreshHandler.Path,
+			body(users.RefreshRequest{RefreshToken: loggedIn.RefreshToken})))
+
+		Expect(recorder.Code).To(Equal(403))
+		_, session, _ := tokens.Validate(loggedIn.Token)
+		Expect(sessions.Active(session)).To(Equal(users.ErrInvalidToken))
+	})
+
+	It("rejects refresh requests without token", func() {
+		recorder := httptest.NewRecorder()
+
+		refreshHandler.Refresh(recorder, httptest.NewRequest("POST", refreshHandler.Path, strings.NewReader("{}")))
+
+		Expect(recorder.Code).To(Equal(400))
+	})
+
+	It("revokes access tokens on logout", func() {
+		loggedIn := login()
+		authenticator := &users.Authenticator{Tokens: tokens, Sessions: sessions}
+		authenticated := func() int {
+			request := httptest.NewRequest("POST", "/node", nil)
+			request.Header.Set("Authorization", "Bearer "+loggedIn.Token)
+			recorder := httptest.NewRecorder()
+			authenticator.Authenticate(func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNoContent)
+			})(recorder, request)
+			return recorder.Code
+		}
+		Expect(authenticated()).To(Equal(204))
+		recorder := httptest.NewRecorder()
+
+		logoutHandler.Logout(recorder, httptest.NewRequest("POST", logoutHandler.Path,
+			body(users.RefreshRequest{RefreshToken: loggedIn.RefreshToken})))
+
+		Expect(recorder.Code).To(Equal(204))
+		Expect(authenticated()).To(Equal(401))
+		recorder = httptest.NewRecorder()
+		refreshHandler.Refresh(recorder, httptest.NewRequest("POST", refreshHandler.Path,
+			body(users.RefreshRequest{RefreshToken: loggedIn.RefreshToken})))
+		Expect(recorder.Code).To(Equal(401))
+	})
+
+	It("logs out everywhere", func() {
+		first := login()
+		second := login()
+		recorder := httptest.NewRecorder()
+
+		logoutHandler.Logout(recorder, httptest.NewRequest("POST", logoutHandler.Path,
+			body(users.RefreshRequest{RefreshToken: first.RefreshToken, All: true})))
+
+		Expect(recorder.Code).To(Equal(204))
+		_, session, _ := tokens.Validate(second.Token)
+		Expect(sessions.Active(session)).To(Equal(users.ErrInvalidToken))
+	})
+
+	It("only logs out with a valid refresh token", func() {
+		recorder := httptest.NewRecorder()
+
+		logoutHandler.Logout(recorder, httptest.NewRequest("POST", logoutHandler.Path,
+			body(users.RefreshRequest{RefreshToken: "unknown.secret"})))
+
+		Expect(recorder.Code).To(Equal(401))
+
+		recorder = httptest.NewRecorder()
+		logoutHandler.Logout(recorder, httptest.NewRequest("GET", logoutHandler.Path, nil))
+		Expect(recorder.Code).To(Equal(405))
+	})
+})
@@ -0,0 +1,110 @@
+package users_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Sessions", func() {
+
+	var repository *users.UserMemoryRepository
+	var sessions *users.Sessions
+
+	BeforeEach(func() {
+		repository = &users.UserMemoryRepository{}
+		Expect(repository.RegisterUser(&users.User{
+			Username: "flo",
+			Email:    "flo@example.org",
+			Password: "sup3rpassw0rd",
+		})).To(Succeed())
+		sessions = &users.Sessions{Repository: repository, TTL: time.Hour}
+	})
+
+	It("starts sessions for known users only", func() {
+		id, refreshToken, err := sessions.Start("flo")
+
+		Expect(err).To(BeNil())
+		Expect(refreshToken).To(HavePrefix(id + "."))
+		Expect(sessions.Active(id)).To(Succeed())
+
+		_, _, err = sessions.Start("nobody")
+		Expect(err).To(Equal(users.ErrUserNotFound))
+	})
+
+	It("only stores a hash of the refresh token", func() {
+		id, refreshToken, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+
+		stored, err := repository.FindRefreshToken(id)
+
+		Expect(err).To(BeNil())
+		Expect(stored.Username).To(Equal("flo"))
+		Expect(refreshToken).NotTo(ContainSubstring(stored.Hash))
+	})
+
+	It("rotates refresh tokens within the session", func() {
+		id, refreshToken, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+
+		session, rotated, err := sessions.Rotate(refreshToken)
+
+		Expect(err).To(BeNil())
+		Expect(session.ID).To(Equal(id))
+		Expect(session.Username).To(Equal("flo"))
+		Expect(rotated).NotTo(Equal(refreshToken))
+
+		_, _, err = sessions.Rotate(rotated)
+		Expect(err).To(BeNil())
+	})
+
+	It("ends the session when a rotated token is used again", func() {
+		id, refreshToken, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+		_, rotated, err := sessions.Rotate(refreshToken)
+		Expect(err).To(BeNil())
+
+		_, _, err = sessions.Rotate(refreshToken)
+
+		Expect(err).To(Equal(users.ErrInvalidToken))
+		Expect(sessions.Active(id)).To(Equal(users.ErrInvalidToken))
+		_, _, err = sessions.Rotate(rotated)
+		Expect(err).To(Equal(users.ErrInvalidToken))
+	})
+
+	It("rejects malformed, unknown and expired tokens", func() {
+		for _, refreshToken := range []string{"", "no-dot", ".secret", "id.", "unknown.secret"} {
+			_, _, err := sessions.Rotate(refreshToken)
+			Expect(err).To(Equal(users.ErrInvalidToken), refreshToken)
+		}
+
+		sessions.TTL = -time.Minute
+		id, refreshToken, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+
+		Expect(sessions.Active(id)).To(Equal(users.ErrInvalidToken))
+		_, _, err = sessions.Rotate(refreshToken)
+		Expect(err).To(Equal(users.ErrInvalidToken))
+	})
+
+	It("ends one or all sessions", func() {
+		first, firstToken, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+		second, _, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+		third, _, err := sessions.Start("flo")
+		Expect(err).To(BeNil())
+
+		ended, err := sessions.End(firstToken)
+
+		Expect(err).To(BeNil())
+		Expect(ended.ID).To(Equal(first))
+		Expect(sessions.Active(first)).To(Equal(users.ErrInvalidToken))
+		Expect(sessions.Active(second)).To(Succeed())
+
+		Expect(sessions.EndAll("flo")).To(Succeed())
+		Expect(sessions.Active(second)).To(Equal(users.ErrInvalidToken))
+		Expect(sessions.Active(third)).To(Equal(users.ErrInvalidToken))
+	})
+})
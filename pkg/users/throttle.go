@@ -0,0 +1,262 @@
+package users
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Reasons recorded for failed logins.
+const (
+	ReasonInvalidCredentials = "invalid_credentials"
+	ReasonAccountLocked      = "account_locked"
+	ReasonThrottled          = "throttled"
+)
+
+// failedLoginRetention is how long failed logins are kept for audits.
+const failedLoginRetention = 90 * 24 * time.Hour
+
+// LoginFailures counts the recent failed logins of an account or a
+// client address.
+type LoginFailures struct {
+	Key         string    `json:"key"`
+	Count       int       `json:"count"`
+	LastFailure time.Time `json:"last_failure"`
+}
+
+// FailedLogin is the audit record of a rejected login.
+type FailedLogin struct {
+	Email   string    `json:"email"`
+	Address string    `json:"address"`
+	Reason  string    `json:"reason"`
+	At      time.Time `json:"at"`
+}
+
+// LoginAttemptRepository stores failed logins.
+type LoginAttemptRepository interface {
+	// AddLoginFailure counts a failure of key at time at and returns the
+	// new count. Failures last counted before since are forgotten, for
+	// all keys.
+	AddLoginFailure(key string, at time.Time, since time.Time) (*LoginFailures, error)
+	// FindLoginFailures returns nil if key has no failures.
+	FindLoginFailures(key string) (*LoginFailures, error)
+	// ReleaseLoginFailure takes back a failure counted by AddLoginFailure,
+	// keeping the time of the last one. Keys without failures are deleted.
+	ReleaseLoginFailure(key string) error
+	DeleteLoginFailures(key string) error
+	// SaveFailedLogin stores an audit record and drops the records from
+	// before since.
+	SaveFailedLogin(record *FailedLogin, since time.Time) error
+	// FindFailedLogins returns up to limit records, newest first.
+	FindFailedLogins(limit int) ([]*FailedLogin, error)
+}
+
+// ThrottlePolicy tells how long to wait after failed logins. The first
+// FreeAttempts failures cost nothing, every further one doubles the wait
+// starting at BaseDelay up to MaxDelay. After LockoutAfter failures,
+// logins are refused for Lockout.
+type ThrottlePolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockoutAfter int
+	Lockout      time.Duration
+}
+
+// blockedUntil returns when failures allow the next attempt.
+func (p ThrottlePolicy) blockedUntil(failures *LoginFailures) time.Time {
+	if failures == nil || failures.Count <= p.FreeAttempts {
+		return time.Time{}
+	}
+	if p.LockoutAfter > 0 && failures.Count >= p.LockoutAfter {
+		return failures.LastFailure.Add(p.Lockout)
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures.Count && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return failures.LastFailure.Add(delay)
+}
+
+// LoginThrottle slows down password guessing, both against one account
+// and from one client address. Failures are forgotten ForgetAfter the
+// last one, so it should be longer than the lockouts. Client addresses
+// are taken from X-Forwarded-For if TrustProxy is set.
+type LoginThrottle struct {
+	Repository  LoginAttemptRepository
+	Account     ThrottlePolicy
+	Address     ThrottlePolicy
+	ForgetAfter time.Duration
+	TrustProxy  bool
+	// Clock returns the current time, time.Now if nil.
+	Clock func() time.Time
+}
+
+// Check returns how long the client has to wait before it may try to log
+// in to the account with email, or zero.
+func (t *LoginThrottle) Check(email string, address string) (time.Duration, error) {
+	now := t.now()
+	var until time.Time
+	for _, check := range t.checks(email, address) {
+		failures, err := t.recentFailures(check.key, now)
+		if err != nil {
+			return 0, err
+		}
+		until = later(until, check.policy.blockedUntil(failures))
+	}
+	return t.wait(until, now), nil
+}
+
+// Reserve counts a login attempt as failed before the password is
+// checked, so that attempts made at the same time cannot all pass Check.
+// It returns how long the client has to wait, or zero if the attempt may
+// go on. Rejected attempts are not counted. A right password has to be
+// followed by Succeed or Release.
+func (t *LoginThrottle) Reserve(email string, address string) (time.Duration, error) {
+	now := t.now()
+	since := now.Add(-t.ForgetAfter)
+	var until time.Time
+	var reserved []string
+	for _, check := range t.checks(email, address) {
+		if until.After(now) {
+			// Rejected already, so do not crowd out other attempts.
+			break
+		}
+		failures, err := t.recentFailures(check.key, now)
+		if err != nil {
+			_ = t.release(reserved)
+			return 0, err
+		}
+		if blocked := check.policy.blockedUntil(failures); blocked.After(now) {
+			until = later(until, blocked)
+			continue
+		}
+		counted, err := t.Repository.AddLoginFailure(check.key, now, since)
+		if err != nil {
+			_ = t.release(reserved)
+			return 0, err
+		}
+		reserved = append(reserved, check.key)
+		if failures == nil {
+			failures = &LoginFailures{}
+		}
+		if counted.Count != failures.Count+1 {
+			// Attempts made at the same time were counted in between,
+			// they may all fail right now.
+			previous := &LoginFailures{Key: check.key, Count: counted.Count - 1, LastFailure: now}
+			until = later(until, check.policy.blockedUntil(previous))
+		}
+	}
+	if wait := t.wait(until, now); wait > 0 {
+		_ = t.release(reserved)
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// Audit records a rejected login without counting it.
+func (t *LoginThrottle) Audit(email string, address string, reason string) error {
+	now := t.now()
+	return t.Repository.SaveFailedLogin(&FailedLogin{
+		Email:   email,
+		Address: address,
+		Reason:  reason,
+		At:      now.UTC(),
+	}, now.Add(-failedLoginRetention))
+}
+
+// Succeed forgets the failures of the account after a successful login
+// and takes back the attempt reserved for the address. Other failures of
+// the address are kept, or logging in to an own account would reset them.
+func (t *LoginThrottle) Succeed(email string, address string) error {
+	if err := t.Repository.DeleteLoginFailures(accountKey(email)); err != nil {
+		return err
+	}
+	return t.Repository.ReleaseLoginFailure(addressKey(address))
+}
+
+// Release takes back an attempt reserved with a right password that is
+// refused nonetheless, like for locked accounts.
+func (t *LoginThrottle) Release(email string, address string) error {
+	return t.release([]string{accountKey(email), addressKey(address)})
+}
+
+func (t *LoginThrottle) release(keys []string) error {
+	for _, key := range keys {
+		if err := t.Repository.ReleaseLoginFailure(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientAddress returns the address logins of request are counted for.
+func (t *LoginThrottle) ClientAddress(request *http.Request) string {
+	if t.TrustProxy {
+		// The last entry was added by the proxy, earlier ones by the
+		// client itself.
+		forwarded := strings.Split(request.Header.Get("X-Forwarded-For"), ",")
+		if address := strings.TrimSpace(forwarded[len(forwarded)-1]); address != "" {
+			return address
+		}
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+type throttleCheck struct {
+	key    string
+	policy ThrottlePolicy
+}
+
+func (t *LoginThrottle) checks(email string, address string) []throttleCheck {
+	return []throttleCheck{
+		{accountKey(email), t.Account},
+		{addressKey(address), t.Address},
+	}
+}
+
+// recentFailures returns the failures of key unless they are forgotten.
+func (t *LoginThrottle) recentFailures(key string, now time.Time) (*LoginFailures, error) {
+	failures, err := t.Repository.FindLoginFailures(key)
+	if err != nil || failures == nil || failures.LastFailure.Before(now.Add(-t.ForgetAfter)) {
+		return nil, err
+	}
+	return failures, nil
+}
+
+func (t *LoginThrottle) wait(until time.Time, now time.Time) time.Duration {
+	if !until.After(now) {
+		return 0
+	}
+	return until.Sub(now)
+}
+
+func later(a time.Time, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+func (t *LoginThrottle) now() time.Time {
+	if t.Clock != nil {
+		return t.Clock()
+	}
+	return time.Now()
+}
+
+func accountKey(email string) string {
+	return "account:" + email
+}
+
+func addressKey(address string) string {
+	return "address:" + address
+}
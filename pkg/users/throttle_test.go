@@ -0,0 +1,162 @@
+package users_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Login throttle", func() {
+
+	var repository *users.LoginAttemptMemoryRepository
+	var throttle *users.LoginThrottle
+	var now time.Time
+
+	BeforeEach(func() {
+		repository = &users.LoginAttemptMemoryRepository{}
+		now = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+		throttle = &users.LoginThrottle{
+			Repository: repository,
+			Account: users.ThrottlePolicy{
+				FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 5 * time.Second,
+				LockoutAfter: 8, Lockout: 15 * time.Minute,
+			},
+			Address: users.ThrottlePolicy{
+				FreeAttempts: 4, BaseDelay: time.Second, MaxDelay: 5 * time.Second,
+				LockoutAfter: 20, Lockout: 15 * time.Minute,
+			},
+			ForgetAfter: time.Hour,
+			Clock:       func() time.Time { return now },
+		}
+	})
+
+	fail := func(times int, email string, address string) {
+		for i := 0; i < times; i++ {
+			for _, key := range []string{"account:" + email, "address:" + address} {
+				_, err := repository.AddLoginFailure(key, now, now.Add(-time.Hour))
+				Expect(err).To(BeNil())
+			}
+		}
+	}
+
+	It("lets the first attempts through", func() {
+		fail(2, "flo@example.org", "192.0.2.1")
+
+		Expect(throttle.Check("flo@example.org", "192.0.2.1")).To(BeZero())
+	})
+
+	It("doubles the wait with every further failure", func() {
+		fail(3, "flo@example.org", "192.0.2.1")
+		Expect(throttle.Check("flo@example.org", "192.0.2.1")).To(Equal(time.Second))
+
+		fail(1, "flo@example.org", "192.0.2.1")
+		Expect(throttle.Check("flo@example.org", "192.0.2.1")).To(Equal(2 * time.Second))
+
+		fail(3, "flo@example.org", "192.0.2.1")
+		Expect(throttle.Check("flo@example.org", "192.0.2.1")).To(Equal(5 * time.Second))
+
+		now = now.Add(5 * time.Second)
+		Expect(throttle.Check("flo@example.org", "192.0.2.1")).To(BeZero())
+	})
+
+	It("locks accounts out", func() {
+		fail(8, "flo@example.org", "192.0.2.1")
+
+		Expect(throttle.Check("flo@example.org", "198.51.100.7")).To(Equal(15 * time.Minute))
+	})
+
+	It("slows down addresses trying many accounts", func() {
+		for _, email := range []string{"a@example.org", "b@example.org", "c@example.org",
+			"d@example.org", "e@example.org"} {
+			fail(1, email, "192.0.2.1")
+		}
+
+		Expect(throttle.Check("f@example.org", "192.0.2.1")).To(Equal(time.Second))
+		Expect(throttle.Check("f@example.org", "198.51.100.7")).To(BeZero())
+	})
+
+	It("forgets failures after a while", func() {
+		fail(8, "flo@example.org", "192.0.2.1")
+
+		now = now.Add(time.Hour + time.Second)
+
+		Expect(throttle.Check("flo@example.org", "192.0.2.1")).To(BeZero())
+	})
+
+	It("counts reserved attempts until they succeed", func() {
+		for i := 0; i < 3; i++ {
+			Expect(throttle.Reserve("flo@example.org", "192.0.2.1")).To(BeZero())
+		}
+		Expect(throttle.Reserve("flo@example.org", "192.0.2.1")).To(Equal(time.Second))
+
+		Expect(repository.FindLoginFailures("account:flo@example.org")).To(
+			Equal(&users.LoginFailures{Key: "account:flo@example.org", Count: 3, LastFailure: now}))
+	})
+
+	It("lets only the allowed share of simultaneous attempts through", func() {
+		fail(2, "flo@example.org", "192.0.2.1")
+		allowed := make(chan bool, 10)
+
+		var attempts sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			attempts.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer attempts.Done()
+				wait, err := throttle.Reserve("flo@example.org", "192.0.2.1")
+				Expect(err).To(BeNil())
+				allowed <- wait == 0
+			}()
+		}
+		attempts.Wait()
+		close(allowed)
+
+		passed := 0
+		for ok := range allowed {
+			if ok {
+				passed++
+			}
+		}
+		Expect(passed).To(Equal(1))
+		failures, err := repository.FindLoginFailures("account:flo@example.org")
+		Expect(err).To(BeNil())
+		Expect(failures.Count).To(Equal(3))
+	})
+
+	It("resets accounts but not addresses on success", func() {
+		fail(5, "flo@example.org", "192.0.2.1")
+		now = now.Add(5 * time.Second)
+		Expect(throttle.Reserve("flo@example.org", "192.0.2.1")).To(BeZero())
+
+		Expect(throttle.Succeed("flo@example.org", "192.0.2.1")).To(Succeed())
+
+		Expect(throttle.Check("flo@example.org", "198.51.100.7")).To(BeZero())
+		Expect(throttle.Check("other@example.org", "192.0.2.1")).To(Equal(time.Second))
+	})
+
+	It("records failed logins", func() {
+		Expect(throttle.Audit("flo@example.org", "192.0.2.1", users.ReasonInvalidCredentials)).To(Succeed())
+		Expect(throttle.Audit("flo@example.org", "192.0.2.1", users.ReasonThrottled)).To(Succeed())
+
+		records, err := repository.FindFailedLogins(10)
+
+		Expect(err).To(BeNil())
+		Expect(records).To(Equal([]*users.FailedLogin{
+			{Email: "flo@example.org", Address: "192.0.2.1", Reason: users.ReasonThrottled, At: now},
+			{Email: "flo@example.org", Address: "192.0.2.1", Reason: users.ReasonInvalidCredentials, At: now},
+		}))
+	})
+
+	It("only trusts X-Forwarded-For behind a proxy", func() {
+		request := httptest.NewRequest("POST", "/users/login", nil)
+		request.RemoteAddr = "10.0.0.2:41234"
+		request.Header.Set("X-Forwarded-For", "203.0.113.9, 192.0.2.1")
+
+		Expect(throttle.ClientAddress(request)).To(Equal("10.0.0.2"))
+		throttle.TrustProxy = true
+		Expect(throttle.ClientAddress(request)).To(Equal("192.0.2.1"))
+	})
+})
@@ -0,0 +1,68 @@
+package users
+
+import (
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"time"
+)
+
+// Tokens issues and checks the HS512 signed access tokens handed out on
+// login. Tokens expire after TTL.
+type Tokens struct {
+	Secret []byte
+	TTL    time.Duration
+}
+
+// Create issues an access token for user within the login session with
+// the given ID, see Sessions.
+func (t *Tokens) Create(user *User, session string) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["authorized"] = true
+	claims["user_id"] = user.Username
+	claims["role"] = string(user.Role)
+	claims["verified"] = user.Verified
+	if session != "" {
+		claims["sid"] = session
+	}
+	claims["exp"] = time.Now().Add(t.TTL).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	return token.SignedString(t.Secret)
+}
+
+// Validate checks a token issued by Create and returns the user it was
+// issued for, carrying username, role and whether the email address was
+// verified, and the session ID.
+func (t *Tokens) Validate(tokenString string) (user *User, session string, err error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS512 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return t.Secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, "", ErrInvalidToken
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, "", ErrInvalidToken
+	}
+	if authorized, _ := claims["authorized"].(bool); !authorized {
+		return nil, "", ErrInvalidToken
+	}
+	username, _ := claims["user_id"].(string)
+	if username == "" {
+		return nil, "", ErrInvalidToken
+	}
+	role, _ := claims["role"].(string)
+	if !Role(role).Valid() {
+		role = string(RoleMember)
+	}
+	verified, _ := claims["verified"].(bool)
+	session, _ = claims["sid"].(string)
+	return &User{
+		Username: username,
+		Role:     Role(role),
+		Verified: verified,
+	}, session, nil
+}
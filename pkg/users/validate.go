@@ -0,0 +1,204 @@
+package users
+
+import (
+	"fmt"
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	minimumUsernameLength = 3
+	maximumUsernameLength = 32
+	// MinimumPasswordLength is the length new passwords need at least.
+	MinimumPasswordLength = 8
+	// maximumPasswordLength is the most bcrypt looks at.
+	maximumPasswordLength = 72
+	maximumEmailLength    = 254
+
+	maximumDisplayNameLength = 64
+	maximumCallsignLength    = 16
+	maximumContactLength     = 256
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// callsignPattern allows portable and mobile suffixes like DL1ABC/P.
+var callsignPattern = regexp.MustCompile(`^[A-Za-z0-9]+(/[A-Za-z0-9]+)*$`)
+
+// reservedUsernames would be confused with paths or staff.
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "hnetdb": true, "me": true,
+	"root": true, "system": true,
+}
+
+// commonPasswords are rejected even though they satisfy the other rules.
+var commonPasswords = map[string]bool{
+	"password1": true, "passw0rd": true, "password123": true, "12345678a": true,
+	"qwerty123": true, "abc12345": true, "iloveyou1": true, "welcome1": true,
+	"letmein1": true, "1q2w3e4r": true, "1qaz2wsx": true, "p@ssw0rd": true,
+	"trustno1": true, "sunshine1": true, "football1": true, "monkey123": true,
+}
+
+// ValidationError lists the rejected fields of a request.
+type ValidationError struct {
+	Fields []apierror.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.Field + " " + field.Message
+	}
+	return "invalid request: " + strings.Join(problems, ", ")
+}
+
+type validation struct {
+	fields []apierror.FieldError
+}
+
+func (v *validation) reject(field string, message string) {
+	v.fields = append(v.fields, apierror.FieldError{Field: field, Message: message})
+}
+
+func (v *validation) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// NormalizeEmail returns the form email addresses are stored and looked
+// up in.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateRegistration checks a new user. The email address has to be
+// normalized already.
+func ValidateRegistration(user *User) error {
+	v := &validation{}
+	if message := checkUsername(user.Username); message != "" {
+		v.reject("username", message)
+	}
+	if message := checkEmail(user.Email); message != "" {
+		v.reject("email", message)
+	}
+	if message := checkPassword(user.Username, user.Password); message != "" {
+		v.reject("password", message)
+	}
+	return v.err()
+}
+
+// ValidatePassword checks a new password of username. username may be
+// empty if it is not known yet.
+func ValidatePassword(username string, password string) error {
+	v := &validation{}
+	if message := checkPassword(username, password); message != "" {
+		v.reject("password", message)
+	}
+	return v.err()
+}
+
+// ValidateProfilePatch checks the changed fields of a profile. The email
+// address has to be normalized already.
+func ValidateProfilePatch(patch *ProfilePatch) error {
+	v := &validation{}
+	if patch.Username != nil {
+		if message := checkUsername(*patch.Username); message != "" {
+			v.reject("username", message)
+		}
+	}
+	if patch.Email != nil {
+		if message := checkEmail(*patch.Email); message != "" {
+			v.reject("email", message)
+		}
+	}
+	for _, field := range []struct {
+		name    string
+		value   *string
+		maximum int
+	}{
+		{"display_name", patch.DisplayName, maximumDisplayNameLength},
+		{"callsign", patch.Callsign, maximumCallsignLength},
+		{"contact", patch.Contact, maximumContactLength},
+	} {
+		if field.value == nil {
+			continue
+		}
+		if message := checkText(*field.value, field.maximum); message != "" {
+			v.reject(field.name, message)
+		}
+	}
+	if patch.Callsign != nil && *patch.Callsign != "" && !callsignPattern.MatchString(*patch.Callsign) {
+		v.reject("callsign", "may only contain letters, digits and '/'")
+	}
+	return v.err()
+}
+
+func checkUsername(username string) string {
+	switch {
+	case username == "":
+		return "is required"
+	case reservedUsernames[strings.ToLower(username)]:
+		return "is reserved"
+	case len(username) < minimumUsernameLength || len(username) > maximumUsernameLength:
+		return fmt.Sprintf("has to have %d to %d characters", minimumUsernameLength, maximumUsernameLength)
+	case !usernamePattern.MatchString(username):
+		return "may only contain letters, digits, '.', '_' and '-' and has to start with a letter or digit"
+	}
+	return ""
+}
+
+func checkEmail(email string) string {
+	if email == "" {
+		return "is required"
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || len(email) > maximumEmailLength ||
+		!strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return "is not a valid email address"
+	}
+	return ""
+}
+
+// checkText allows empty values, which clear a field.
+func checkText(text string, maximum int) string {
+	if utf8.RuneCountInString(text) > maximum {
+		return fmt.Sprintf("may have at most %d characters", maximum)
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			return "may not contain control characters"
+		}
+	}
+	return ""
+}
+
+func checkPassword(username string, password string) string {
+	var letter, other bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			letter = true
+		} else {
+			other = true
+		}
+	}
+	lower := strings.ToLower(password)
+	switch {
+	case len(password) < MinimumPasswordLength:
+		return fmt.Sprintf("has to have at least %d characters", MinimumPasswordLength)
+	case len(password) > maximumPasswordLength:
+		return fmt.Sprintf("may have at most %d bytes", maximumPasswordLength)
+	case !letter || !other:
+		return "has to contain a letter and a digit or symbol"
+	case username != "" && strings.Contains(lower, strings.ToLower(username)):
+		return "may not contain the username"
+	case commonPasswords[lower]:
+		return "is too common"
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package users_test
+
+import (
+	"github.com/mvslovers/hnetdb/pkg/apierror"
+	"github.com/mvslovers/hnetdb/pkg/users"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"strings"
+)
+
+var _ = Describe("Validation", func() {
+
+	valid := func() *users.User {
+		return &users.User{Username: "flo", Email: "flo@example.org", Password: "sup3rpassw0rd"}
+	}
+
+	It("accepts valid registrations", func() {
+		Expect(users.ValidateRegistration(valid())).To(Succeed())
+	})
+
+	DescribeTable("rejects registrations",
+		func(change func(user *users.User), field string, message string) {
+			user := valid()
+			change(user)
+
+			err := users.ValidateRegistration(user)
+
+			Expect(err).To(BeAssignableToTypeOf(&users.ValidationError{}))
+			Expect(err.(*users.ValidationError).Fields).To(Equal([]apierror.FieldError{{Field: field, Message: message}}))
+		},
+		Entry("without username",
+			func(user *users.User) { user.Username = "" }, "username", "is required"),
+		Entry("with a long username",
+			func(user *users.User) { user.Username = strings.Repeat("a", 33) }, "username", "has to have 3 to 32 characters"),
+		Entry("with spaces in the username",
+			func(user *users.User) { user.Username = "flo rent" }, "username",
+			"may only contain letters, digits, '.', '_' and '-' and has to start with a letter or digit"),
+		Entry("with a reserved username",
+			func(user *users.User) { user.Username = "Me" }, "username", "is reserved"),
+		Entry("without email address",
+			func(user *users.User) { user.Email = "" }, "email", "is required"),
+		Entry("with a display name in the email address",
+			func(user *users.User) { user.Email = "Flo <flo@example.org>" }, "email", "is not a valid email address"),
+		Entry("with an email address without domain",
+			func(user *users.User) { user.Email = "flo@localhost" }, "email", "is not a valid email address"),
+		Entry("with a short password",
+			func(user *users.User) { user.Password = "s3cr3t" }, "password", "has to have at least 8 characters"),
+		Entry("with a password bcrypt would cut off",
+			func(user *users.User) { user.Password = strings.Repeat("a1", 37) }, "password", "may have at most 72 bytes"),
+		Entry("with a password of letters only",
+			func(user *users.User) { user.Password = "supersecret" }, "password", "has to contain a letter and a digit or symbol"),
+		Entry("with the username in the password",
+			func(user *users.User) { user.Password = "FLO-is-great" }, "password", "may not contain the username"),
+		Entry("with a common password",
+			func(user *users.User) { user.Password = "Passw0rd" }, "password", "is too common"),
+	)
+
+	It("checks passwords of unknown users", func() {
+		Expect(users.ValidatePassword("", "flo-is-great")).To(Succeed())
+		Expect(users.ValidatePassword("flo", "flo-is-great")).To(HaveOccurred())
+	})
+
+	text := func(value string) *string {
+		return &value
+	}
+
+	It("accepts profile changes and cleared contact details", func() {
+		Expect(users.ValidateProfilePatch(&users.ProfilePatch{
+			Username:    text("florent"),
+			DisplayName: text(""),
+			Callsign:    text("DL1ABC/P"),
+			Contact:     text("https://example.org/flo"),
+		})).To(Succeed())
+	})
+
+	DescribeTable("rejects profile changes",
+		func(patch *users.ProfilePatch, field string, message string) {
+			err := users.ValidateProfilePatch(patch)
+
+			Expect(err).To(BeAssignableToTypeOf(&users.ValidationError{}))
+			Expect(err.(*users.ValidationError).Fields).To(Equal([]apierror.FieldError{{Field: field, Message: message}}))
+		},
+		Entry("with a reserved username",
+			&users.ProfilePatch{Username: text("admin")}, "username", "is reserved"),
+		Entry("with an invalid email address",
+			&users.ProfilePatch{Email: text("flo")}, "email", "is not a valid email address"),
+		Entry("with a long display name",
+			&users.ProfilePatch{DisplayName: text(strings.Repeat("ä", 65))}, "display_name", "may have at most 64 characters"),
+		Entry("with a line break in the contact",
+			&users.ProfilePatch{Contact: text("flo\nexample")}, "contact", "may not contain control characters"),
+		Entry("with spaces in the callsign",
+			&users.ProfilePatch{Callsign: text("DL1 ABC")}, "callsign", "may only contain letters, digits and '/'"),
+	)
+
+	It("normalizes email addresses", func() {
+		Expect(users.NormalizeEmail(" Flo@Example.ORG\n")).To(Equal("flo@example.org"))
+	})
+})